@@ -0,0 +1,80 @@
+package carrot_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/nvlled/carrot"
+	"github.com/nvlled/carrot/carrottest"
+)
+
+// Run with: go test -run '^$' -bench . -count 10 | tee new.txt
+// and compare against an earlier run with benchstat old.txt new.txt.
+
+func BenchmarkDeepNesting(b *testing.B) {
+	for _, depth := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("depth=%v", depth), func(b *testing.B) {
+			carrottest.BenchUpdate(b, carrot.Start(carrottest.Nested(depth)))
+		})
+	}
+}
+
+func BenchmarkWideFanOut(b *testing.B) {
+	for _, width := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("width=%v", width), func(b *testing.B) {
+			carrottest.BenchUpdate(b, carrot.Start(carrottest.FanOut(width)))
+		})
+	}
+}
+
+func BenchmarkTransitionChurn(b *testing.B) {
+	carrottest.BenchUpdate(b, carrot.Start(carrottest.Churn))
+}
+
+func BenchmarkManager(b *testing.B) {
+	for _, count := range []int{100, 10000} {
+		b.Run(fmt.Sprintf("scripts=%v", count), func(b *testing.B) {
+			manager := carrot.NewManager()
+			scripts := make([]*carrot.Script, count)
+			for i := range scripts {
+				scripts[i] = manager.Start(carrottest.Spin)
+			}
+			carrottest.BenchManager(b, manager, scripts)
+		})
+	}
+}
+
+// Each goroutine updates its own script,
+// like a server with one script per connection.
+func BenchmarkParallelUpdate(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		script := carrot.Start(carrottest.FanOut(10))
+		defer script.Close()
+		for pb.Next() {
+			script.Update()
+		}
+	})
+}
+
+// Compares the cost of the instrumentation,
+// see carrot.SetInstrumentation().
+func BenchmarkInstrumentation(b *testing.B) {
+	labeled := func(ctrl *carrot.Control) {
+		for i := 0; i < 10; i++ {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				for {
+					ctrl.YieldNamed("spin")
+				}
+			})
+		}
+		carrottest.Spin(ctrl)
+	}
+	for _, enabled := range []bool{true, false} {
+		b.Run(fmt.Sprintf("enabled=%v", enabled), func(b *testing.B) {
+			carrot.SetInstrumentation(enabled)
+			defer carrot.SetInstrumentation(true)
+			carrottest.BenchUpdate(b, carrot.Start(labeled))
+		})
+	}
+}
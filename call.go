@@ -0,0 +1,45 @@
+package carrot
+
+import "sync/atomic"
+
+// Calls fn on the main thread on the next Update(), and
+// yields until it is done. Returns the result of fn.
+// Useful for reading engine state that is only safe to
+// access on the main thread. fn is called before the coroutines
+// are resumed, so this returns within the same Update().
+// Panics when cancelled, or returns ErrCancelled if the
+// cancel policy is not CancelPanic. fn is skipped if the
+// coroutine is already gone on the next Update(), but
+// may still be called if it's cancelled meanwhile.
+//
+//	Note: fn must not block, since it runs inside Update().
+func CallOnUpdate[T any](ctrl *Control, fn func() T) (T, error) {
+	return CallOn(ctrl, ctrl.callOnUpdate, fn)
+}
+
+// Same as CallOnUpdate(), but fn is handed to schedule, which
+// must call it later on the thread that fn is meant for, for
+// instance the queue of a UI loop. Yields until fn is done.
+// Panics when cancelled, or returns ErrCancelled if the
+// cancel policy is not CancelPanic. fn is skipped if the
+// coroutine is already gone when it's called.
+func CallOn[T any](ctrl *Control, schedule func(func()), fn func() T) (T, error) {
+	var zero T
+	// only read by the coroutine once the state is taskDone
+	var result T
+	var state atomic.Int32
+	schedule(func() {
+		if state.Load() == taskAbandoned {
+			return
+		}
+		result = fn()
+		state.CompareAndSwap(taskPending, taskDone)
+	})
+	// also abandons the call when the wait panics
+	defer state.CompareAndSwap(taskPending, taskAbandoned)
+	YieldUntilEqual(ctrl, state.Load, taskDone)
+	if state.CompareAndSwap(taskPending, taskAbandoned) {
+		return zero, ErrCancelled
+	}
+	return result, nil
+}
@@ -0,0 +1,67 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestCallOnUpdate(t *testing.T) {
+	// only accessed on the main thread
+	frame := 0
+	mainThreadOnly := func() int { return frame }
+
+	var got []int
+	call := func(ctrl *carrot.Control) {
+		result, err := carrot.CallOnUpdate(ctrl, mainThreadOnly)
+		if err != nil {
+			t.Error(err)
+		}
+		got = append(got, result)
+	}
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Yield()
+		call(ctrl)
+		child := ctrl.StartAsync(call)
+		child.Join(ctrl)
+	})
+
+	for !script.IsDone() {
+		frame++
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if len(got) != 2 || got[0] != 3 || got[1] <= got[0] {
+		t.Error("wrong results", got)
+	}
+}
+
+func TestCallOnUpdateCancelled(t *testing.T) {
+	var called, returned atomic.Bool
+	var callErr atomic.Value
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for ctrl.YieldErr() == nil {
+		}
+		_, err := carrot.CallOnUpdate(ctrl, func() int {
+			called.Store(true)
+			return 1
+		})
+		callErr.Store(err)
+		returned.Store(true)
+	}, carrot.WithCancelPolicy(carrot.CancelError))
+
+	script.Update()
+	script.Cancel()
+	for i := 0; i < 5; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if err, _ := callErr.Load().(error); !returned.Load() || err != carrot.ErrCancelled {
+		t.Error("CallOnUpdate should return ErrCancelled", err)
+	}
+	if called.Load() {
+		t.Error("fn should be skipped once the call is abandoned")
+	}
+}
@@ -0,0 +1,236 @@
+package carrot
+
+import (
+	"context"
+	"time"
+)
+
+// A CancelPolicy decides how a cancellation is delivered
+// to the coroutines of a script. See WithCancelPolicy().
+type CancelPolicy uint8
+
+const (
+	// Yield methods panic with ErrCancelled when the coroutine
+	// is cancelled. The panic is recovered by the coroutine,
+	// so no code after the yield is run. This is the default.
+	CancelPanic CancelPolicy = iota
+
+	// Yield methods return early when the coroutine is
+	// cancelled, without waiting for their condition.
+	// The coroutine must check for the cancellation,
+	// with IsCancelled() or the *Err() variants of the
+	// yield methods such as YieldErr(), and return.
+	CancelError
+
+	// Same as CancelError, but the coroutine is expected to
+	// check for the cancellation with ctrl.Context() instead.
+	CancelContext
+)
+
+func (policy CancelPolicy) String() string {
+	switch policy {
+	case CancelPanic:
+		return "panic"
+	case CancelError:
+		return "error"
+	case CancelContext:
+		return "context"
+	}
+	return "unknown"
+}
+
+// Sets how a cancellation is delivered to the coroutines
+// of the script. Only has effect when used with Start()
+// or Create(), child coroutines always use the
+// policy of their script.
+//
+//	Note: With CancelError or CancelContext, a coroutine
+//	that keeps yielding after it's cancelled will never end,
+//	and so will its parent, which waits for its children.
+func WithCancelPolicy(policy CancelPolicy) Option {
+	return func(ctrl *Control) {
+		ctrl.cancelPolicy = policy
+	}
+}
+
+// Returns true if the coroutine has been cancelled.
+// Mainly used with CancelError, see WithCancelPolicy().
+func (ctrl *Control) IsCancelled() bool {
+	return ctrl.isCanceled()
+}
+
+// Returns a context that is cancelled when the coroutine
+// is cancelled or ends. Mainly used with CancelContext,
+// see WithCancelPolicy(), but can also be passed to
+// functions that accept a context.
+func (ctrl *Control) Context() context.Context {
+	ctrl.ctxMu.Lock()
+	defer ctrl.ctxMu.Unlock()
+	if ctrl.ctx == nil {
+		parent := ctrl.spanCtx
+		if parent == nil {
+			parent = context.Background()
+		}
+		ctrl.ctx, ctrl.ctxCancel = context.WithCancel(parent)
+		if ctrl.isCanceled() {
+			ctrl.ctxCancel()
+		}
+	}
+	return ctrl.ctx
+}
+
+// Same as Yield(), but returns ErrCancelled instead of
+// panicking when cancelled, regardless of the policy.
+func (ctrl *Control) YieldErr() error {
+	return ctrl.catchCancel(ctrl.Yield)
+}
+
+// Same as Delay(), but returns ErrCancelled instead of
+// panicking when cancelled, regardless of the policy.
+func (ctrl *Control) DelayErr(count int) error {
+	return ctrl.catchCancel(func() { ctrl.Delay(count) })
+}
+
+// Same as Sleep(), but returns ErrCancelled instead of
+// panicking when cancelled, regardless of the policy.
+func (ctrl *Control) SleepErr(duration time.Duration) error {
+	return ctrl.catchCancel(func() { ctrl.Sleep(duration) })
+}
+
+// Same as YieldUntil(), but returns ErrCancelled instead of
+// panicking when cancelled, regardless of the policy.
+func (ctrl *Control) YieldUntilErr(fn func() bool) error {
+	return ctrl.catchCancel(func() { ctrl.YieldUntil(fn) })
+}
+
+// Same as YieldWhile(), but returns ErrCancelled instead of
+// panicking when cancelled, regardless of the policy.
+func (ctrl *Control) YieldWhileErr(fn func() bool) error {
+	return ctrl.catchCancel(func() { ctrl.YieldWhile(fn) })
+}
+
+func (ctrl *Control) catchCancel(fn func()) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			if e != ErrCancelled {
+				panic(e)
+			}
+			err = ErrCancelled
+		}
+	}()
+	fn()
+	if ctrl.isCanceled() {
+		return ErrCancelled
+	}
+	return nil
+}
+
+// Returns true if a yield method should stop waiting
+// since the coroutine is cancelled and the policy
+// does not panic, or since Validate() forced the wait to end.
+// Has no side effects, so it can be checked any number of
+// times during a wait and after it ends.
+func (ctrl *Control) cancelReturns() bool {
+	if ctrl.isCanceled() && ctrl.root().cancelPolicy != CancelPanic {
+		return true
+	}
+	return ctrl.forceWait.Load()
+}
+
+// Must be called by the yield methods before they start
+// waiting. A wait forced to end by Validate() only ends
+// that wait, the next one starts unforced.
+func (ctrl *Control) beginWait() {
+	if ctrl.forceWait.Load() {
+		ctrl.forceWait.Store(false)
+	}
+}
+
+func (ctrl *Control) cancelContext() {
+	ctrl.ctxMu.Lock()
+	defer ctrl.ctxMu.Unlock()
+	if ctrl.ctxCancel != nil {
+		ctrl.ctxCancel()
+	}
+}
+
+func (ctrl *Control) resetContext() {
+	ctrl.ctxMu.Lock()
+	defer ctrl.ctxMu.Unlock()
+	if ctrl.ctxCancel != nil {
+		ctrl.ctxCancel()
+	}
+	ctrl.ctx = nil
+	ctrl.ctxCancel = nil
+}
+
+// A CancelCause tells why a coroutine was cancelled.
+// See ctrl.Cause().
+type CancelCause uint32
+
+const (
+	// The coroutine is not cancelled.
+	CauseNone CancelCause = iota
+
+	// Cancelled with Cancel().
+	CauseExplicitCancel
+
+	// Cancelled since it was restarted while still running,
+	// with Restart() or RestartKeepChildren().
+	CauseRestart
+
+	// Cancelled since it was replaced by another
+	// coroutine with Transition() or TransitionBack().
+	CauseTransition
+
+	// Cancelled since its parent coroutine ended,
+	// was cancelled or restarted.
+	CauseParentEnded
+
+	// Cancelled with the Token given to WithToken().
+	CauseToken
+
+	// Cancelled since it ran past the duration
+	// given to WithTimeout().
+	CauseTimeout
+
+	// Cancelled since a script linked
+	// with Link() was cancelled.
+	CauseLinked
+)
+
+func (cause CancelCause) String() string {
+	switch cause {
+	case CauseNone:
+		return "none"
+	case CauseExplicitCancel:
+		return "cancel"
+	case CauseRestart:
+		return "restart"
+	case CauseTransition:
+		return "transition"
+	case CauseParentEnded:
+		return "parent ended"
+	case CauseToken:
+		return "token"
+	case CauseTimeout:
+		return "timeout"
+	case CauseLinked:
+		return "linked"
+	}
+	return "unknown"
+}
+
+// Returns why the coroutine was cancelled, or CauseNone
+// if it's not cancelled. Useful in deferred cleanup, for
+// instance to keep some state when the coroutine is only
+// being restarted:
+//
+//	defer func() {
+//		if ctrl.Cause() != carrot.CauseRestart {
+//			resetPosition()
+//		}
+//	}()
+func (ctrl *Control) Cause() CancelCause {
+	return CancelCause(ctrl.cause.Load())
+}
@@ -0,0 +1,147 @@
+package carrot_test
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestCancelPolicy(t *testing.T) {
+	run := func(policy carrot.CancelPolicy, coroutine carrot.Coroutine) *carrot.Script {
+		script := carrot.Start(coroutine, carrot.WithCancelPolicy(policy))
+		for i := 0; i < 3; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+		script.Cancel()
+		for i := 0; i < 100 && !script.IsDone(); i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+		if !script.IsDone() {
+			t.Fatal("script should end", policy)
+		}
+		return script
+	}
+
+	t.Run("error", func(t *testing.T) {
+		var cleanedUp, childCancelled atomic.Bool
+		run(carrot.CancelError, func(ctrl *carrot.Control) {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				ctrl.Abyss()
+				childCancelled.Store(ctrl.IsCancelled())
+			})
+			for {
+				if err := ctrl.YieldErr(); err != nil {
+					cleanedUp.Store(err == carrot.ErrCancelled)
+					return
+				}
+			}
+		})
+		if !cleanedUp.Load() {
+			t.Error("YieldErr should return ErrCancelled")
+		}
+		if !childCancelled.Load() {
+			t.Error("Abyss should return when cancelled")
+		}
+	})
+
+	t.Run("context", func(t *testing.T) {
+		var done atomic.Bool
+		run(carrot.CancelContext, func(ctrl *carrot.Control) {
+			ctx := ctrl.Context()
+			ctrl.YieldUntil(func() bool { return false })
+			<-ctx.Done()
+			done.Store(true)
+		})
+		if !done.Load() {
+			t.Error("context should be cancelled")
+		}
+	})
+
+	t.Run("panic", func(t *testing.T) {
+		var err atomic.Value
+		var after atomic.Bool
+		run(carrot.CancelPanic, func(ctrl *carrot.Control) {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				err.Store(ctrl.SleepErr(time.Hour))
+			})
+			ctrl.Abyss()
+			after.Store(true)
+		})
+		if err.Load() != carrot.ErrCancelled {
+			t.Error("SleepErr should return ErrCancelled", err.Load())
+		}
+		if after.Load() {
+			t.Error("Abyss should panic when cancelled")
+		}
+	})
+}
+
+func TestCancelPolicyErr(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		child := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Sleep(time.Hour)
+		})
+		ctrl.Yield()
+		ctrl.Yield()
+		child.Cancel()
+		if err := child.Join(ctrl); err != carrot.ErrCancelled {
+			panic(err)
+		}
+	}, carrot.WithCancelPolicy(carrot.CancelError))
+
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !script.IsDone() {
+		t.Error("script should end")
+	}
+}
+
+func TestCancelCause(t *testing.T) {
+	var mu sync.Mutex
+	var causes []string
+	record := func(ctrl *carrot.Control) {
+		mu.Lock()
+		causes = append(causes, ctrl.Cause().String())
+		mu.Unlock()
+	}
+	child := func(ctrl *carrot.Control) {
+		defer record(ctrl)
+		ctrl.Abyss()
+	}
+	state := func(ctrl *carrot.Control) {
+		defer record(ctrl)
+		ctrl.StartAsync(child)
+		ctrl.Abyss()
+	}
+
+	script := carrot.Start(state)
+	update := func() {
+		for i := 0; i < 5; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+	update()
+	script.Restart()
+	update()
+	script.Transition(state)
+	update()
+	script.Cancel()
+	update()
+
+	mu.Lock()
+	defer mu.Unlock()
+	// children are cancelled after the parent returns
+	want := "[restart parent ended transition parent ended cancel parent ended]"
+	if fmt.Sprint(causes) != want {
+		t.Error("wrong causes", causes)
+	}
+}
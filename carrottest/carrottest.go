@@ -0,0 +1,58 @@
+// Package carrottest provides utilities for testing carrot scripts.
+package carrottest
+
+import (
+	"testing"
+
+	"github.com/nvlled/carrot"
+	"golang.org/x/exp/slices"
+)
+
+// Reports an error if the script hasn't reached
+// the given checkpoint. See ctrl.Checkpoint().
+func AssertReached(t testing.TB, script *carrot.Script, checkpoint string) {
+	t.Helper()
+	checkpoints := script.Checkpoints()
+	if !slices.Contains(checkpoints, checkpoint) {
+		t.Errorf("checkpoint %q not reached, reached checkpoints: %v", checkpoint, checkpoints)
+	}
+}
+
+// Reports an error if the script has reached
+// the given checkpoint.
+func AssertNotReached(t testing.TB, script *carrot.Script, checkpoint string) {
+	t.Helper()
+	if slices.Contains(script.Checkpoints(), checkpoint) {
+		t.Errorf("checkpoint %q should not be reached", checkpoint)
+	}
+}
+
+// Reports an error for every script that is still alive,
+// see carrot.LeakReport(). Leak tracking must be enabled
+// with carrot.TrackLeaks(true) before the scripts are created.
+//
+//	carrot.TrackLeaks(true)
+//	t.Cleanup(func() { carrottest.AssertNoLeaks(t) })
+func AssertNoLeaks(t testing.TB) {
+	t.Helper()
+	for _, leak := range carrot.LeakReport() {
+		t.Errorf("leaked %v", leak)
+	}
+}
+
+// Reports an error if the script hasn't reached
+// the given checkpoints in the same order.
+// Other checkpoints in between are ignored.
+func AssertReachedInOrder(t testing.TB, script *carrot.Script, checkpoints ...string) {
+	t.Helper()
+	reached := script.Checkpoints()
+	i := 0
+	for _, c := range reached {
+		if i < len(checkpoints) && c == checkpoints[i] {
+			i++
+		}
+	}
+	if i < len(checkpoints) {
+		t.Errorf("checkpoints %v not reached in order, reached checkpoints: %v", checkpoints, reached)
+	}
+}
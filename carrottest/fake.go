@@ -0,0 +1,375 @@
+package carrottest
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+// A FakeControl is a carrot.Ctl that runs without a script.
+// Yields return immediately, and the calls are recorded
+// so that they can be checked afterwards with Calls().
+// Sleep() advances the fake clock instead of waiting.
+// Child coroutines started with StartAsync() are
+// recorded but not run.
+//
+//	fake := carrottest.NewFakeControl()
+//	fake.Run(func(ctrl carrot.Ctl) { patrol(ctrl) })
+//	if fake.Frames() != 10 { ... }
+type FakeControl struct {
+	// Called on every fake frame, for instance to
+	// change the state checked by YieldUntil().
+	OnYield func()
+
+	// Maximum number of frames that YieldUntil() and
+	// similar methods wait before panicking.
+	// Defaults to 10000.
+	MaxFrames int
+
+	// Seed of the source returned by Rand().
+	Seed int64
+
+	mu          sync.Mutex
+	calls       []string
+	frames      int
+	now         time.Time
+	values      map[any]any
+	checkpoints []string
+	cancelled   bool
+	progress    float64
+	status      string
+	alpha       float64
+	rand        *rand.Rand
+}
+
+// Creates a new FakeControl, with the fake clock
+// starting at the current time.
+func NewFakeControl() *FakeControl {
+	return &FakeControl{
+		now:    time.Now(),
+		values: map[any]any{},
+	}
+}
+
+// Calls fn with the fake control. Returns true if fn
+// returned normally, or false if it was cancelled.
+func (fake *FakeControl) Run(fn func(carrot.Ctl)) (finished bool) {
+	defer func() {
+		if err := recover(); err != nil {
+			if err != carrot.ErrCancelled {
+				panic(err)
+			}
+			finished = false
+		}
+	}()
+	fn(fake)
+	return true
+}
+
+// Returns the recorded calls, formatted like "Sleep(1s)".
+func (fake *FakeControl) Calls() []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return append([]string(nil), fake.calls...)
+}
+
+// Returns the number of fake frames that have passed.
+func (fake *FakeControl) Frames() int {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.frames
+}
+
+// Returns the checkpoints reached with Checkpoint().
+func (fake *FakeControl) Checkpoints() []string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return append([]string(nil), fake.checkpoints...)
+}
+
+func (fake *FakeControl) Yield() {
+	fake.record("Yield()")
+	fake.nextFrame()
+}
+
+func (fake *FakeControl) MaybeYield(budget time.Duration) {
+	fake.record("MaybeYield(%v)", budget)
+	fake.nextFrame()
+}
+
+func (fake *FakeControl) Delay(count int) {
+	fake.record("Delay(%v)", count)
+	for i := 0; i < count; i++ {
+		fake.nextFrame()
+	}
+}
+
+func (fake *FakeControl) Sleep(duration time.Duration) {
+	fake.record("Sleep(%v)", duration)
+	fake.mu.Lock()
+	fake.now = fake.now.Add(duration)
+	fake.mu.Unlock()
+	fake.nextFrame()
+}
+
+// Advances the fake clock by exactly the duration,
+// so the overshoot is always zero.
+func (fake *FakeControl) SleepExact(duration time.Duration) time.Duration {
+	fake.record("SleepExact(%v)", duration)
+	fake.mu.Lock()
+	fake.now = fake.now.Add(duration)
+	fake.mu.Unlock()
+	fake.nextFrame()
+	return 0
+}
+
+func (fake *FakeControl) YieldWhile(fn func() bool) {
+	fake.record("YieldWhile()")
+	fake.waitUntil(func() bool { return !fn() })
+}
+
+func (fake *FakeControl) YieldUntil(fn func() bool) {
+	fake.record("YieldUntil()")
+	fake.waitUntil(fn)
+}
+
+func (fake *FakeControl) YieldWhileVar(value *bool) {
+	fake.record("YieldWhileVar()")
+	fake.waitUntil(func() bool { return value == nil || !*value })
+}
+
+func (fake *FakeControl) YieldUntilVar(value *bool) {
+	fake.record("YieldUntilVar()")
+	fake.waitUntil(func() bool { return value != nil && *value })
+}
+
+func (fake *FakeControl) YieldWhileAtomic(value *atomic.Bool) {
+	fake.record("YieldWhileAtomic()")
+	fake.waitUntil(func() bool { return !value.Load() })
+}
+
+func (fake *FakeControl) YieldUntilAtomic(value *atomic.Bool) {
+	fake.record("YieldUntilAtomic()")
+	fake.waitUntil(value.Load)
+}
+
+func (fake *FakeControl) YieldUntilAny(fns ...func() bool) int {
+	fake.record("YieldUntilAny()")
+	index := -1
+	fake.waitUntil(func() bool {
+		for i, fn := range fns {
+			if fn() {
+				index = i
+				return true
+			}
+		}
+		return false
+	})
+	return index
+}
+
+func (fake *FakeControl) YieldUntilAll(fns ...func() bool) {
+	fake.record("YieldUntilAll()")
+	fake.waitUntil(func() bool {
+		for _, fn := range fns {
+			if !fn() {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Abyss panics with carrot.ErrCancelled, since
+// it would never return otherwise.
+func (fake *FakeControl) Abyss() {
+	fake.record("Abyss()")
+	panic(carrot.ErrCancelled)
+}
+
+// AwaitContext runs fn immediately on the calling goroutine.
+func (fake *FakeControl) AwaitContext(fn func(ctx context.Context) error) error {
+	fake.record("AwaitContext()")
+	return fn(context.Background())
+}
+
+// AfterChan returns a channel that already holds the
+// time of the fake clock plus the duration, so
+// timeouts fire without waiting.
+func (fake *FakeControl) AfterChan(duration time.Duration) <-chan time.Time {
+	fake.record("AfterChan(%v)", duration)
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	ch := make(chan time.Time, 1)
+	ch <- fake.now.Add(duration)
+	return ch
+}
+
+// StartAsync records the call, and returns a
+// SubControl that is already done.
+// The coroutine is not run.
+func (fake *FakeControl) StartAsync(coroutine carrot.Coroutine, options ...carrot.Option) carrot.SubControl {
+	fake.record("StartAsync()")
+	return fakeSub{}
+}
+
+// Cancel causes the next yield to panic
+// with carrot.ErrCancelled.
+func (fake *FakeControl) Cancel() {
+	fake.record("Cancel()")
+	fake.mu.Lock()
+	fake.cancelled = true
+	fake.mu.Unlock()
+}
+
+func (fake *FakeControl) Restart() {
+	fake.record("Restart()")
+}
+
+func (fake *FakeControl) Transition(coroutine carrot.Coroutine) {
+	fake.record("Transition()")
+}
+
+// Returns the time of the fake clock.
+func (fake *FakeControl) Now() time.Time {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.now
+}
+
+// Returns the alpha set with SetAlpha().
+func (fake *FakeControl) Alpha() float64 {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.alpha
+}
+
+// Sets the value returned by Alpha().
+func (fake *FakeControl) SetAlpha(alpha float64) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.alpha = alpha
+}
+
+// Returns a source seeded with fake.Seed,
+// created on the first call.
+func (fake *FakeControl) Rand() *rand.Rand {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.rand == nil {
+		fake.rand = rand.New(rand.NewSource(fake.Seed))
+	}
+	return fake.rand
+}
+
+func (fake *FakeControl) Value(key any) any {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.values[key]
+}
+
+func (fake *FakeControl) SetValue(key, value any) {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	fake.values[key] = value
+}
+
+func (fake *FakeControl) Checkpoint(name string) {
+	fake.record("Checkpoint(%v)", name)
+	fake.mu.Lock()
+	fake.checkpoints = append(fake.checkpoints, name)
+	fake.mu.Unlock()
+}
+
+func (fake *FakeControl) SetProgress(progress float64) {
+	fake.record("SetProgress(%v)", progress)
+	fake.mu.Lock()
+	fake.progress = progress
+	fake.mu.Unlock()
+}
+
+func (fake *FakeControl) SetStatus(status string) {
+	fake.record("SetStatus(%v)", status)
+	fake.mu.Lock()
+	fake.status = status
+	fake.mu.Unlock()
+}
+
+// Returns the progress set with SetProgress().
+func (fake *FakeControl) Progress() float64 {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.progress
+}
+
+// Returns the status set with SetStatus().
+func (fake *FakeControl) Status() string {
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	return fake.status
+}
+
+func (fake *FakeControl) Logf(format string, args ...any) {}
+
+func (fake *FakeControl) record(format string, args ...any) {
+	fake.mu.Lock()
+	fake.calls = append(fake.calls, fmt.Sprintf(format, args...))
+	fake.mu.Unlock()
+}
+
+func (fake *FakeControl) nextFrame() {
+	fake.mu.Lock()
+	fake.frames++
+	cancelled := fake.cancelled
+	fake.mu.Unlock()
+
+	if fake.OnYield != nil {
+		fake.OnYield()
+	}
+	if cancelled {
+		panic(carrot.ErrCancelled)
+	}
+}
+
+func (fake *FakeControl) waitUntil(fn func() bool) {
+	maxFrames := fake.MaxFrames
+	if maxFrames <= 0 {
+		maxFrames = 10000
+	}
+	for i := 0; !fn(); i++ {
+		if i >= maxFrames {
+			panic(fmt.Sprintf("carrottest: condition not met after %v frames", maxFrames))
+		}
+		fake.nextFrame()
+	}
+}
+
+// A SubControl that is already done.
+type fakeSub struct{}
+
+func (fakeSub) Cancel()                       {}
+func (fakeSub) Restart()                      {}
+func (fakeSub) Transition(carrot.Coroutine)   {}
+func (fakeSub) SetUpdateDivider(int)          {}
+func (fakeSub) Pause()                        {}
+func (fakeSub) Resume()                       {}
+func (fakeSub) FastForward()                  {}
+func (fakeSub) Wake()                         {}
+func (fakeSub) Stats() carrot.Stats           { return carrot.Stats{} }
+func (fakeSub) IsRunning() bool               { return false }
+func (fakeSub) IsDone() bool                  { return true }
+func (fakeSub) WaitReason() carrot.WaitReason { return carrot.WaitNone }
+func (fakeSub) WaitLabel() string             { return "" }
+func (fakeSub) TimedOut() bool                { return false }
+func (fakeSub) Transitions() int64            { return 0 }
+func (fakeSub) Restarts() int64               { return 0 }
+func (fakeSub) Err() error                    { return nil }
+func (fakeSub) WasCancelled() bool            { return false }
+func (fakeSub) Join(*carrot.Control) error    { return nil }
+
+var _ carrot.Ctl = (*FakeControl)(nil)
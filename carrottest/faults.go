@@ -0,0 +1,121 @@
+package carrottest
+
+import (
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/nvlled/carrot"
+)
+
+// Options for InjectFaults(). Rates are the chance,
+// from 0 to 1, of injecting the fault on each yield.
+type FaultOptions struct {
+	// Seed of the random faults. If zero, the seed
+	// is taken from the environment variable CARROT_STRESS_SEED,
+	// or from the current time if it's not set.
+	Seed int64
+
+	// Chance of cancelling the yielding coroutine.
+	CancelRate float64
+
+	// Chance of restarting the yielding coroutine.
+	RestartRate float64
+
+	// Chance of delaying the yielding coroutine
+	// by extra frames.
+	DelayRate float64
+
+	// Maximum number of extra frames of a delay.
+	// Defaults to 3.
+	MaxDelay int
+}
+
+// Counts of the faults injected by InjectFaults().
+type FaultCounts struct {
+	Cancels  int
+	Restarts int
+	Delays   int
+}
+
+// Faults are the faults injected into a script.
+// See InjectFaults().
+type Faults struct {
+	options FaultOptions
+
+	// rng is shared by all coroutines of the script
+	mu  sync.Mutex
+	rng *rand.Rand
+
+	cancels  atomic.Int64
+	restarts atomic.Int64
+	delays   atomic.Int64
+}
+
+// InjectFaults randomly cancels, restarts and delays the
+// coroutines of the script at their yield points, so that the
+// cleanup code of the coroutines, such as defers and OnCancel(),
+// is exercised. Similar to Stress(), but the faults happen inside
+// the coroutines instead of on the main thread. The seed is
+// reported when the test fails.
+//
+//	script := carrot.Start(spawnEnemies)
+//	carrottest.InjectFaults(t, script, carrottest.FaultOptions{CancelRate: 0.01})
+//	for !script.IsDone() { script.Update() }
+//
+//	Note: Coroutines run concurrently, so the same seed only
+//	gives the same faults if the coroutines yield in the same order.
+func InjectFaults(t testing.TB, script *carrot.Script, options FaultOptions) *Faults {
+	t.Helper()
+	seed := resolveSeed(options.Seed)
+	if options.MaxDelay <= 0 {
+		options.MaxDelay = 3
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("faults were injected, rerun with %v=%v", SeedEnv, seed)
+		}
+	})
+
+	faults := &Faults{
+		options: options,
+		rng:     rand.New(rand.NewSource(seed)),
+	}
+	script.Use(faults.middleware)
+	return faults
+}
+
+// Returns the number of faults injected so far.
+func (faults *Faults) Counts() FaultCounts {
+	return FaultCounts{
+		Cancels:  int(faults.cancels.Load()),
+		Restarts: int(faults.restarts.Load()),
+		Delays:   int(faults.delays.Load()),
+	}
+}
+
+func (faults *Faults) middleware(next carrot.YieldFunc) carrot.YieldFunc {
+	return func(ctrl *carrot.Control, reason carrot.WaitReason) {
+		options := faults.options
+		faults.mu.Lock()
+		n := faults.rng.Float64()
+		delay := 1 + faults.rng.Intn(options.MaxDelay)
+		faults.mu.Unlock()
+
+		switch {
+		case n < options.CancelRate:
+			faults.cancels.Add(1)
+			ctrl.Cancel()
+		case n < options.CancelRate+options.RestartRate:
+			faults.restarts.Add(1)
+			ctrl.Restart()
+		case n < options.CancelRate+options.RestartRate+options.DelayRate:
+			faults.delays.Add(1)
+			for i := 0; i < delay; i++ {
+				next(ctrl, carrot.WaitFrame)
+			}
+		}
+		next(ctrl, reason)
+	}
+}
@@ -0,0 +1,91 @@
+package carrottest
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+// The environment variable that overrides the seed used in
+// Stress(), used to reproduce a failed stress test.
+const SeedEnv = "CARROT_STRESS_SEED"
+
+// Options for Stress().
+type StressOptions struct {
+	// Seed of the random interleavings. If zero, the seed
+	// is taken from the environment variable CARROT_STRESS_SEED,
+	// or from the current time if it's not set.
+	Seed int64
+
+	// Number of operations to do. Defaults to 1000.
+	Steps int
+
+	// Coroutines used for random transitions. If empty,
+	// no transitions will be done.
+	Transitions []carrot.Coroutine
+}
+
+// Stress randomly interleaves calls to Update(), Cancel(),
+// Restart() and Transition() on the script, similar to what
+// the TestTransition* tests do, but with seeded and reproducible
+// operations. The seed is reported when the test fails, so that it
+// can be rerun with the same seed using CARROT_STRESS_SEED.
+//
+// The script is made serialized with script.SetSerialized(),
+// so its coroutines run one at a time and have all yielded
+// by the time Update() returns. The same seed then gives the
+// same interleaving of the operations with the coroutines,
+// as long as the coroutines don't depend on the real time,
+// for instance by using Delay() instead of Sleep().
+func Stress(t testing.TB, script *carrot.Script, options StressOptions) {
+	t.Helper()
+	script.SetSerialized(true)
+	seed := resolveSeed(options.Seed)
+	steps := options.Steps
+	if steps <= 0 {
+		steps = 1000
+	}
+
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("stress test failed, rerun with %v=%v", SeedEnv, seed)
+		}
+	})
+	defer func() {
+		if err := recover(); err != nil {
+			t.Fatalf("stress test panicked: %v, rerun with %v=%v", err, SeedEnv, seed)
+		}
+	}()
+
+	rng := rand.New(rand.NewSource(seed))
+	for i := 0; i < steps; i++ {
+		switch n := rng.Intn(10); {
+		case n < 6:
+			script.Update()
+		case n < 7:
+			script.Cancel()
+		case n < 8:
+			script.Restart()
+		case len(options.Transitions) > 0:
+			script.Transition(options.Transitions[rng.Intn(len(options.Transitions))])
+		default:
+			script.Update()
+		}
+	}
+}
+
+// Returns seed if it's not zero, otherwise the seed from
+// CARROT_STRESS_SEED, or the current time if it's not set.
+func resolveSeed(seed int64) int64 {
+	if seed != 0 {
+		return seed
+	}
+	if s, err := strconv.ParseInt(os.Getenv(SeedEnv), 10, 64); err == nil {
+		return s
+	}
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,91 @@
+package carrottest
+
+import (
+	"testing"
+
+	"github.com/nvlled/carrot"
+)
+
+// Synthetic workloads for benchmarks. Each one keeps its
+// coroutines alive and resumes all of them on every
+// Update(), so that the cost per update stays the same
+// over the whole benchmark.
+
+// A coroutine that yields on every frame, forever.
+func Spin(ctrl *carrot.Control) {
+	for {
+		ctrl.Yield()
+	}
+}
+
+// Returns a coroutine that starts a chain of child
+// coroutines, each one nested in the previous, depth
+// levels deep. Every level yields on every frame.
+func Nested(depth int) carrot.Coroutine {
+	return func(ctrl *carrot.Control) {
+		if depth > 1 {
+			ctrl.StartAsync(Nested(depth - 1))
+		}
+		Spin(ctrl)
+	}
+}
+
+// Returns a coroutine that starts width child coroutines
+// that yield on every frame.
+func FanOut(width int) carrot.Coroutine {
+	return func(ctrl *carrot.Control) {
+		for i := 0; i < width; i++ {
+			ctrl.StartAsync(Spin)
+		}
+		Spin(ctrl)
+	}
+}
+
+// A coroutine that starts a child and transitions to
+// itself on every frame, so that every update cancels
+// the coroutine and its child and starts them again.
+func Churn(ctrl *carrot.Control) {
+	ctrl.StartAsync(Spin)
+	ctrl.Yield()
+	ctrl.Transition(Churn)
+	ctrl.Yield()
+}
+
+// Number of updates done before the timer is started,
+// so that coroutines have started and the pool is warm.
+const benchWarmup = 3
+
+// Benchmarks script.Update(). Allocations are reported,
+// and the names of sub-benchmarks are left to the caller,
+// so the output can be compared with tools like benchstat.
+func BenchUpdate(b *testing.B, script *carrot.Script) {
+	b.Helper()
+	for i := 0; i < benchWarmup; i++ {
+		script.Update()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		script.Update()
+	}
+	b.StopTimer()
+	script.Close()
+}
+
+// Same as BenchUpdate(), but for manager.Update().
+// The scripts of the manager are closed at the end.
+func BenchManager(b *testing.B, manager *carrot.Manager, scripts []*carrot.Script) {
+	b.Helper()
+	for i := 0; i < benchWarmup; i++ {
+		manager.Update()
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		manager.Update()
+	}
+	b.StopTimer()
+	for _, script := range scripts {
+		script.Close()
+	}
+}
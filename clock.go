@@ -0,0 +1,17 @@
+package carrot
+
+import "time"
+
+// A Clock provides the current time for the time-based
+// methods of a script, such as Sleep().
+// See WithClock() and script.Advance().
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// The clock that is used when no clock is set with WithClock().
+var SystemClock Clock = systemClock{}
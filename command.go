@@ -0,0 +1,140 @@
+package carrot
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// A CommandFunc runs a console command with the words
+// that follow its name, and returns its output.
+// See RegisterCommand().
+type CommandFunc func(args []string) (string, error)
+
+// Returned by ExecCommand() for a command that
+// is not registered, wrapped with the name.
+var ErrUnknownCommand = errors.New("unknown command")
+
+var commands = struct {
+	mu  sync.RWMutex
+	fns map[string]CommandFunc
+}{fns: map[string]CommandFunc{}}
+
+func init() {
+	RegisterCommand("list", listCommand)
+	RegisterCommand("pause", scriptCommand(func(script *Script) { script.Pause() }))
+	RegisterCommand("resume", scriptCommand(func(script *Script) { script.Resume() }))
+	RegisterCommand("cancel", scriptCommand(func(script *Script) { script.Cancel() }))
+	RegisterCommand("restart", scriptCommand(func(script *Script) { script.Restart() }))
+	RegisterCommand("inspect", inspectCommand)
+}
+
+// Registers a command for ExecCommand(), replacing any
+// command with the same name. The following commands,
+// which find scripts by the names given with WithName(),
+// are registered by default:
+//
+//	list                lists the names of the running scripts
+//	pause <name>...     pauses the scripts
+//	resume <name>...    resumes the scripts
+//	cancel <name>...    cancels the scripts
+//	restart <name>...   restarts the scripts
+//	inspect <name>      shows the state of the script
+func RegisterCommand(name string, fn CommandFunc) {
+	commands.mu.Lock()
+	defer commands.mu.Unlock()
+	commands.fns[name] = fn
+}
+
+// Runs a command line, such as "restart boss", from an in-game
+// developer console. The line is split into words, the first one
+// being the name of the command given to RegisterCommand().
+// Returns the output of the command, or an error wrapping
+// ErrUnknownCommand if there is no such command.
+// Can be called from any thread.
+func ExecCommand(line string) (string, error) {
+	words := strings.Fields(line)
+	if len(words) == 0 {
+		return "", nil
+	}
+	commands.mu.RLock()
+	fn, ok := commands.fns[words[0]]
+	commands.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w %q", ErrUnknownCommand, words[0])
+	}
+	return fn(words[1:])
+}
+
+// Returns the names of the registered
+// commands, in sorted order.
+func CommandNames() []string {
+	commands.mu.RLock()
+	defer commands.mu.RUnlock()
+	names := make([]string, 0, len(commands.fns))
+	for name := range commands.fns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func lookupArgs(args []string) ([]*Script, error) {
+	if len(args) == 0 {
+		return nil, errors.New("missing script name")
+	}
+	scripts := make([]*Script, len(args))
+	for i, name := range args {
+		if scripts[i] = Lookup(name); scripts[i] == nil {
+			return nil, fmt.Errorf("no script named %q", name)
+		}
+	}
+	return scripts, nil
+}
+
+func scriptCommand(fn func(*Script)) CommandFunc {
+	return func(args []string) (string, error) {
+		scripts, err := lookupArgs(args)
+		if err != nil {
+			return "", err
+		}
+		for _, script := range scripts {
+			fn(script)
+		}
+		return "", nil
+	}
+}
+
+func listCommand(args []string) (string, error) {
+	return strings.Join(RegisteredNames(), "\n"), nil
+}
+
+func inspectCommand(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("inspect takes one script name")
+	}
+	scripts, err := lookupArgs(args)
+	if err != nil {
+		return "", err
+	}
+	script := scripts[0]
+	ctrl := script.baseControl
+	info := ctrl.info()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v (id %v): %v, running %v", script.Name(), info.ID, info.State, info.Name)
+	if info.WaitReason != WaitNone {
+		fmt.Fprintf(&b, "\nwaiting on %v", info.WaitReason)
+		if info.WaitLabel != "" {
+			fmt.Fprintf(&b, " %q", info.WaitLabel)
+		}
+	}
+	if status := script.Status(); status != "" {
+		fmt.Fprintf(&b, "\nstatus %q, progress %.0f%%", status, script.Progress()*100)
+	}
+	stats := script.Stats()
+	fmt.Fprintf(&b, "\n%v resumes, %v restarts, %v children",
+		stats.Resumes, stats.Restarts, info.Children)
+	return b.String(), nil
+}
@@ -0,0 +1,61 @@
+package carrot_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestExecCommand(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.SetStatus("idling")
+		ctrl.YieldNamed("player")
+		ctrl.Abyss()
+	}, carrot.WithName("test-console-boss"))
+	update := func() {
+		for i := 0; i < 3; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+	update()
+
+	out, err := carrot.ExecCommand("list")
+	if err != nil || !strings.Contains(out, "test-console-boss") {
+		t.Error("expected the script in the list, got", out, err)
+	}
+	out, err = carrot.ExecCommand("inspect test-console-boss")
+	if err != nil || !strings.Contains(out, "forever") || !strings.Contains(out, `"idling"`) {
+		t.Error("unexpected inspect output", out, err)
+	}
+	if _, err := carrot.ExecCommand("pause test-console-boss"); err != nil || !script.IsPaused() {
+		t.Error("the script should be paused", err)
+	}
+	if _, err := carrot.ExecCommand("resume test-console-boss"); err != nil || script.IsPaused() {
+		t.Error("the script should be resumed", err)
+	}
+	if _, err := carrot.ExecCommand("cancel nobody"); err == nil {
+		t.Error("expected an error for an unknown script")
+	}
+	if _, err := carrot.ExecCommand("explode test-console-boss"); !errors.Is(err, carrot.ErrUnknownCommand) {
+		t.Error("expected ErrUnknownCommand, got", err)
+	}
+
+	carrot.RegisterCommand("test-echo", func(args []string) (string, error) {
+		return strings.Join(args, ","), nil
+	})
+	if out, _ := carrot.ExecCommand("test-echo a  b"); out != "a,b" {
+		t.Error("expected the custom command to run, got", out)
+	}
+
+	if _, err := carrot.ExecCommand("cancel test-console-boss"); err != nil {
+		t.Error(err)
+	}
+	update()
+	if !script.IsDone() {
+		t.Error("the script should be cancelled")
+	}
+}
@@ -2,6 +2,7 @@ package carrot
 
 import (
 	"errors"
+	"fmt"
 )
 
 // The error that is thrown while waiting on
@@ -10,8 +11,20 @@ import (
 // when cancelled.
 // No need to explicitly handle and recover from
 // this error inside a coroutine.
+// See WithCancelPolicy() for scripts that
+// don't use panics for cancellation.
 var ErrCancelled = errors.New("coroutine has been cancelled")
 
+// A PanicError is the error reported when
+// a coroutine panics.
+type PanicError struct {
+	Value any
+}
+
+func (err *PanicError) Error() string {
+	return fmt.Sprintf("coroutine panicked: %v", err.Value)
+}
+
 // A type representing none.
 // Used on tasks that doesn't return
 // value: Task[void]
@@ -20,9 +33,3 @@ type void struct{}
 // That value that represents nothing.
 // Similar to nil, but safer.
 var none = void{}
-
-func catchCancellation() {
-	if err := recover(); err != nil && err != ErrCancelled {
-		panic(err)
-	}
-}
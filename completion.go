@@ -0,0 +1,49 @@
+package carrot
+
+import "sync/atomic"
+
+// A Completion is completed once by the engine, for instance
+// from an "animation finished" or "sound ended" callback, and
+// waited on by coroutines. This standardizes waiting on the
+// callbacks of engine-side systems instead of each project
+// wiring its own flags.
+//
+//	done := carrot.NewCompletion()
+//	sprite.PlayAnimation("attack", done.Complete)
+//	done.Wait(ctrl)
+type Completion struct {
+	completed atomic.Bool
+}
+
+// Creates a new completion that is not completed yet.
+func NewCompletion() *Completion {
+	return &Completion{}
+}
+
+// Marks the completion as completed, releasing the coroutines
+// waiting on it on their next update. Calling Complete()
+// more than once has no effect. Can be called from any thread.
+func (c *Completion) Complete() {
+	c.completed.Store(true)
+}
+
+// Returns true if Complete() has been called.
+func (c *Completion) IsComplete() bool {
+	return c.completed.Load()
+}
+
+// Yields until the completion is completed. Returns right away if
+// it already is. Returns false if the coroutine was cancelled
+// before that, true otherwise.
+// Panics when cancelled, unless the cancel policy
+// is set to something other than CancelPanic.
+func (c *Completion) Wait(ctrl *Control) bool {
+	ctrl.beginWait()
+	for !c.completed.Load() {
+		if ctrl.cancelReturns() {
+			return false
+		}
+		ctrl.yield(WaitCondition)
+	}
+	return true
+}
@@ -0,0 +1,34 @@
+package carrot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+	"github.com/nvlled/carrot/carrottest"
+)
+
+func TestCompletion(t *testing.T) {
+	done := carrot.NewCompletion()
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		done.Wait(ctrl)
+		ctrl.Checkpoint("animation done")
+		done.Wait(ctrl)
+		ctrl.Checkpoint("waited again")
+	})
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	carrottest.AssertNotReached(t, script, "animation done")
+	done.Complete()
+	done.Complete()
+	for i := 0; i < 2; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	carrottest.AssertReached(t, script, "waited again")
+	if !done.IsComplete() {
+		t.Error("should be complete")
+	}
+}
@@ -0,0 +1,96 @@
+package carrot
+
+import (
+	"fmt"
+	"time"
+)
+
+// A Cond is a reusable wait condition that can be
+// composed with And(), Or() and Not(), and waited on
+// with ctrl.YieldOn(). Conds are immutable, the methods
+// return a new Cond.
+//
+//	canAttack := carrot.When(enemyInRange).Named("in range").
+//		And(carrot.When(hasAmmo).Named("has ammo")).
+//		WithTimeout(5 * time.Second)
+//	if ctrl.YieldOn(canAttack) { ... }
+type Cond struct {
+	name  string
+	check func(ctrl *Control, wait *condWait) bool
+}
+
+// state of one ctrl.YieldOn() call
+type condWait struct {
+	start    time.Time
+	timedOut bool
+}
+
+// Creates a condition that is true when fn returns true.
+func When(fn func() bool) *Cond {
+	return &Cond{
+		name:  "condition",
+		check: func(*Control, *condWait) bool { return fn() },
+	}
+}
+
+// Returns a copy of the condition with the given name,
+// used for debugging. See ctrl.WaitCond().
+func (cond *Cond) Named(name string) *Cond {
+	return &Cond{name: name, check: cond.check}
+}
+
+// Returns a condition that is true when both
+// conditions are true.
+func (cond *Cond) And(other *Cond) *Cond {
+	return &Cond{
+		name: fmt.Sprintf("(%v and %v)", cond, other),
+		check: func(ctrl *Control, wait *condWait) bool {
+			return cond.check(ctrl, wait) && other.check(ctrl, wait)
+		},
+	}
+}
+
+// Returns a condition that is true when either
+// of the conditions is true.
+func (cond *Cond) Or(other *Cond) *Cond {
+	return &Cond{
+		name: fmt.Sprintf("(%v or %v)", cond, other),
+		check: func(ctrl *Control, wait *condWait) bool {
+			return cond.check(ctrl, wait) || other.check(ctrl, wait)
+		},
+	}
+}
+
+// Returns a condition that is true when the
+// condition is false.
+func (cond *Cond) Not() *Cond {
+	return &Cond{
+		name: fmt.Sprintf("not %v", cond),
+		check: func(ctrl *Control, wait *condWait) bool {
+			return !cond.check(ctrl, wait)
+		},
+	}
+}
+
+// Returns a condition that is also true once the duration
+// has passed since ctrl.YieldOn() started waiting.
+// The time is measured with ctrl.Now().
+func (cond *Cond) WithTimeout(duration time.Duration) *Cond {
+	return &Cond{
+		name: fmt.Sprintf("%v (timeout %v)", cond, duration),
+		check: func(ctrl *Control, wait *condWait) bool {
+			if cond.check(ctrl, wait) {
+				return true
+			}
+			if ctrl.Now().Sub(wait.start) >= duration {
+				wait.timedOut = true
+				return true
+			}
+			return false
+		},
+	}
+}
+
+func (cond *Cond) String() string {
+	return cond.name
+}
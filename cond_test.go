@@ -0,0 +1,55 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestCond(t *testing.T) {
+	var inRange, hasAmmo atomic.Bool
+	cond := carrot.When(inRange.Load).Named("in range").
+		And(carrot.When(hasAmmo.Load).Named("has ammo").Not().Not())
+
+	if cond.String() != "(in range and not not has ammo)" {
+		t.Error("wrong name", cond)
+	}
+
+	var met, timedOut bool
+	var waitCond atomic.Pointer[carrot.Cond]
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			met = ctrl.YieldOn(cond)
+		})
+		ctrl.YieldUntil(func() bool { return sub.WaitReason() == carrot.WaitCondition })
+		waitCond.Store(sub.(*carrot.Control).WaitCond())
+		sub.Join(ctrl)
+
+		timedOut = !ctrl.YieldOn(carrot.When(func() bool { return false }).WithTimeout(time.Second))
+	}, carrot.WithClock(fixedClock{now: time.Unix(0, 0)}))
+
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	inRange.Store(true)
+	hasAmmo.Store(true)
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !met {
+		t.Error("condition should be met")
+	}
+	if waitCond.Load() != cond {
+		t.Error("wrong wait condition", waitCond.Load())
+	}
+
+	script.Advance(time.Second, time.Second/2)
+	time.Sleep(updateDelay)
+	if !script.IsDone() || !timedOut {
+		t.Error("wait should time out")
+	}
+}
@@ -1,12 +1,16 @@
 package carrot
 
 import (
+	"context"
 	"fmt"
+	"math"
+	"math/rand"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	bits "github.com/nvlled/carrot/atombits"
+	"golang.org/x/exp/slices"
 )
 
 // An Control is used to direct the program flow of a coroutine.
@@ -19,7 +23,8 @@ import (
 //	Note: Control methods should be only called within a coroutine
 //	since yield methods will panic with ErrCancelled when cancelled.
 //	This error will automatically be handled inside a coroutine,
-//	no need to try to recover from this.
+//	no need to try to recover from this. See WithCancelPolicy()
+//	for other ways to handle cancellation.
 type Control struct {
 	// ID of invoker. Mainly used for debugging.
 	ID int64
@@ -29,12 +34,231 @@ type Control struct {
 	state  atomic.Uint32
 	action atomic.Uint32
 
+	// ID of the goroutine that runs the coroutine, and
+	// whether the coroutine is running and not waiting on
+	// a yield. Used to detect Update() called from the coroutine.
+	gid    atomic.Int64
+	active atomic.Bool
+
+	// why the coroutine is being cancelled
+	cause atomic.Uint32
+
+	// only written by the main thread under historyMu, so
+	// other threads must hold historyMu to read it
 	coroutine Coroutine
+	// set by Transition(), swapped in on the next restart
+	nextCoroutine Coroutine
+
+	// previous coroutines replaced by Transition()
+	history   []Coroutine
+	historyMu sync.Mutex
+
+	parent *Control
 
 	subControls   []*Control
 	subControlsMu sync.RWMutex
 
+	// held while the subs are being updated or freed, only
+	// by the main thread and by the coroutine's own teardown,
+	// never by StartAsync() since the subs being updated may
+	// start siblings on their ancestors
+	subUpdateMu sync.Mutex
+
+	// copy of subControls being updated
 	tempSubControls []*Control
+	// finished subs, freed once they are
+	// removed from subControls
+	doneSubControls []*Control
+	// set while holding the parent's subUpdateMu
+	// on a sub that is about to be removed
+	removing bool
+
+	// number of subs started, used to order the subs
+	// by creation when they are cancelled
+	subCount   int64
+	startOrder int64
+
+	sequentialTeardown atomic.Bool
+
+	// set when restarted while the cancelled coroutine
+	// is still waiting for its subs to end
+	restartPending atomic.Bool
+
+	// set by RestartKeepChildren()
+	keepChildren atomic.Bool
+	// set by the KeepOnRestart() option
+	keepOnRestart bool
+
+	// time when the coroutine was last resumed,
+	// only accessed inside the coroutine
+	resumedAt time.Time
+
+	// ideal end time of the last SleepExact(), and the
+	// resume it returned in, only accessed inside the coroutine
+	exactEnd     time.Time
+	exactResumed time.Time
+
+	updateDivider atomic.Int32
+	updateCount   int
+
+	priority    atomic.Int64
+	frameBudget atomic.Int64
+
+	paused atomic.Bool
+
+	// closed by script.Close() to end the loopRunner
+	quit chan void
+
+	// closed once the loopRunner goroutine has started
+	ready chan void
+
+	// the parent that allocated the control from the pool,
+	// nil while it's in the pool. Guards against freeing it
+	// twice, or by a parent that no longer owns it.
+	owner atomic.Pointer[Control]
+	// set if the control was allocated from syncControlPool
+	syncEntry *syncPoolEntry
+
+	// set when the coroutine is resumed to be started,
+	// so that a resume meant for a coroutine that
+	// already ended doesn't start it again
+	startRequested atomic.Bool
+
+	// incremented on FastForward()
+	skipCount atomic.Int64
+
+	// set by Wake(), used up by AbyssUntilWoken()
+	woken atomic.Bool
+
+	stats controlStats
+
+	waitReason atomic.Uint32
+	// the condition of the current YieldOn()
+	waitCond atomic.Pointer[Cond]
+	// set by YieldNamed() and the like
+	waitLabel atomic.Pointer[string]
+	// end of the current Sleep(), in unix nanoseconds
+	sleepUntil atomic.Int64
+	// skipMark() when the current Sleep() started
+	sleepMark atomic.Int64
+
+	// how the coroutine ended, only valid when ended is true
+	err   error
+	ended bool
+	errMu sync.Mutex
+
+	// incremented every time the control is allocated
+	// from the pool, with the error of the previous allocation
+	// kept so that Join() works even after the control is reused
+	generation atomic.Uint64
+	prevErr    error
+
+	values   map[any]any
+	valuesMu sync.RWMutex
+
+	tags   []string
+	tagsMu sync.RWMutex
+
+	// timers created with AfterChan(), stopped
+	// when the coroutine ends
+	timers   []afterTimer
+	timersMu sync.Mutex
+
+	// cancelled when the coroutine is cancelled or ends,
+	// created on the first call to Context()
+	ctx       context.Context
+	ctxCancel context.CancelFunc
+	ctxMu     sync.Mutex
+	// set by WithTracer(), span and spanCtx
+	// are guarded by ctxMu
+	tracer   Tracer
+	traceCtx context.Context
+	span     Span
+	spanCtx  context.Context
+
+	// float64 bits of the progress
+	progress atomic.Uint64
+	status   string
+	statusMu sync.Mutex
+
+	// only used on the root control
+	checkpoints   []string
+	checkpointsMu sync.Mutex
+	clock         Clock
+	clockOffset   atomic.Int64
+	// clock time when the script was paused, see freezeClock()
+	frozenAt      atomic.Int64
+	frozen        atomic.Uint32
+	freezeMu      sync.Mutex
+	ids           *IDSource
+	middlewares   []func(YieldFunc) YieldFunc
+	middlewaresMu sync.Mutex
+	yieldFn       atomic.Pointer[YieldFunc]
+	breakID       atomic.Int64
+	breakSteps    atomic.Int32
+	calls         []func()
+	callsMu       sync.Mutex
+	cancelPolicy  CancelPolicy
+	background    atomic.Bool
+	serialized    atomic.Bool
+	shard         string
+	// set while the script is in a manager
+	shardMu atomic.Pointer[sync.Mutex]
+	// the shard lock held by the coroutine, only
+	// used by the coroutine's own goroutine
+	heldShard *sync.Mutex
+	syncPool  bool
+	// set by WithSeed() for the script, derived
+	// from the parent's for children
+	seed int64
+	rand *rand.Rand
+	// number of Transition() and TransitionBack() calls,
+	// see YieldUntilTransition()
+	transitions atomic.Int64
+	// set by WithTimeout(), timeoutAt is in
+	// unix nanoseconds of the script's clock
+	timeout   time.Duration
+	timeoutAt atomic.Int64
+	timedOut  atomic.Bool
+	// see PostInput(), pending events are delivered
+	// to the queue on the next update
+	inputMu      sync.Mutex
+	inputPending []any
+	inputQueue   []any
+	// set by WithName()
+	scriptName string
+	// set by Validate(), forceWait ends the current wait
+	// as if it was cancelled, until the next beginWait()
+	validation *validation
+	forceWait  atomic.Bool
+	// set by WithStallReport()
+	stallFrames int
+	stallFn     func(Stall)
+	// set by WithLogger()
+	logger    Logger
+	logLevel  LogLevel
+	hasLogger bool
+	// set by WithHistory()
+	historyLog *historyLog
+	// number of updates of the script, only counted
+	// with WithHistory() or WithRestartGuard()
+	frame atomic.Int64
+	// number of starts, including restarts
+	starts atomic.Int64
+	// set by WithRestartGuard(), restartFrames has
+	// the frames of the recent restarts
+	restartLimit  int
+	restartWindow int
+	restartFn     func(RestartLoop)
+	restartFrames []int64
+	// loop to panic with once the update is done,
+	// only set on the root when restartFn is nil
+	restartLoop *RestartLoop
+	// unix nanoseconds, set during UpdateDeadline()
+	deadline atomic.Int64
+	overrun  *DeadlineError
+	// float64 bits of the interpolation alpha
+	alpha atomic.Uint64
 }
 
 // A SubControl is a limited Control
@@ -48,8 +272,22 @@ type SubControl interface {
 	Cancel()
 	Restart()
 	Transition(Coroutine)
+	SetUpdateDivider(int)
+	Pause()
+	Resume()
+	FastForward()
+	Wake()
+	Stats() Stats
 	IsRunning() bool
 	IsDone() bool
+	WaitReason() WaitReason
+	WaitLabel() string
+	TimedOut() bool
+	Transitions() int64
+	Restarts() int64
+	Err() error
+	WasCancelled() bool
+	Join(*Control) error
 }
 
 // A Coroutine is function that only takes an *Control argument.
@@ -74,10 +312,15 @@ const (
 
 var idGen = atomic.Int64{}
 
+// number of coroutines kept for TransitionBack()
+const maxTransitionHistory = 16
+
 func NewControl() *Control {
 	ctrl := &Control{
 		ID:     idGen.Add(1),
 		kanata: newKatana(),
+		quit:   make(chan void),
+		ready:  make(chan void),
 	}
 	go ctrl.loopRunner()
 	return ctrl
@@ -87,73 +330,348 @@ func NewControl() *Control {
 // In other words, Yield() waits for one frame.
 // Panics when cancelled.
 func (ctrl *Control) Yield() {
-	ctrl.kanata.YieldRight()
-	if ctrl.isCanceled() {
-		panic(ErrCancelled)
+	ctrl.yield(WaitFrame)
+}
+
+// MaybeYield yields only if the coroutine has been running
+// longer than the budget since it was last resumed.
+// Useful for spreading heavy computations across frames
+// without yielding on every iteration.
+// Panics when cancelled.
+func (ctrl *Control) MaybeYield(budget time.Duration) {
+	if time.Since(ctrl.resumedAt) >= budget {
+		ctrl.Yield()
 	}
 }
 
 // Delay waits for a number of calls to Update().
+// Returns early if FastForward() is called.
 // Panics when cancelled.
 func (ctrl *Control) Delay(count int) {
-	for i := 0; i < count; i++ {
+	ctrl.beginWait()
+	mark := ctrl.skipMark()
+	for i := 0; i < count && ctrl.skipMark() == mark && !ctrl.cancelReturns(); i++ {
 		ctrl.Yield()
 	}
 }
 
+// Calls fn every n frames until it returns false, yielding
+// in between. The first call happens after offset frames,
+// counted from when EveryFrames() was called, so that
+// periodic work of many scripts can be staggered across
+// frames by giving each a different offset:
+//
+//	ctrl.EveryFrames(10, int(entity.ID), func() bool {
+//		entity.updatePath()
+//		return entity.alive
+//	})
+//
+// The offset is taken modulo n. A value of n <= 1 calls
+// fn every frame. Panics when cancelled.
+func (ctrl *Control) EveryFrames(n, offset int, fn func() bool) {
+	ctrl.beginWait()
+	if n < 1 {
+		n = 1
+	}
+	offset %= n
+	if offset < 0 {
+		offset += n
+	}
+	for i := 0; !ctrl.cancelReturns(); i++ {
+		if i%n == offset && !fn() {
+			return
+		}
+		ctrl.yield(WaitFrame)
+	}
+}
+
 // Sleep blocks and waits for the given duration.
+// Returns early if FastForward() is called.
+// The coroutine is not resumed on Update() while sleeping,
+// but its child coroutines are.
 //
 //	Note: Actual sleep duration might be off by several milliseconds,
 //	depending on your update FPS. Minimum sleep duration will be
 //	the frame duration.
 func (ctrl *Control) Sleep(sleepDuration time.Duration) {
+	ctrl.beginWait()
 	// time.Sleep isn't used here to allow immediate cancellation
-	startTime := time.Now()
+	startTime := ctrl.Now()
+	mark := ctrl.skipMark()
+	ctrl.sleepUntil.Store(startTime.Add(sleepDuration).UnixNano())
+	ctrl.sleepMark.Store(mark)
 	for {
-		ctrl.Yield()
-		elapsed := time.Since(startTime)
+		ctrl.yield(WaitSleep)
+		if ctrl.skipMark() != mark || ctrl.cancelReturns() {
+			break
+		}
+		elapsed := ctrl.Now().Sub(startTime)
 		if elapsed.Microseconds() >= sleepDuration.Microseconds() {
 			break
 		}
 	}
 }
 
+// Like Sleep(), but accounts for the sleep ending on a frame
+// boundary. Returns the overshoot, which is how much later
+// than the requested duration the sleep ended. The returned
+// value is negative if the sleep was cut short by a
+// cancellation or FastForward().
+//
+// When called again before the coroutine yields, the next
+// sleep is measured from when the previous one should have
+// ended instead of when it actually ended, so that the
+// overshoot doesn't accumulate over a sequence of sleeps:
+//
+//	for {
+//		ctrl.SleepExact(100 * time.Millisecond)
+//		spawnEnemy() // spawns every 100ms on average
+//	}
+func (ctrl *Control) SleepExact(sleepDuration time.Duration) time.Duration {
+	start := ctrl.Now()
+	if !ctrl.exactEnd.IsZero() && ctrl.exactResumed.Equal(ctrl.resumedAt) {
+		start = ctrl.exactEnd
+	}
+	target := start.Add(sleepDuration)
+	if remaining := target.Sub(ctrl.Now()); remaining > 0 {
+		ctrl.Sleep(remaining)
+	}
+
+	overshoot := ctrl.Now().Sub(target)
+	if overshoot < 0 {
+		ctrl.exactEnd = time.Time{}
+	} else {
+		ctrl.exactEnd = target
+		ctrl.exactResumed = ctrl.resumedAt
+	}
+	return overshoot
+}
+
 // Repeatedly yields, and stops when *value is false or nil.
+//
+//	Note: Use YieldWhileAtomic() instead if the value
+//	is changed from another goroutine.
 func (ctrl *Control) YieldWhileVar(value *bool) {
-	for value != nil && *value {
-		ctrl.Yield()
+	ctrl.beginWait()
+	for value != nil && *value && !ctrl.cancelReturns() {
+		ctrl.yield(WaitCondition)
 	}
 }
 
 // Repeatedly yields, and stops when fn returns false.
 func (ctrl *Control) YieldWhile(fn func() bool) {
-	for fn() {
-		ctrl.Yield()
+	ctrl.beginWait()
+	for fn() && !ctrl.cancelReturns() {
+		ctrl.yield(WaitCondition)
 	}
 }
 
 // Repeatedly yields, and stops when *value is true.
 // Similar to While(), but with the condition negated.
+//
+//	Note: Use YieldUntilAtomic() instead if the value
+//	is changed from another goroutine.
 func (ctrl *Control) YieldUntilVar(value *bool) {
-	for value == nil || !*value {
-		ctrl.Yield()
+	ctrl.beginWait()
+	for (value == nil || !*value) && !ctrl.cancelReturns() {
+		ctrl.yield(WaitCondition)
 	}
 }
 
 // Repeatedly yields, and stops when fn returns true.
 // Similar to WhileFunc(), but with the condition negated.
 func (ctrl *Control) YieldUntil(fn func() bool) {
-	for !fn() {
-		ctrl.Yield()
+	ctrl.beginWait()
+	for !fn() && !ctrl.cancelReturns() {
+		ctrl.yield(WaitCondition)
+	}
+}
+
+// Repeatedly yields, and stops when value is false.
+func (ctrl *Control) YieldWhileAtomic(value *atomic.Bool) {
+	ctrl.beginWait()
+	for value.Load() && !ctrl.cancelReturns() {
+		ctrl.yield(WaitCondition)
+	}
+}
+
+// Repeatedly yields, and stops when value is true.
+func (ctrl *Control) YieldUntilAtomic(value *atomic.Bool) {
+	ctrl.beginWait()
+	for !value.Load() && !ctrl.cancelReturns() {
+		ctrl.yield(WaitCondition)
+	}
+}
+
+// Repeatedly yields, and stops when load returns
+// a value equal to want. load is usually the Load
+// method of an atomic value, for instance
+//
+//	carrot.YieldUntilEqual(ctrl, state.Load, stateReady)
+//
+// Panics when cancelled.
+func YieldUntilEqual[T comparable](ctrl *Control, load func() T, want T) {
+	ctrl.beginWait()
+	for load() != want && !ctrl.cancelReturns() {
+		ctrl.yield(WaitCondition)
+	}
+}
+
+// Repeatedly yields, and stops when the condition is true.
+// Returns false if the wait ended due to a timeout set
+// with cond.WithTimeout(), true otherwise.
+// While waiting, the condition can be inspected with WaitCond().
+// Panics when cancelled.
+func (ctrl *Control) YieldOn(cond *Cond) bool {
+	ctrl.beginWait()
+	wait := &condWait{start: ctrl.Now()}
+	ctrl.waitCond.Store(cond)
+	defer ctrl.waitCond.Store(nil)
+	for !cond.check(ctrl, wait) && !ctrl.cancelReturns() {
+		ctrl.yield(WaitCondition)
+	}
+	return !wait.timedOut
+}
+
+// Repeatedly yields, and stops when any of the functions
+// returns true. Returns the index of the first function
+// that returned true, or -1 when cancelled with
+// a policy other than CancelPanic.
+// Panics when cancelled.
+func (ctrl *Control) YieldUntilAny(fns ...func() bool) int {
+	ctrl.beginWait()
+	for {
+		for i, fn := range fns {
+			if fn() {
+				return i
+			}
+		}
+		if ctrl.cancelReturns() {
+			return -1
+		}
+		ctrl.yield(WaitCondition)
 	}
 }
 
+// Repeatedly yields, and stops when all of the functions
+// return true in the same frame.
+// Panics when cancelled.
+func (ctrl *Control) YieldUntilAll(fns ...func() bool) {
+	ctrl.YieldUntil(func() bool {
+		for _, fn := range fns {
+			if !fn() {
+				return false
+			}
+		}
+		return true
+	})
+}
+
 // Causes the coroutine to block indefinitely and
 // spiral downwards the endless depths of nothingness, never
 // again to return from the utter blackness of empty void.
 func (ctrl *Control) Abyss() {
-	for {
-		ctrl.Yield()
+	ctrl.beginWait()
+	for !ctrl.cancelReturns() {
+		ctrl.yield(WaitForever)
+	}
+}
+
+// Parks the coroutine until Wake() is called. Unlike Abyss(),
+// the coroutine is not resumed on Update() while parked, so
+// idle coroutines cost nothing until some other system
+// decides to wake them up. If Wake() was called before
+// parking, returns right away. Returns false if the coroutine
+// was cancelled, true otherwise.
+// Panics when cancelled, unless the cancel policy
+// is set to something other than CancelPanic.
+func (ctrl *Control) AbyssUntilWoken() bool {
+	ctrl.beginWait()
+	for !ctrl.woken.Swap(false) {
+		if ctrl.cancelReturns() {
+			return false
+		}
+		ctrl.yield(WaitWake)
+	}
+	return true
+}
+
+// Wakes up the coroutine parked with AbyssUntilWoken(),
+// which resumes on the next Update(). If the coroutine isn't
+// parked, the next AbyssUntilWoken() returns right away.
+// Can be called from any thread.
+func (ctrl *Control) Wake() {
+	ctrl.woken.Store(true)
+}
+
+// Returns what the coroutine is currently waiting on.
+// Returns WaitNone if the coroutine is done.
+func (ctrl *Control) WaitReason() WaitReason {
+	return WaitReason(ctrl.waitReason.Load())
+}
+
+// Returns the condition that the coroutine is waiting
+// on with YieldOn(), or nil if there is none.
+func (ctrl *Control) WaitCond() *Cond {
+	return ctrl.waitCond.Load()
+}
+
+// Returns ErrCancelled if the coroutine was cancelled,
+// a *PanicError if it panicked, or nil if it finished
+// normally or is still running.
+//
+//	Note: Panics are only recovered in child coroutines
+//	started with StartAsync(). A panic in the base
+//	coroutine of a script is not recovered.
+func (ctrl *Control) Err() error {
+	ctrl.errMu.Lock()
+	defer ctrl.errMu.Unlock()
+	return ctrl.endErr()
+}
+
+// Returns true if the coroutine was cancelled
+// before it could finish.
+func (ctrl *Control) WasCancelled() bool {
+	return ctrl.Err() == ErrCancelled
+}
+
+// Yields the given parent coroutine until the coroutine is done,
+// then returns Err(). This is a safer alternative to
+//
+//	ctrl.YieldUntil(sub.IsDone)
+//
+// since it also returns once the coroutine is done and
+// is freed to be reused for another coroutine.
+// Panics when the parent is cancelled.
+//
+//	Note: Join() should be called by the parent before
+//	it starts other child coroutines, since a child that is
+//	already done may have been reused for a newer child.
+func (ctrl *Control) Join(parent *Control) error {
+	gen := ctrl.generation.Load()
+	if ctrl.parent != parent {
+		// already freed and reused elsewhere
+		return nil
+	}
+	parent.YieldUntil(func() bool {
+		return ctrl.generation.Load() != gen || ctrl.IsDone()
+	})
+	return ctrl.errOf(gen)
+}
+
+// Returns Err() of the coroutine that ran
+// in the given generation of the control.
+func (ctrl *Control) errOf(gen uint64) error {
+	ctrl.errMu.Lock()
+	defer ctrl.errMu.Unlock()
+	switch ctrl.generation.Load() {
+	case gen:
+		return ctrl.endErr()
+	case gen + 1:
+		return ctrl.prevErr
+	default:
+		// reused more than once, the error is lost
+		return nil
 	}
 }
 
@@ -175,28 +693,188 @@ func (ctrl *Control) IsDone() bool {
 //	Note: Cancel() won't immediately take effect.
 //	Actual cancellation will be done on next Update().
 func (ctrl *Control) Cancel() {
+	ctrl.cancelWith(CauseExplicitCancel)
+}
+
+func (ctrl *Control) cancelWith(cause CancelCause) {
+	ctrl.cause.Store(uint32(cause))
 	ctrl.action.Store(actionCancel)
 }
 
+// Pauses the coroutine. A paused coroutine, including its
+// child coroutines, will not be resumed on Update()
+// until Resume() is called.
+//
+//	Note: Cancel() and Restart() will only take effect
+//	once the coroutine is resumed.
+func (ctrl *Control) Pause() {
+	ctrl.paused.Store(true)
+}
+
+// Resumes a paused coroutine.
+func (ctrl *Control) Resume() {
+	ctrl.paused.Store(false)
+}
+
+// Returns true if the coroutine is paused.
+func (ctrl *Control) IsPaused() bool {
+	return ctrl.paused.Load()
+}
+
 // Restarts the coroutine. If the coroutine still running,
 // it is cancelled first.
 //
 //	Note: Restart() won't immediately take effect.
 //	Actual restart will be done on next Update().
 func (ctrl *Control) Restart() {
+	if ctrl.IsRunning() && !ctrl.isRestarting() && !ctrl.isCancelling() {
+		ctrl.cancelWith(CauseRestart)
+	}
 	bits.Set(&ctrl.action, actionRestart)
 }
 
+// Same as Restart(), but child coroutines started with the
+// KeepOnRestart() option are kept running instead of
+// being cancelled. If the coroutine is still running,
+// it is cancelled first.
+// Useful for re-planning without interrupting children
+// such as animations.
+func (ctrl *Control) RestartKeepChildren() {
+	ctrl.keepChildren.Store(true)
+	ctrl.cancelWith(CauseRestart)
+	ctrl.Restart()
+}
+
 // Changes the current coroutine to a new one. If there is
 // a current coroutine running, it is cancelled first.
 // This is conceptually equivalent to transitions in
 // finite state machines.
 func (ctrl *Control) Transition(newCoroutine Coroutine) {
-	ctrl.coroutine = newCoroutine
-	ctrl.Cancel()
+	ctrl.historyMu.Lock()
+	if current := ctrl.pendingCoroutine(); current != nil {
+		if len(ctrl.history) >= maxTransitionHistory {
+			ctrl.history = append(ctrl.history[:0], ctrl.history[1:]...)
+		}
+		ctrl.history = append(ctrl.history, current)
+	}
+	ctrl.nextCoroutine = newCoroutine
+	ctrl.historyMu.Unlock()
+
+	ctrl.recordTransition(newCoroutine)
+	ctrl.cancelWith(CauseTransition)
 	ctrl.Restart()
 }
 
+// Changes back to the coroutine before the last Transition(),
+// which is restarted from the beginning. Returns false
+// if there is no previous coroutine.
+// Only the last 16 coroutines are remembered.
+// This is conceptually equivalent to history states in statecharts.
+func (ctrl *Control) TransitionBack() bool {
+	ctrl.historyMu.Lock()
+	if len(ctrl.history) == 0 {
+		ctrl.historyMu.Unlock()
+		return false
+	}
+	last := len(ctrl.history) - 1
+	prev := ctrl.history[last]
+	ctrl.history[last] = nil
+	ctrl.history = ctrl.history[:last]
+	ctrl.nextCoroutine = prev
+	ctrl.historyMu.Unlock()
+
+	ctrl.recordTransition(prev)
+	ctrl.cancelWith(CauseTransition)
+	ctrl.Restart()
+	return true
+}
+
+// Returns the number of times the coroutine changed
+// to another one with Transition() or TransitionBack().
+// Can be called from any thread.
+func (ctrl *Control) Transitions() int64 {
+	return ctrl.transitions.Load()
+}
+
+// Yields until the other coroutine changes to another one with
+// Transition() or TransitionBack(), so that a supervising
+// coroutine can react to the state changes of an entity's
+// state machine. Returns false if the other coroutine
+// ended instead.
+// Panics when cancelled.
+//
+//	for ctrl.YieldUntilTransition(enemy) {
+//		updateAlertLevel()
+//	}
+func (ctrl *Control) YieldUntilTransition(other SubControl) bool {
+	sub, ok := other.(*Control)
+	if !ok {
+		start := other.Transitions()
+		ctrl.YieldUntil(func() bool {
+			return other.Transitions() != start || other.IsDone()
+		})
+		return other.Transitions() != start
+	}
+	// the other coroutine may end and be reused for
+	// another one, which has transitions of its own
+	gen := sub.generation.Load()
+	start := sub.Transitions()
+	ctrl.YieldUntil(func() bool {
+		return sub.generation.Load() != gen || sub.Transitions() != start || sub.IsDone()
+	})
+	transitions := sub.Transitions()
+	return sub.generation.Load() == gen && transitions != start
+}
+
+func (ctrl *Control) recordTransition(next Coroutine) {
+	ctrl.transitions.Add(1)
+	if ctrl.root().historyLog != nil {
+		ctrl.recordHistory(HistoryEvent{Kind: HistoryTransition, Name: coroutineName(next)})
+	}
+	ctrl.spanEvent("transition", map[string]string{"to": coroutineName(next)})
+}
+
+// Causes the pending Sleep() and Delay() calls of the coroutine
+// and its child coroutines to return on the next Update().
+// Other waits like YieldUntil() are not affected.
+// Useful for skipping cutscenes, or for speeding up tests.
+func (ctrl *Control) FastForward() {
+	ctrl.skipCount.Add(1)
+}
+
+// Sets the coroutine to be only resumed every nth Update().
+// Useful for low-priority coroutines that doesn't need
+// to run every frame. A value of n <= 1 resumes the coroutine
+// on every Update().
+//
+//	Note: Cancellation and restarts are still
+//	applied on the next Update().
+func (ctrl *Control) SetUpdateDivider(n int) {
+	if n < 1 {
+		n = 1
+	}
+	ctrl.updateDivider.Store(int32(n))
+}
+
+// When enabled, the child coroutines are cancelled one at a time
+// in reverse order of creation when the coroutine ends, with each
+// child finishing its cleanup before the next one is cancelled.
+// Useful when the children depend on each other, for instance
+// an effect attached to a spawned object.
+// Otherwise, all children are cancelled at the same time.
+func (ctrl *Control) SetSequentialTeardown(enabled bool) {
+	ctrl.sequentialTeardown.Store(enabled)
+}
+
+// Sets the maximum time spent on updating the child coroutines
+// in one frame. When exceeded, the remaining low-priority children
+// (those with negative priority) are skipped until the next frame.
+// A budget of zero or less disables the limit.
+// See also WithPriority().
+func (ctrl *Control) SetFrameBudget(budget time.Duration) {
+	ctrl.frameBudget.Store(int64(budget))
+}
+
 // Starts a new child coroutine asynchronously. The child
 // coroutine will be automatically cancelled when the current
 // coroutine ends and is no longer IsRunning().
@@ -205,27 +883,255 @@ func (ctrl *Control) Transition(newCoroutine Coroutine) {
 //
 //	ctrl.YieldUntil(childIn.IsDone)
 //
+// If the child coroutine panics, the panic is recovered
+// and reported with sub.Err() instead.
+//
 // See also the test functions TestAsync* for a more thorough
 // example.
-func (ctrl *Control) StartAsync(coroutine Coroutine) SubControl {
-	subIn := allocCoroutine()
+func (ctrl *Control) StartAsync(coroutine Coroutine, options ...Option) SubControl {
+	subIn := allocCoroutine(ctrl)
 	subIn.initialize(coroutine)
+	for _, opt := range options {
+		opt(subIn)
+	}
+
+	subIn.parent = ctrl
+	if ids := ctrl.root().ids; ids != nil {
+		subIn.ID = ids.Next()
+	}
+
 	ctrl.subControlsMu.Lock()
-	ctrl.subControls = append(ctrl.subControls, subIn)
+	// keep children sorted by priority, in order of creation
+	// for children with the same priority
+	index := len(ctrl.subControls)
+	for index > 0 && ctrl.subControls[index-1].priority.Load() < subIn.priority.Load() {
+		index--
+	}
+	ctrl.subControls = slices.Insert(ctrl.subControls, index, subIn)
+	ctrl.subCount++
+	subIn.startOrder = ctrl.subCount
+	subIn.seed = childSeed(ctrl.seed, subIn.startOrder)
 	ctrl.subControlsMu.Unlock()
 
 	return subIn
 }
 
-// Use for debugging. Call SetLogging(true) to enable.
+// Returns the parent of the coroutine, or nil
+// if it's the base coroutine of a script.
+func (ctrl *Control) Parent() SubControl {
+	if ctrl.parent == nil {
+		return nil
+	}
+	return ctrl.parent
+}
+
+// Returns the base coroutine of the script
+// where the coroutine was started.
+func (ctrl *Control) Root() SubControl {
+	return ctrl.root()
+}
+
+// Returns a snapshot of the currently running child coroutines.
+//
+//	Note: Avoid keeping the returned children beyond the
+//	current frame, since they may be disposed and
+//	freed for subsequent use once they're done.
+func (ctrl *Control) Children() []SubControl {
+	ctrl.subControlsMu.RLock()
+	defer ctrl.subControlsMu.RUnlock()
+	result := make([]SubControl, 0, len(ctrl.subControls))
+	for _, sub := range ctrl.subControls {
+		result = append(result, sub)
+	}
+	return result
+}
+
+// Associates a value with the key on the coroutine.
+// The value will be visible to all child coroutines
+// through Value(), unless a child sets its own
+// value with the same key.
+func (ctrl *Control) SetValue(key, value any) {
+	ctrl.valuesMu.Lock()
+	defer ctrl.valuesMu.Unlock()
+	if ctrl.values == nil {
+		ctrl.values = map[any]any{}
+	}
+	ctrl.values[key] = value
+}
+
+// Returns the value associated with the key. If
+// the coroutine has no such value, the parent coroutines
+// are looked up instead. Returns nil if none is found.
+func (ctrl *Control) Value(key any) any {
+	for c := ctrl; c != nil; c = c.parent {
+		c.valuesMu.RLock()
+		value, ok := c.values[key]
+		c.valuesMu.RUnlock()
+		if ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// Sets how much of the coroutine's work has been done,
+// from 0 to 1. Values outside the range are clamped.
+// Useful for loading screens and debug UIs.
+// See also script.Progress().
+func (ctrl *Control) SetProgress(progress float64) {
+	progress = math.Max(0, math.Min(1, progress))
+	ctrl.progress.Store(math.Float64bits(progress))
+}
+
+// Returns the progress set with SetProgress().
+func (ctrl *Control) Progress() float64 {
+	return math.Float64frombits(ctrl.progress.Load())
+}
+
+// Sets a short description of what the coroutine is
+// currently doing, for instance "loading textures".
+func (ctrl *Control) SetStatus(status string) {
+	ctrl.statusMu.Lock()
+	ctrl.status = status
+	ctrl.statusMu.Unlock()
+}
+
+// Returns the status set with SetStatus().
+func (ctrl *Control) Status() string {
+	ctrl.statusMu.Lock()
+	defer ctrl.statusMu.Unlock()
+	return ctrl.status
+}
+
+// Returns a copy of the tags of the coroutine. See WithTags().
+func (ctrl *Control) Tags() []string {
+	ctrl.tagsMu.RLock()
+	defer ctrl.tagsMu.RUnlock()
+	return slices.Clone(ctrl.tags)
+}
+
+// Returns true if the coroutine has the given tag.
+func (ctrl *Control) HasTag(tag string) bool {
+	ctrl.tagsMu.RLock()
+	defer ctrl.tagsMu.RUnlock()
+	return slices.Contains(ctrl.tags, tag)
+}
+
+// Records that the coroutine has reached a certain point.
+// Checkpoints of all coroutines are collected in the script,
+// and can be retrieved with script.Checkpoints().
+// Mainly used for testing the control flow of a script.
+func (ctrl *Control) Checkpoint(name string) {
+	root := ctrl.root()
+	root.checkpointsMu.Lock()
+	root.checkpoints = append(root.checkpoints, name)
+	root.checkpointsMu.Unlock()
+}
+
+// Returns the current time according to the clock
+// of the script, including the time simulated
+// with script.Advance(). See WithClock().
+func (ctrl *Control) Now() time.Time {
+	root := ctrl.root()
+	offset := time.Duration(root.clockOffset.Load())
+	if at := root.frozenAt.Load(); at != 0 {
+		return time.Unix(0, at).Add(offset)
+	}
+	return root.clockNow().Add(offset)
+}
+
+// Returns the time of the clock, without the offset.
+func (ctrl *Control) clockNow() time.Time {
+	if ctrl.clock == nil {
+		return SystemClock.Now()
+	}
+	return ctrl.clock.Now()
+}
+
+// Returns the interpolation fraction, from 0 to 1, of the
+// current update, as given to script.UpdateWithAlpha().
+// When the script is updated on a fixed timestep, this is
+// how far the rendered frame is between the previous
+// and the next fixed update. Returns 0 if the script
+// is updated with Update().
+func (ctrl *Control) Alpha() float64 {
+	return math.Float64frombits(ctrl.root().alpha.Load())
+}
+
+// Use for debugging. Call SetLogging(true) to enable,
+// or start the script with WithLogger().
 func (ctrl *Control) Logf(format string, args ...any) {
-	logFn(ctrl, format, args...)
+	ctrl.logAt(LogDebug, format, args...)
 }
 
 func (ctrl *Control) String() string {
 	return fmt.Sprintf("coroutine-%v", ctrl.ID)
 }
 
+func (ctrl *Control) yield(reason WaitReason) {
+	if fn := ctrl.root().yieldFn.Load(); fn != nil {
+		(*fn)(ctrl, reason)
+		return
+	}
+	baseYield(ctrl, reason)
+}
+
+func baseYield(ctrl *Control, reason WaitReason) {
+	if ctrl.root().historyLog != nil {
+		ctrl.recordHistory(HistoryEvent{Kind: HistoryYield, Reason: reason, Label: ctrl.WaitLabel()})
+	}
+	if ctrl.tracesYields() {
+		ctrl.tracef("yield (%v)", reason)
+	}
+	ctrl.waitReason.Store(uint32(reason))
+	ctrl.unlockShard()
+	ctrl.active.Store(false)
+	ctrl.kanata.YieldRight()
+	ctrl.active.Store(true)
+	ctrl.lockShard()
+	ctrl.waitReason.Store(uint32(WaitNone))
+	ctrl.resumedAt = time.Now()
+	if ctrl.isCanceled() && ctrl.root().cancelPolicy == CancelPanic {
+		panic(ErrCancelled)
+	}
+}
+
+// Schedules fn to be called on the main thread
+// on the next Update() of the script.
+func (ctrl *Control) callOnUpdate(fn func()) {
+	root := ctrl.root()
+	root.callsMu.Lock()
+	root.calls = append(root.calls, fn)
+	root.callsMu.Unlock()
+}
+
+// Calls the functions scheduled with callOnUpdate().
+// Functions scheduled while running are called
+// on the next update.
+func (ctrl *Control) runCalls() {
+	ctrl.callsMu.Lock()
+	calls := ctrl.calls
+	ctrl.calls = nil
+	ctrl.callsMu.Unlock()
+	for i, fn := range calls {
+		fn()
+		calls[i] = nil
+	}
+	ctrl.callsMu.Lock()
+	if ctrl.calls == nil {
+		ctrl.calls = calls[:0]
+	}
+	ctrl.callsMu.Unlock()
+}
+
+func (ctrl *Control) root() *Control {
+	root := ctrl
+	for root.parent != nil {
+		root = root.parent
+	}
+	return root
+}
+
 func (ctrl *Control) setRunning(yes bool) {
 	if yes {
 		bits.Set(&ctrl.state, stateRunning)
@@ -234,13 +1140,66 @@ func (ctrl *Control) setRunning(yes bool) {
 	}
 }
 
+// Returns the coroutine that runs on the next restart,
+// must be called while holding historyMu.
+func (ctrl *Control) pendingCoroutine() Coroutine {
+	if ctrl.nextCoroutine != nil {
+		return ctrl.nextCoroutine
+	}
+	return ctrl.coroutine
+}
+
+// Returns the current coroutine, can be called from any thread.
+func (ctrl *Control) currentCoroutine() Coroutine {
+	ctrl.historyMu.Lock()
+	defer ctrl.historyMu.Unlock()
+	return ctrl.coroutine
+}
+
+// Reports whether there is a coroutine to run, including
+// one set by Transition() that has not started yet.
+func (ctrl *Control) hasCoroutine() bool {
+	ctrl.historyMu.Lock()
+	defer ctrl.historyMu.Unlock()
+	return ctrl.pendingCoroutine() != nil
+}
+
 func (ctrl *Control) applyRestart() {
+	ctrl.historyMu.Lock()
+	if ctrl.nextCoroutine != nil {
+		ctrl.coroutine = ctrl.nextCoroutine
+		ctrl.nextCoroutine = nil
+	}
+	ctrl.historyMu.Unlock()
+	ctrl.cause.Store(uint32(CauseNone))
 	bits.Unset(&ctrl.state, stateCancel)
 	bits.Unset(&ctrl.action, actionRestart|actionCancel)
 }
 func (ctrl *Control) applyCancel() {
+	switch ctrl.Cause() {
+	case CauseRestart, CauseTransition:
+	default:
+		if ctrl.root().historyLog != nil {
+			ctrl.recordHistory(HistoryEvent{Kind: HistoryCancel, Cause: ctrl.Cause()})
+		}
+		ctrl.spanEvent("cancel", map[string]string{"cause": ctrl.Cause().String()})
+		if instrumented() {
+			ctrl.stats.cancels.Add(1)
+		}
+	}
 	bits.Set(&ctrl.state, stateCancel)
 	bits.Unset(&ctrl.action, actionCancel)
+	ctrl.cancelContext()
+}
+
+// Returns a value that changes whenever FastForward() is
+// called on the coroutine or any of its parents.
+func (ctrl *Control) skipMark() int64 {
+	var mark int64
+	for c := ctrl; c != nil; c = c.parent {
+		mark += c.skipCount.Load()
+	}
+	return mark
 }
 
 func (ctrl *Control) isRestarting() bool { return bits.IsSet(&ctrl.action, actionRestart) }
@@ -248,16 +1207,42 @@ func (ctrl *Control) isCancelling() bool { return bits.IsSet(&ctrl.action, actio
 func (ctrl *Control) isCanceled() bool   { return bits.IsSet(&ctrl.state, stateCancel) }
 
 func (ctrl *Control) loopRunner() {
+	ctrl.gid.Store(goroutineID())
 	ctrl.setRunning(true)
+	close(ctrl.ready)
 	for {
-		ctrl.Logf("loop start")
-		ctrl.kanata.YieldRight()
+		// the parent may not be set yet, so
+		// this only goes to the global log
+		logFn(ctrl, "loop start")
+		if !ctrl.kanata.YieldRightOrQuit(ctrl.quit) {
+			ctrl.setRunning(false)
+			return
+		}
+		if !ctrl.startRequested.Swap(false) {
+			// resumed after the coroutine ended,
+			// or cancelled before it started
+			ctrl.setRunning(false)
+			continue
+		}
 
-		ctrl.Logf("coroutine start")
-		ctrl.setRunning(true)
-		ctrl.startCoroutine()
+		for {
+			ctrl.Logf("coroutine start")
+			ctrl.startRequested.Store(false)
+			ctrl.restartPending.Store(false)
+			ctrl.setRunning(true)
+			ctrl.resumedAt = time.Now()
+			ctrl.startSpan()
+			ctrl.startCoroutine()
+			if ctrl.root().historyLog != nil {
+				ctrl.recordHistory(HistoryEvent{Kind: HistoryEnd, Err: ctrl.Err()})
+			}
+			ctrl.endSpan(ctrl.Err())
 
-		ctrl.waitForSubsToEnd()
+			ctrl.waitForSubsToEnd()
+			if !ctrl.restartPending.Load() {
+				break
+			}
+		}
 
 		ctrl.Logf("coroutine end")
 		ctrl.setRunning(false)
@@ -265,20 +1250,91 @@ func (ctrl *Control) loopRunner() {
 }
 
 func (ctrl *Control) startCoroutine() {
-	defer catchCancellation()
+	ctrl.setEnded(false, nil)
+	ctrl.forceWait.Store(false)
+	ctrl.resetContext()
+	ctrl.active.Store(true)
+	defer ctrl.active.Store(false)
+	ctrl.lockShard()
+	defer ctrl.unlockShard()
+	defer ctrl.catchError()
+	defer ctrl.stopTimers()
+	defer ctrl.cancelContext()
+	if ctrl.root().historyLog != nil {
+		ctrl.recordHistory(HistoryEvent{Kind: HistoryStart, Name: coroutineName(ctrl.coroutine)})
+	}
 	ctrl.coroutine(ctrl)
 }
 
+func (ctrl *Control) catchError() {
+	err := recover()
+	switch {
+	case err == nil && ctrl.isCanceled() && ctrl.cancelReturns():
+		// returned after noticing the cancellation
+		ctrl.setEnded(true, ErrCancelled)
+	case err == nil:
+		ctrl.setEnded(true, nil)
+	case err == ErrCancelled:
+		ctrl.setEnded(true, ErrCancelled)
+	case ctrl.parent == nil:
+		panic(err)
+	default:
+		ctrl.Logf("panicked: %v", err)
+		ctrl.setEnded(true, &PanicError{Value: err})
+	}
+}
+
+func (ctrl *Control) setEnded(ended bool, err error) {
+	ctrl.errMu.Lock()
+	ctrl.ended = ended
+	ctrl.err = err
+	ctrl.errMu.Unlock()
+}
+
 func (ctrl *Control) waitForSubsToEnd() {
+	// stopping state and the list of subs is changed while locked
+	// so that update() won't also free the same subs
+	ctrl.subUpdateMu.Lock()
 	bits.Set(&ctrl.state, stateStopping)
-	defer bits.Unset(&ctrl.state, stateStopping)
-
 	ctrl.subControlsMu.RLock()
-	subs := ctrl.subControls
+	subs := slices.Clone(ctrl.subControls)
 	ctrl.subControlsMu.RUnlock()
+	ctrl.subUpdateMu.Unlock()
 
-	for _, s := range subs {
-		s.Cancel()
+	var kept []*Control
+	if ctrl.keepChildren.Swap(false) && ctrl.isRestarting() {
+		var ending []*Control
+		for _, s := range subs {
+			if s.keepOnRestart {
+				kept = append(kept, s)
+			} else {
+				ending = append(ending, s)
+			}
+		}
+		subs = ending
+	}
+
+	ctrl.waitReason.Store(uint32(WaitChildren))
+	defer ctrl.waitReason.Store(uint32(WaitNone))
+
+	// cancel the newest subs first, since they may
+	// depend on the older ones
+	ordered := subs
+	if len(subs) > 1 {
+		ordered = slices.Clone(subs)
+		slices.SortStableFunc(ordered, func(a, b *Control) bool {
+			return a.startOrder > b.startOrder
+		})
+	}
+	waited := 0
+	sequential := ctrl.sequentialTeardown.Load()
+	for _, s := range ordered {
+		s.cancelWith(CauseParentEnded)
+		for sequential && !s.IsDone() {
+			ctrl.kanata.YieldRight()
+			waited++
+			ctrl.checkStall(waited, subs)
+		}
 	}
 
 	done := false
@@ -293,71 +1349,384 @@ func (ctrl *Control) waitForSubsToEnd() {
 		}
 		if !done {
 			ctrl.kanata.YieldRight()
+			waited++
+			ctrl.checkStall(waited, subs)
 		}
 	}
 
-	ctrl.subControlsMu.Lock()
-	ctrl.subControls = ctrl.subControls[:0]
-	ctrl.subControlsMu.Unlock()
-
+	ctrl.subUpdateMu.Lock()
 	for _, s := range subs {
-		freeCoroutine(s)
+		s.removing = true
 	}
+	// subs started while waiting are kept
+	ctrl.removeSubs()
+	for _, s := range subs {
+		freeCoroutine(s, ctrl)
+	}
+	bits.Unset(&ctrl.state, stateStopping)
+	ctrl.subUpdateMu.Unlock()
+}
 
+// Removes the subs marked as removing from subControls.
+// Must be called while holding subUpdateMu.
+func (ctrl *Control) removeSubs() {
+	ctrl.subControlsMu.Lock()
+	defer ctrl.subControlsMu.Unlock()
+	kept := ctrl.subControls[:0]
+	for _, sub := range ctrl.subControls {
+		if !sub.removing {
+			kept = append(kept, sub)
+		}
+	}
+	for i := len(kept); i < len(ctrl.subControls); i++ {
+		ctrl.subControls[i] = nil
+	}
+	ctrl.subControls = kept
 }
 
-func (ctrl *Control) update() {
+// Updates the coroutine and its subs. The report
+// may be nil if it's not needed.
+func (ctrl *Control) update(report *FrameReport) {
+	if poolTracing.Load() {
+		traceUse(ctrl)
+	}
+	if ctrl.paused.Load() {
+		return
+	}
+	if ctrl.timeout > 0 {
+		ctrl.checkTimeout()
+	}
+
 	restartNow := ctrl.isRestarting()
 	if ctrl.isCancelling() {
 		ctrl.applyCancel()
 		restartNow = false
 	} else if restartNow {
+		if ctrl.isCanceled() && ctrl.IsRunning() {
+			// the resume below will be used up by waitForSubsToEnd(),
+			// so the loopRunner has to start the coroutine by itself
+			ctrl.restartPending.Store(true)
+		}
 		bits.Unset(&ctrl.action, actionRestart)
 		ctrl.applyRestart()
+		ctrl.startTimeout()
+		ctrl.starts.Add(1)
+		ctrl.checkRestartLoop()
+		if instrumented() {
+			ctrl.stats.starts.Add(1)
+		}
+		// mark as running before resuming, otherwise the
+		// coroutine could be seen as IsDone() before it even starts
+		if ctrl.coroutine != nil {
+			ctrl.setRunning(true)
+		}
 	}
 
 	if ctrl.coroutine != nil && (ctrl.IsRunning() || restartNow) {
-		ctrl.kanata.YieldLeft()
+		// sleeping coroutines are not resumed until the sleep ends,
+		// since they would only yield again right away
+		if restartNow || ctrl.isCanceled() || (!ctrl.isSleeping() && !ctrl.isParked() && !ctrl.isAtBreakpoint() && ctrl.isUpdateTurn()) {
+			if restartNow {
+				ctrl.startRequested.Store(true)
+			}
+			if ctrl.root().historyLog != nil {
+				ctrl.recordHistory(HistoryEvent{Kind: HistoryResume})
+			}
+			if !ctrl.resumeLeft() {
+				// still running since the last resume,
+				// the subs are left for the next update
+				return
+			}
+			if ctrl.root().serialized.Load() {
+				spinUntil(ctrl.kanata.isWaiting)
+			}
+			if instrumented() {
+				ctrl.stats.resumes.Add(1)
+			}
+			if report != nil {
+				report.Resumed++
+			}
+		} else if instrumented() {
+			ctrl.stats.framesWaited.Add(1)
+		}
+	}
+	if report != nil && ctrl.isRestarting() {
+		report.PendingRestarts++
 	}
 
 	{
-		// update and remove finished subs
+		// update and remove finished subs. The subs are copied
+		// since the coroutine and the subs may be running and
+		// starting new subs at the same time, the lock only keeps
+		// the teardown of the coroutine from freeing them meanwhile
+		ctrl.subUpdateMu.Lock()
+		defer ctrl.subUpdateMu.Unlock()
 		ctrl.subControlsMu.RLock()
-		subs := ctrl.subControls
+		ctrl.tempSubControls = append(ctrl.tempSubControls[:0], ctrl.subControls...)
 		ctrl.subControlsMu.RUnlock()
+		subs := ctrl.tempSubControls
+		defer func() {
+			for i := range ctrl.tempSubControls {
+				ctrl.tempSubControls[i] = nil
+			}
+			ctrl.tempSubControls = ctrl.tempSubControls[:0]
+		}()
 		if len(subs) > 0 {
 			// if it's stopping already, don't bother
 			// filtering out finished subs here, since they will
 			// be removed soon anyway on the loopRunner thread.
 			if bits.IsSet(&ctrl.state, stateStopping) {
 				for _, sub := range subs {
-					sub.update()
+					sub.update(report)
 				}
 			} else {
-				hasRemoved := false
+				budget := time.Duration(ctrl.frameBudget.Load())
+				startTime := time.Now()
 				for _, sub := range subs {
-					sub.update()
+					if budget > 0 && sub.priority.Load() < 0 && time.Since(startTime) > budget {
+						continue
+					}
+					sub.update(report)
 					if sub.IsDone() {
-						freeCoroutine(sub)
-						hasRemoved = true
-					} else {
-						ctrl.tempSubControls = append(ctrl.tempSubControls, sub)
+						sub.removing = true
+						ctrl.doneSubControls = append(ctrl.doneSubControls, sub)
+						if report != nil {
+							report.Completed++
+						}
 					}
 				}
-				if hasRemoved {
-					ctrl.subControlsMu.Lock()
-					ctrl.subControls = ctrl.tempSubControls
-					ctrl.subControlsMu.Unlock()
+				if len(ctrl.doneSubControls) > 0 {
+					// the finished subs are only freed once removed,
+					// so that ChildrenSnapshot() doesn't see them reused
+					ctrl.removeSubs()
+					for i, sub := range ctrl.doneSubControls {
+						freeCoroutine(sub, ctrl)
+						ctrl.doneSubControls[i] = nil
+					}
+					ctrl.doneSubControls = ctrl.doneSubControls[:0]
 				}
-				ctrl.tempSubControls = ctrl.tempSubControls[:0]
 			}
 		}
 	}
 }
 
+// Returns true if updating the coroutine and its subs
+// won't do anything other than resuming them, which is
+// the case when all of them are done, paused, or are sleeping
+// until after the given time.
+func (ctrl *Control) isIdle(now time.Time) bool {
+	if ctrl.paused.Load() {
+		return true
+	}
+	if ctrl.action.Load() != actionNone {
+		return false
+	}
+
+	if ctrl.coroutine != nil && ctrl.IsRunning() {
+		switch ctrl.WaitReason() {
+		case WaitSleep:
+			if !ctrl.isSleepingAt(now) {
+				return false
+			}
+		case WaitWake:
+			if !ctrl.isParked() {
+				return false
+			}
+		case WaitForever:
+		case WaitChildren:
+			if !ctrl.hasRunningSubs() {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	ctrl.subControlsMu.RLock()
+	defer ctrl.subControlsMu.RUnlock()
+	for _, sub := range ctrl.subControls {
+		if !sub.isIdle(now) {
+			return false
+		}
+	}
+	return true
+}
+
+// Returns true if the coroutine should not be resumed
+// due to script.Break(). Uses up one step from
+// script.StepOne() if there is any.
+func (ctrl *Control) isAtBreakpoint() bool {
+	root := ctrl.root()
+	if root.breakID.Load() != ctrl.ID {
+		return false
+	}
+	for {
+		steps := root.breakSteps.Load()
+		if steps <= 0 {
+			return true
+		}
+		if root.breakSteps.CompareAndSwap(steps, steps-1) {
+			return false
+		}
+	}
+}
+
+// Returns true if the coroutine is in Sleep(),
+// and the sleep hasn't ended yet.
+func (ctrl *Control) isSleeping() bool {
+	if ctrl.WaitReason() != WaitSleep {
+		return false
+	}
+	return ctrl.isSleepingAt(ctrl.Now())
+}
+
+func (ctrl *Control) isSleepingAt(now time.Time) bool {
+	return now.UnixNano() < ctrl.sleepUntil.Load() && ctrl.skipMark() == ctrl.sleepMark.Load()
+}
+
+// Returns true if the coroutine is in AbyssUntilWoken(),
+// and Wake() hasn't been called yet.
+func (ctrl *Control) isParked() bool {
+	return ctrl.WaitReason() == WaitWake && !ctrl.woken.Load()
+}
+
+// Must be called with errMu held.
+func (ctrl *Control) endErr() error {
+	if ctrl.ended {
+		return ctrl.err
+	}
+	if ctrl.coroutine != nil && ctrl.IsDone() {
+		// cancelled before it even started
+		return ErrCancelled
+	}
+	return nil
+}
+
+func (ctrl *Control) hasRunningSubs() bool {
+	ctrl.subControlsMu.RLock()
+	defer ctrl.subControlsMu.RUnlock()
+	for _, sub := range ctrl.subControls {
+		if !sub.IsDone() {
+			return true
+		}
+	}
+	return false
+}
+
+func (ctrl *Control) isUpdateTurn() bool {
+	divider := int(ctrl.updateDivider.Load())
+	if divider <= 1 {
+		return true
+	}
+	ctrl.updateCount++
+	return ctrl.updateCount%divider == 0
+}
+
 func (ctrl *Control) initialize(coroutine Coroutine) {
+	ctrl.errMu.Lock()
+	ctrl.prevErr = ctrl.endErr()
+	ctrl.generation.Add(1)
+	ctrl.ended = false
+	ctrl.err = nil
+	ctrl.errMu.Unlock()
+
+	ctrl.historyMu.Lock()
 	ctrl.coroutine = coroutine
+	ctrl.nextCoroutine = nil
+	for i := range ctrl.history {
+		ctrl.history[i] = nil
+	}
+	ctrl.history = ctrl.history[:0]
+	ctrl.historyMu.Unlock()
+	ctrl.exactEnd = time.Time{}
+	ctrl.exactResumed = time.Time{}
+	ctrl.updateDivider.Store(1)
+	ctrl.updateCount = 0
+	ctrl.priority.Store(0)
+	ctrl.frameBudget.Store(0)
+	ctrl.sequentialTeardown.Store(false)
+	ctrl.keepChildren.Store(false)
+	ctrl.keepOnRestart = false
+	ctrl.removing = false
+	ctrl.restartPending.Store(false)
+	ctrl.subCount = 0
+	ctrl.paused.Store(false)
+	ctrl.woken.Store(false)
+	ctrl.stats.reset()
+	ctrl.parent = nil
+	ctrl.tagsMu.Lock()
+	ctrl.tags = ctrl.tags[:0]
+	ctrl.tagsMu.Unlock()
+	ctrl.progress.Store(0)
+	ctrl.SetStatus("")
+	ctrl.clock = nil
+	ctrl.clockOffset.Store(0)
+	ctrl.frozenAt.Store(0)
+	ctrl.frozen.Store(0)
+	ctrl.ids = nil
+	ctrl.middlewaresMu.Lock()
+	ctrl.middlewares = ctrl.middlewares[:0]
+	ctrl.yieldFn.Store(nil)
+	ctrl.middlewaresMu.Unlock()
+	ctrl.breakID.Store(0)
+	ctrl.breakSteps.Store(0)
+	ctrl.alpha.Store(0)
+	ctrl.cancelPolicy = CancelPanic
+	ctrl.background.Store(false)
+	ctrl.serialized.Store(false)
+	ctrl.shard = ""
+	ctrl.shardMu.Store(nil)
+	ctrl.syncPool = false
+	ctrl.historyLog = nil
+	ctrl.frame.Store(0)
+	ctrl.starts.Store(0)
+	ctrl.restartLimit = 0
+	ctrl.restartWindow = 0
+	ctrl.restartFn = nil
+	ctrl.restartFrames = ctrl.restartFrames[:0]
+	ctrl.restartLoop = nil
+	ctrl.logger = nil
+	ctrl.logLevel = LogDebug
+	ctrl.hasLogger = false
+	ctrl.transitions.Store(0)
+	ctrl.scriptName = ""
+	ctrl.validation = nil
+	ctrl.forceWait.Store(false)
+	ctrl.stallFrames = 0
+	ctrl.stallFn = nil
+	ctrl.inputMu.Lock()
+	ctrl.inputPending = nil
+	ctrl.inputQueue = nil
+	ctrl.inputMu.Unlock()
+	ctrl.timeout = 0
+	ctrl.timeoutAt.Store(0)
+	ctrl.timedOut.Store(false)
+	ctrl.tracer = nil
+	ctrl.traceCtx = nil
+	ctrl.seed = timeSeed()
+	ctrl.rand = nil
+	ctrl.cause.Store(uint32(CauseNone))
+	ctrl.callsMu.Lock()
+	ctrl.calls = ctrl.calls[:0]
+	ctrl.callsMu.Unlock()
+	ctrl.waitReason.Store(uint32(WaitNone))
+	ctrl.waitCond.Store(nil)
+	ctrl.waitLabel.Store(nil)
+
+	ctrl.checkpointsMu.Lock()
+	ctrl.checkpoints = ctrl.checkpoints[:0]
+	ctrl.checkpointsMu.Unlock()
+
+	ctrl.valuesMu.Lock()
+	for k := range ctrl.values {
+		delete(ctrl.values, k)
+	}
+	ctrl.valuesMu.Unlock()
 	ctrl.Logf("created")
-	ctrl.Restart()
+	// start on the next update, clearing actions left from the
+	// previous use of the control, such as a cancel on a coroutine
+	// that was already done. Restart() is not used since it
+	// would also cancel the idle loopRunner.
+	ctrl.action.Store(actionRestart)
 
 }
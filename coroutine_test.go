@@ -3,6 +3,7 @@ package carrot_test
 import (
 	"fmt"
 	"math/rand"
+	"reflect"
 	"sort"
 	"strings"
 	"sync"
@@ -11,6 +12,7 @@ import (
 	"time"
 
 	"github.com/nvlled/carrot"
+	"github.com/nvlled/carrot/carrottest"
 )
 
 var updateDelay = 100 * time.Microsecond
@@ -559,3 +561,1470 @@ func BenchmarkYield(b *testing.B) {
 		script.Update()
 	}
 }
+
+func TestMaybeYield(t *testing.T) {
+	count := 0
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 10; i++ {
+			// fast enough, should not yield
+			ctrl.MaybeYield(time.Second)
+		}
+		count++
+		for i := 0; i < 3; i++ {
+			time.Sleep(2 * time.Millisecond)
+			ctrl.MaybeYield(time.Millisecond)
+		}
+		count++
+	})
+
+	frames := 0
+	for !script.IsDone() {
+		script.Update()
+		frames++
+	}
+	if count != 2 {
+		t.Error("wrong count", count)
+	}
+	if frames < 4 || frames > 5 {
+		t.Error("wrong number of frames", frames)
+	}
+}
+
+func TestUpdateDivider(t *testing.T) {
+	fastCount := atomic.Int32{}
+	slowCount := atomic.Int32{}
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			for {
+				slowCount.Add(1)
+				ctrl.Yield()
+			}
+		})
+		sub.SetUpdateDivider(3)
+		for i := 0; i < 30; i++ {
+			fastCount.Add(1)
+			ctrl.Yield()
+		}
+	})
+
+	for !script.IsDone() {
+		script.Update()
+	}
+
+	if fastCount.Load() != 30 {
+		t.Error("wrong fast count", fastCount.Load())
+	}
+	if n := slowCount.Load(); n < 9 || n > 11 {
+		t.Error("wrong slow count", n)
+	}
+}
+
+func TestParentAndChildren(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		if ctrl.Parent() != nil {
+			t.Error("base coroutine should have no parent")
+		}
+		// subB is started by the child on its own goroutine
+		subB := make(chan carrot.SubControl, 1)
+		subA := ctrl.StartAsync(func(child *carrot.Control) {
+			subB <- child.StartAsync(func(grandChild *carrot.Control) {
+				if grandChild.Parent() != child {
+					t.Error("wrong parent")
+				}
+				if grandChild.Root() != ctrl {
+					t.Error("wrong root")
+				}
+				grandChild.Abyss()
+			})
+			child.Abyss()
+		})
+		ctrl.Yield()
+		ctrl.Yield()
+
+		children := ctrl.Children()
+		if len(children) != 1 || children[0] != subA {
+			t.Error("wrong children", children)
+		}
+		grandChildren := subA.(*carrot.Control).Children()
+		if len(grandChildren) != 1 || grandChildren[0] != <-subB {
+			t.Error("wrong grand children", grandChildren)
+		}
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+}
+
+func TestValue(t *testing.T) {
+	type key string
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.SetValue(key("owner"), "player")
+		ctrl.SetValue(key("difficulty"), 1)
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.SetValue(key("difficulty"), 2)
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				if v := ctrl.Value(key("owner")); v != "player" {
+					t.Error("wrong owner", v)
+				}
+				if v := ctrl.Value(key("difficulty")); v != 2 {
+					t.Error("wrong difficulty", v)
+				}
+				if v := ctrl.Value(key("none")); v != nil {
+					t.Error("value should be nil", v)
+				}
+			})
+			ctrl.Yield()
+			ctrl.Yield()
+		})
+		ctrl.YieldUntil(sub.IsDone)
+		if v := ctrl.Value(key("difficulty")); v != 1 {
+			t.Error("wrong difficulty", v)
+		}
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	doorOpen := atomic.Bool{}
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Checkpoint("start")
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Delay(3)
+			doorOpen.Store(true)
+			ctrl.Checkpoint("door_opened")
+		})
+		ctrl.YieldUntil(sub.IsDone)
+		if !doorOpen.Load() {
+			ctrl.Checkpoint("door_locked")
+		}
+		ctrl.Checkpoint("reached_door")
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	carrottest.AssertReached(t, script, "reached_door")
+	carrottest.AssertNotReached(t, script, "door_locked")
+	carrottest.AssertReachedInOrder(t, script, "start", "door_opened", "reached_door")
+}
+
+func TestStress(t *testing.T) {
+	run := func(seed int64) []string {
+		var events []string
+		coroutine1 := func(ctrl *carrot.Control) {
+			events = append(events, "start1")
+			for i := 0; i < 10; i++ {
+				ctrl.StartAsync(func(ctrl *carrot.Control) {
+					ctrl.Delay(5)
+					events = append(events, "child")
+				})
+				ctrl.Yield()
+			}
+		}
+		coroutine2 := func(ctrl *carrot.Control) {
+			events = append(events, "start2")
+			ctrl.Delay(3)
+		}
+		script := carrot.Start(coroutine1)
+		carrottest.Stress(t, script, carrottest.StressOptions{
+			Seed:        seed,
+			Steps:       5000,
+			Transitions: []carrot.Coroutine{coroutine1, coroutine2},
+		})
+
+		script.Cancel()
+		for i := 0; i < 100 && !script.IsDone(); i++ {
+			script.Update()
+		}
+		if !script.IsDone() {
+			t.Fatal("script should be done after cancel")
+		}
+		return events
+	}
+
+	first := run(42)
+	second := run(42)
+	if len(first) < 10 {
+		t.Fatalf("too few events: %v", first)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("same seed should give the same events:\n%v\n%v", first, second)
+	}
+}
+
+func TestLifecycleCallbacks(t *testing.T) {
+	var events []string
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Delay(3)
+	})
+	script.OnStart(func() { events = append(events, "start") })
+	script.OnRestart(func() { events = append(events, "restart") })
+	script.OnCancel(func() { events = append(events, "cancel") })
+	script.OnDone(func() { events = append(events, "done") })
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Update()
+
+	script.Restart()
+	script.Update()
+	script.Cancel()
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Update()
+
+	result := strings.Join(events, " ")
+	if result != "start done restart cancel done" {
+		t.Error("wrong events:", result)
+	}
+}
+
+func TestUpdateReport(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 3; i++ {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				ctrl.Yield()
+			})
+		}
+		ctrl.Delay(10)
+	})
+
+	resumed := 0
+	completed := 0
+	for !script.IsDone() {
+		report := script.UpdateReport()
+		resumed += report.Resumed
+		completed += report.Completed
+		time.Sleep(updateDelay)
+	}
+	if completed != 3 {
+		t.Error("wrong completed count", completed)
+	}
+	if resumed < 11+6 {
+		t.Error("wrong resumed count", resumed)
+	}
+}
+
+func TestFastForward(t *testing.T) {
+	var slept, childDone atomic.Bool
+	var resume bool
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Delay(100000)
+			childDone.Store(true)
+		})
+		ctrl.Sleep(time.Hour)
+		slept.Store(true)
+		ctrl.YieldUntilVar(&resume)
+	})
+	script.Update()
+	script.Update()
+	script.FastForward()
+	script.Update()
+	script.Update()
+
+	if !slept.Load() {
+		t.Error("sleep was not skipped")
+	}
+	if !childDone.Load() {
+		t.Error("child delay was not skipped")
+	}
+	if script.IsDone() {
+		t.Error("YieldUntil must not be skipped")
+	}
+	script.Cancel()
+	script.Update()
+}
+
+type fixedClock struct{ now time.Time }
+
+func (clock fixedClock) Now() time.Time { return clock.now }
+
+func TestAdvance(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for {
+			ctrl.Sleep(30 * time.Minute)
+			ctrl.Checkpoint("slept")
+		}
+	}, carrot.WithClock(fixedClock{now: time.Unix(0, 0)}))
+
+	script.Update()
+	script.Update()
+	if len(script.Checkpoints()) != 0 {
+		t.Fatal("sleep must not end without advancing time")
+	}
+
+	script.Advance(time.Hour, time.Minute)
+	if n := len(script.Checkpoints()); n != 2 {
+		t.Error("wrong number of sleeps completed", n)
+	}
+	script.Cancel()
+	script.Update()
+}
+
+func TestIdle(t *testing.T) {
+	var child carrot.SubControl
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		child = ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+		ctrl.Delay(2)
+		ctrl.Sleep(time.Hour)
+	})
+	update := func() {
+		script.Update()
+		// let the coroutines reach their next yield
+		time.Sleep(time.Millisecond)
+	}
+
+	if script.IsIdle() {
+		t.Error("script must not be idle before it starts")
+	}
+	update()
+	if script.IsIdle() {
+		t.Error("script must not be idle while delaying")
+	}
+	update()
+	if reason := child.WaitReason(); reason != carrot.WaitForever {
+		t.Error("wrong wait reason:", reason)
+	}
+	update()
+	if !script.IsIdle() {
+		t.Error("script must be idle while sleeping")
+	}
+
+	script.FastForward()
+	if script.IsIdle() {
+		t.Error("script must not be idle after fast-forward")
+	}
+	update()
+	update()
+	if !script.IsDone() || !script.IsIdle() {
+		t.Error("script must be done and idle")
+	}
+}
+
+func TestSleepSkipsResume(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Sleep(time.Hour)
+	}, carrot.WithClock(fixedClock{now: time.Unix(0, 0)}))
+
+	resumed := 0
+	for i := 0; i < 20; i++ {
+		resumed += script.UpdateReport().Resumed
+		time.Sleep(updateDelay)
+	}
+	if resumed > 2 {
+		t.Error("sleeping coroutine must not be resumed every frame", resumed)
+	}
+
+	script.Advance(time.Hour, 0)
+	time.Sleep(updateDelay)
+	if !script.IsDone() {
+		t.Error("sleep must end after the duration has passed")
+	}
+}
+
+func TestSubControlErr(t *testing.T) {
+	var normal, panicked, cancelled error
+	var wasCancelled, cancelledEarly bool
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		a := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Yield()
+		})
+		b := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Yield()
+			panic("oops")
+		})
+		c := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+		d := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+		d.Cancel()
+		ctrl.Delay(2)
+		c.Cancel()
+
+		ctrl.YieldUntil(func() bool {
+			return a.IsDone() && b.IsDone() && c.IsDone() && d.IsDone()
+		})
+		normal = a.Err()
+		panicked = b.Err()
+		cancelled = c.Err()
+		wasCancelled = c.WasCancelled()
+		cancelledEarly = d.WasCancelled()
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if normal != nil {
+		t.Error("expected no error", normal)
+	}
+	if _, ok := panicked.(*carrot.PanicError); !ok {
+		t.Error("expected a panic error", panicked)
+	}
+	if cancelled != carrot.ErrCancelled || !wasCancelled {
+		t.Error("expected a cancel error", cancelled)
+	}
+	if !cancelledEarly {
+		t.Error("expected child to be cancelled before it started")
+	}
+}
+
+func TestJoin(t *testing.T) {
+	var errs []error
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Delay(3)
+		})
+		errs = append(errs, sub.Join(ctrl))
+
+		sub = ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Yield()
+			panic("oops")
+		})
+		errs = append(errs, sub.Join(ctrl))
+
+		sub = ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+		sub.Cancel()
+		errs = append(errs, sub.Join(ctrl))
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if len(errs) != 3 {
+		t.Fatal("wrong number of results", errs)
+	}
+	if errs[0] != nil {
+		t.Error("expected no error", errs[0])
+	}
+	if _, ok := errs[1].(*carrot.PanicError); !ok {
+		t.Error("expected a panic error", errs[1])
+	}
+	if errs[2] != carrot.ErrCancelled {
+		t.Error("expected a cancel error", errs[2])
+	}
+}
+
+func TestYieldMiddleware(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Yield()
+		ctrl.YieldUntil(func() bool { return true })
+		ctrl.YieldUntilVar(nil)
+	})
+	for _, name := range []string{"a", "b"} {
+		name := name
+		script.Use(func(next carrot.YieldFunc) carrot.YieldFunc {
+			return func(ctrl *carrot.Control, reason carrot.WaitReason) {
+				mu.Lock()
+				events = append(events, name+":"+reason.String())
+				mu.Unlock()
+				next(ctrl, reason)
+			}
+		})
+	}
+
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Cancel()
+	script.Update()
+
+	mu.Lock()
+	defer mu.Unlock()
+	result := strings.Join(events, " ")
+	if !strings.HasPrefix(result, "a:frame b:frame a:condition b:condition") {
+		t.Error("wrong events:", result)
+	}
+}
+
+func TestBreakpoint(t *testing.T) {
+	var frozenCount, otherCount atomic.Int32
+	var frozenID atomic.Int64
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			frozenID.Store(ctrl.ID)
+			for {
+				frozenCount.Add(1)
+				ctrl.Yield()
+			}
+		})
+		for {
+			otherCount.Add(1)
+			ctrl.Yield()
+		}
+	})
+	update := func() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	update()
+	update()
+	script.Break(frozenID.Load())
+	update()
+	count := frozenCount.Load()
+	for i := 0; i < 5; i++ {
+		update()
+	}
+	if frozenCount.Load() != count {
+		t.Error("coroutine should be frozen", frozenCount.Load(), count)
+	}
+	if otherCount.Load() < 6 {
+		t.Error("other coroutines should keep running", otherCount.Load())
+	}
+
+	script.StepOne()
+	update()
+	update()
+	if frozenCount.Load() != count+1 {
+		t.Error("coroutine should only step once", frozenCount.Load(), count)
+	}
+
+	script.Continue()
+	update()
+	update()
+	if frozenCount.Load() != count+3 {
+		t.Error("coroutine should continue", frozenCount.Load(), count)
+	}
+	script.Cancel()
+	update()
+}
+
+func TestYieldUntilAnyAll(t *testing.T) {
+	var frame atomic.Int32
+	var index int
+	var anyFrame, allFrame int32
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		index = ctrl.YieldUntilAny(
+			func() bool { return frame.Load() >= 5 },
+			func() bool { return frame.Load() >= 3 },
+		)
+		anyFrame = frame.Load()
+		ctrl.YieldUntilAll(
+			func() bool { return frame.Load() >= 4 },
+			func() bool { return frame.Load() >= 7 },
+		)
+		allFrame = frame.Load()
+	})
+
+	for !script.IsDone() {
+		frame.Add(1)
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if index != 1 || anyFrame != 3 {
+		t.Error("wrong condition satisfied", index, anyFrame)
+	}
+	if allFrame != 7 {
+		t.Error("wrong frame for all conditions", allFrame)
+	}
+}
+
+func TestYieldAtomic(t *testing.T) {
+	var ready, busy atomic.Bool
+	var state atomic.Int32
+	busy.Store(true)
+
+	var steps []string
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.YieldUntilAtomic(&ready)
+		steps = append(steps, "ready")
+		ctrl.YieldWhileAtomic(&busy)
+		steps = append(steps, "idle")
+		carrot.YieldUntilEqual(ctrl, state.Load, 3)
+		steps = append(steps, "state")
+	})
+
+	go func() {
+		time.Sleep(time.Millisecond)
+		ready.Store(true)
+		time.Sleep(time.Millisecond)
+		busy.Store(false)
+		for i := 0; i < 3; i++ {
+			time.Sleep(time.Millisecond)
+			state.Add(1)
+		}
+	}()
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if strings.Join(steps, " ") != "ready idle state" {
+		t.Error("wrong steps", steps)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var progress []float64
+	var status []string
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 1; i <= 4; i++ {
+			ctrl.SetStatus(fmt.Sprintf("loading %v", i))
+			ctrl.Yield()
+			ctrl.SetProgress(float64(i) / 4)
+		}
+		ctrl.SetProgress(2)
+		ctrl.Yield()
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+		progress = append(progress, script.Progress())
+		status = append(status, script.Status())
+	}
+
+	if progress[len(progress)-1] != 1 {
+		t.Error("progress should be clamped to 1", progress)
+	}
+	if !sort.Float64sAreSorted(progress) {
+		t.Error("progress should only increase", progress)
+	}
+	if status[len(status)-1] != "loading 4" {
+		t.Error("wrong status", status)
+	}
+}
+
+func TestSequentialTeardown(t *testing.T) {
+	var mu sync.Mutex
+	var order []string
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.SetSequentialTeardown(true)
+		for _, name := range []string{"a", "b", "c"} {
+			name := name
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				defer func() {
+					mu.Lock()
+					order = append(order, name)
+					mu.Unlock()
+				}()
+				ctrl.Abyss()
+			})
+		}
+		ctrl.Delay(2)
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if result := strings.Join(order, " "); result != "c b a" {
+		t.Error("children should be cancelled in reverse order:", result)
+	}
+}
+
+func TestRestartKeepChildren(t *testing.T) {
+	var starts, animFrames atomic.Int32
+	var otherCancelled atomic.Bool
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		if starts.Add(1) == 1 {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				for {
+					animFrames.Add(1)
+					ctrl.Yield()
+				}
+			}, carrot.KeepOnRestart())
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				defer otherCancelled.Store(true)
+				ctrl.Abyss()
+			})
+		}
+		ctrl.Abyss()
+	})
+	update := func() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	for i := 0; i < 3; i++ {
+		update()
+	}
+	script.RestartKeepChildren()
+	for i := 0; i < 3; i++ {
+		update()
+	}
+	frames := animFrames.Load()
+	update()
+	update()
+
+	if starts.Load() != 2 {
+		t.Error("coroutine should be restarted", starts.Load())
+	}
+	if !otherCancelled.Load() {
+		t.Error("other children should be cancelled")
+	}
+	if animFrames.Load() <= frames {
+		t.Error("kept child should still be running")
+	}
+	script.Cancel()
+	update()
+}
+
+func TestTransitionWithChildren(t *testing.T) {
+	var started atomic.Bool
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+		ctrl.Abyss()
+	})
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	script.Transition(func(ctrl *carrot.Control) {
+		started.Store(true)
+	})
+	for i := 0; i < 10 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !started.Load() {
+		t.Error("new coroutine should start after the children are cancelled")
+	}
+}
+
+func TestTransitionBack(t *testing.T) {
+	var current atomic.Value
+	state := func(name string) carrot.Coroutine {
+		return func(ctrl *carrot.Control) {
+			current.Store(name)
+			ctrl.Abyss()
+		}
+	}
+	script := carrot.Start(state("main"))
+	run := func() {
+		for i := 0; i < 3; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	run()
+	script.Transition(state("options"))
+	run()
+	script.Transition(state("audio"))
+	run()
+	if current.Load() != "audio" {
+		t.Error("wrong state", current.Load())
+	}
+
+	var states []string
+	for script.TransitionBack() {
+		run()
+		states = append(states, current.Load().(string))
+	}
+	if strings.Join(states, " ") != "options main" {
+		t.Error("wrong states", states)
+	}
+	script.Cancel()
+	script.Update()
+}
+
+func TestAlpha(t *testing.T) {
+	var mu sync.Mutex
+	var alphas []float64
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 4; i++ {
+			mu.Lock()
+			alphas = append(alphas, ctrl.Alpha())
+			mu.Unlock()
+			ctrl.Yield()
+		}
+	})
+	for _, alpha := range []float64{-1, 0.25, 2, -1, -1} {
+		if alpha < 0 {
+			script.Update()
+		} else {
+			script.UpdateWithAlpha(alpha)
+		}
+		time.Sleep(updateDelay)
+	}
+	for !script.IsDone() {
+		script.Update()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(alphas) != "[0 0.25 1 0]" {
+		t.Error("wrong alphas", alphas)
+	}
+}
+
+func TestInjectFaults(t *testing.T) {
+	var held atomic.Int32
+	acquire := func(ctrl *carrot.Control) {
+		held.Add(1)
+		defer held.Add(-1)
+		for i := 0; i < 10; i++ {
+			ctrl.Yield()
+		}
+	}
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for {
+			ctrl.StartAsync(acquire)
+			acquire(ctrl)
+		}
+	})
+	faults := carrottest.InjectFaults(t, script, carrottest.FaultOptions{
+		Seed:        1,
+		CancelRate:  0.01,
+		RestartRate: 0.05,
+		DelayRate:   0.1,
+	})
+
+	for i := 0; i < 500 && !script.IsDone(); i++ {
+		script.Update()
+	}
+	script.Cancel()
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if n := held.Load(); n != 0 {
+		t.Error("resources were not released", n)
+	}
+	counts := faults.Counts()
+	if counts.Restarts == 0 || counts.Delays == 0 {
+		t.Error("faults should be injected", counts)
+	}
+}
+
+func TestUpdateReentrancy(t *testing.T) {
+	var script *carrot.Script
+	var err atomic.Value
+	script = carrot.Start(func(ctrl *carrot.Control) {
+		child := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Yield()
+			script.Update()
+		})
+		err.Store(child.Join(ctrl))
+	})
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	panicErr, ok := err.Load().(*carrot.PanicError)
+	if !ok {
+		t.Fatal("calling Update() inside the coroutine should panic", err.Load())
+	}
+	msg := fmt.Sprint(panicErr.Value)
+	if !strings.Contains(msg, "coroutine-") || !strings.Contains(msg, "coroutine_test.go") {
+		t.Error("message should name the coroutine and the call site", msg)
+	}
+}
+
+func TestSleepExact(t *testing.T) {
+	clock := &manualClock{}
+	var mu sync.Mutex
+	var overshoots []time.Duration
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 3; i++ {
+			overshoot := ctrl.SleepExact(100 * time.Millisecond)
+			mu.Lock()
+			overshoots = append(overshoots, overshoot)
+			mu.Unlock()
+		}
+	}, carrot.WithClock(clock))
+
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		clock.Add(30 * time.Millisecond)
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []time.Duration{20 * time.Millisecond, 10 * time.Millisecond, 0}
+	if !reflect.DeepEqual(overshoots, expected) {
+		t.Errorf("expected overshoots %v, got %v", expected, overshoots)
+	}
+}
+
+func TestEveryFrames(t *testing.T) {
+	var mu sync.Mutex
+	var frames []int
+	frame := 0
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		calls := 0
+		ctrl.EveryFrames(3, 4, func() bool {
+			mu.Lock()
+			frames = append(frames, frame)
+			mu.Unlock()
+			calls++
+			return calls < 3
+		})
+	})
+
+	for i := 0; i < 20 && !script.IsDone(); i++ {
+		mu.Lock()
+		frame = i
+		mu.Unlock()
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	// the offset of 4 is taken modulo 3
+	expected := []int{1, 4, 7}
+	if !reflect.DeepEqual(frames, expected) {
+		t.Errorf("expected calls on frames %v, got %v", expected, frames)
+	}
+}
+
+func TestAbyssUntilWoken(t *testing.T) {
+	var resumes, wakes atomic.Int32
+	var sub carrot.SubControl
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		sub = ctrl.StartAsync(func(ctrl *carrot.Control) {
+			for {
+				ctrl.Yield()
+				resumes.Add(1)
+				if !ctrl.AbyssUntilWoken() {
+					return
+				}
+				wakes.Add(1)
+			}
+		})
+		ctrl.Abyss()
+	}, carrot.WithCancelPolicy(carrot.CancelError))
+	update := func(n int) {
+		for i := 0; i < n; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update(10)
+	if resumes.Load() != 1 || wakes.Load() != 0 {
+		t.Fatal("parked coroutine should not be resumed", resumes.Load(), wakes.Load())
+	}
+	if sub.WaitReason() != carrot.WaitWake {
+		t.Error("expected wait reason wake, got", sub.WaitReason())
+	}
+	if !script.IsIdle() {
+		t.Error("script with parked coroutines should be idle")
+	}
+
+	sub.Wake()
+	update(10)
+	if resumes.Load() != 2 || wakes.Load() != 1 {
+		t.Error("woken coroutine should resume once", resumes.Load(), wakes.Load())
+	}
+
+	script.Cancel()
+	update(5)
+	if !script.IsDone() || wakes.Load() != 1 {
+		t.Error("cancelled coroutine should stop parking")
+	}
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	var frames atomic.Int32
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			for {
+				ctrl.Yield()
+			}
+		})
+		for {
+			ctrl.Yield()
+			frames.Add(1)
+		}
+	})
+	script.Update()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				script.Update()
+			}
+		}()
+	}
+	wg.Wait()
+	time.Sleep(updateDelay)
+	if n := frames.Load(); n != 400 {
+		t.Error("every update should resume the coroutine once, got", n)
+	}
+	script.Close()
+}
+
+func TestWaitLabel(t *testing.T) {
+	var open atomic.Bool
+	var sub carrot.SubControl
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		sub = ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.YieldUntilNamed("waiting-for-door", open.Load)
+			ctrl.YieldNamed("walking")
+			ctrl.Abyss()
+		})
+		ctrl.Abyss()
+	})
+	update := func() {
+		for i := 0; i < 3; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update()
+	if label := sub.WaitLabel(); label != "waiting-for-door" {
+		t.Error("expected label waiting-for-door, got", label)
+	}
+	open.Store(true)
+	update()
+	if label := sub.WaitLabel(); label != "" {
+		t.Error("label should be cleared after the wait, got", label)
+	}
+	script.Close()
+}
+
+func TestHistory(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.YieldNamed("first")
+		ctrl.Transition(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+	}, carrot.WithHistory(64))
+	for i := 0; i < 4; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Cancel()
+	script.Update()
+	time.Sleep(updateDelay)
+
+	var kinds []carrot.HistoryKind
+	for _, event := range script.History(64) {
+		if event.Kind != carrot.HistoryResume {
+			kinds = append(kinds, event.Kind)
+		}
+	}
+	expected := []carrot.HistoryKind{
+		carrot.HistoryStart,
+		carrot.HistoryYield,
+		carrot.HistoryTransition,
+		carrot.HistoryEnd,
+		carrot.HistoryStart,
+		carrot.HistoryYield,
+		carrot.HistoryCancel,
+		carrot.HistoryEnd,
+	}
+	if !reflect.DeepEqual(kinds, expected) {
+		t.Error("expected", expected, "got", kinds)
+	}
+	if events := script.History(2); len(events) != 2 || events[1].Kind != carrot.HistoryEnd {
+		t.Error("expected the last two events, got", events)
+	}
+	if events := carrot.Start(func(ctrl *carrot.Control) {}).History(10); events != nil {
+		t.Error("history should be disabled by default, got", events)
+	}
+	script.Close()
+}
+
+func TestRand(t *testing.T) {
+	draw := func(seed int64) []int {
+		var values []int
+		var mu sync.Mutex
+		add := func(n int) {
+			mu.Lock()
+			values = append(values, n)
+			mu.Unlock()
+		}
+		script := carrot.Start(func(ctrl *carrot.Control) {
+			add(ctrl.Rand().Intn(1000))
+			sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+				add(ctrl.Rand().Intn(1000))
+			})
+			ctrl.YieldUntil(sub.IsDone)
+			add(ctrl.Rand().Intn(1000))
+		}, carrot.WithSeed(seed))
+		for i := 0; i < 5; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+		script.Close()
+		return values
+	}
+
+	first := draw(42)
+	if len(first) != 3 {
+		t.Fatal("expected 3 values, got", first)
+	}
+	if second := draw(42); !reflect.DeepEqual(first, second) {
+		t.Error("same seed should draw the same values, got", first, second)
+	}
+	if other := draw(43); reflect.DeepEqual(first, other) {
+		t.Error("different seeds should draw different values, got", first, other)
+	}
+}
+
+func TestChildrenSnapshot(t *testing.T) {
+	var snapshots [][]carrot.ChildInfo
+	var mu sync.Mutex
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.YieldNamed("short")
+		}, carrot.WithTags("short"))
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+		for {
+			mu.Lock()
+			snapshots = append(snapshots, ctrl.ChildrenSnapshot())
+			mu.Unlock()
+			ctrl.Yield()
+		}
+	})
+	for i := 0; i < 6; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	first := snapshots[0]
+	if len(first) != 2 {
+		t.Fatal("expected 2 children, got", first)
+	}
+	if first[0].State != carrot.ChildRunning || !reflect.DeepEqual(first[0].Tags, []string{"short"}) {
+		t.Error("unexpected info of the first child", first[0])
+	}
+	last := snapshots[len(snapshots)-1]
+	if len(last) != 1 || last[0].WaitReason != carrot.WaitForever {
+		t.Error("expected only the waiting child to be left, got", last)
+	}
+	if !strings.Contains(last[0].Name, "TestChildrenSnapshot") {
+		t.Error("expected the name of the coroutine function, got", last[0].Name)
+	}
+}
+
+func TestChildrenSnapshotWhileFreeing(t *testing.T) {
+	spawn := func(tag string) carrot.Coroutine {
+		return func(ctrl *carrot.Control) {
+			for {
+				for i := 0; i < 20; i++ {
+					ctrl.StartAsync(func(ctrl *carrot.Control) {
+						ctrl.Yield()
+					}, carrot.WithTags(tag), carrot.WithPriority(1))
+				}
+				ctrl.Yield()
+			}
+		}
+	}
+	var root atomic.Pointer[carrot.Control]
+	watched := carrot.Start(func(ctrl *carrot.Control) {
+		root.Store(ctrl)
+		spawn("watched")(ctrl)
+	})
+	// reuses the freed children of the watched script
+	other := carrot.Start(spawn("other"))
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			ctrl := root.Load()
+			if ctrl == nil {
+				continue
+			}
+			for _, info := range ctrl.ChildrenSnapshot() {
+				if !reflect.DeepEqual(info.Tags, []string{"watched"}) {
+					t.Error("snapshot has a reused child", info)
+				}
+			}
+		}
+	}()
+	for i := 0; i < 20; i++ {
+		watched.Update()
+		other.Update()
+	}
+	close(stop)
+	<-done
+	watched.Close()
+	other.Close()
+}
+
+func TestStartAsyncTimeout(t *testing.T) {
+	clock := &manualClock{}
+	var slow, fast carrot.SubControl
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		slow = ctrl.StartAsyncTimeout(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		}, time.Second)
+		fast = ctrl.StartAsyncTimeout(func(ctrl *carrot.Control) {
+			ctrl.Yield()
+		}, time.Second)
+		ctrl.Abyss()
+	}, carrot.WithClock(clock))
+	update := func() {
+		for i := 0; i < 3; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update()
+	if slow.IsDone() || slow.TimedOut() {
+		t.Error("the slow child should still be running")
+	}
+	clock.Add(2 * time.Second)
+	update()
+	if !slow.IsDone() || !slow.TimedOut() {
+		t.Error("the slow child should be cancelled after its timeout")
+	}
+	if !fast.IsDone() || fast.TimedOut() {
+		t.Error("the fast child should finish without timing out")
+	}
+	script.Close()
+}
+
+func TestYieldUntilTransition(t *testing.T) {
+	var changes atomic.Int32
+	var idle, alert carrot.Coroutine
+	idle = func(ctrl *carrot.Control) {
+		ctrl.Delay(2)
+		ctrl.Transition(alert)
+	}
+	alert = func(ctrl *carrot.Control) {
+		ctrl.Delay(2)
+	}
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		enemy := ctrl.StartAsync(idle)
+		for ctrl.YieldUntilTransition(enemy) {
+			changes.Add(1)
+		}
+		ctrl.Checkpoint("enemy ended")
+	})
+	for i := 0; i < 12; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if n := changes.Load(); n != 1 {
+		t.Error("expected 1 transition, got", n)
+	}
+	carrottest.AssertReached(t, script, "enemy ended")
+}
+
+func TestYieldUntilTransitionReused(t *testing.T) {
+	var changed atomic.Bool
+	var done atomic.Bool
+	watcher := carrot.Start(func(ctrl *carrot.Control) {
+		enemy := ctrl.StartAsync(func(ctrl *carrot.Control) {})
+		changed.Store(ctrl.YieldUntilTransition(enemy))
+		done.Store(true)
+	}, carrot.Serialized())
+	// the ended enemy is freed, and its control is
+	// likely reused by the other script's coroutine
+	other := carrot.Start(func(ctrl *carrot.Control) {
+		for {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				ctrl.Transition(func(ctrl *carrot.Control) { ctrl.Abyss() })
+				ctrl.Abyss()
+			})
+			ctrl.Yield()
+		}
+	}, carrot.Serialized())
+	defer other.Cancel()
+
+	for i := 0; i < 10 && !done.Load(); i++ {
+		watcher.Update()
+		other.Update()
+	}
+	if !done.Load() {
+		t.Fatal("watcher should be done")
+	}
+	if changed.Load() {
+		t.Error("transitions of a reused control should not count")
+	}
+}
+
+func TestNextInput(t *testing.T) {
+	var mu sync.Mutex
+	var received []any
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for {
+			event := ctrl.NextInput()
+			mu.Lock()
+			received = append(received, event)
+			mu.Unlock()
+		}
+	})
+	script.Update()
+	time.Sleep(updateDelay)
+
+	script.PostInput("left")
+	script.PostInput("jump")
+	mu.Lock()
+	if len(received) != 0 {
+		t.Error("inputs should only be delivered on the next update, got", received)
+	}
+	mu.Unlock()
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.PostInput(3)
+	script.Update()
+	time.Sleep(updateDelay)
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []any{"left", "jump", 3}
+	if !reflect.DeepEqual(received, expected) {
+		t.Error("expected", expected, "got", received)
+	}
+	script.Close()
+}
+
+func TestYieldUntilEdge(t *testing.T) {
+	var held atomic.Bool
+	held.Store(true)
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.YieldUntilRisingEdge(held.Load)
+		ctrl.Checkpoint("pressed")
+		ctrl.YieldUntilFallingEdge(held.Load)
+		ctrl.Checkpoint("released")
+	})
+	update := func() {
+		for i := 0; i < 3; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update()
+	carrottest.AssertNotReached(t, script, "pressed")
+	held.Store(false)
+	update()
+	carrottest.AssertNotReached(t, script, "pressed")
+	held.Store(true)
+	update()
+	carrottest.AssertReached(t, script, "pressed")
+	carrottest.AssertNotReached(t, script, "released")
+	held.Store(false)
+	update()
+	carrottest.AssertReached(t, script, "released")
+}
+
+func TestYieldUntilCombo(t *testing.T) {
+	clock := &manualClock{}
+	var pressed atomic.Bool
+	var results []bool
+	var mu sync.Mutex
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for {
+			ok := ctrl.YieldUntilCombo(
+				carrot.ComboStep{Edge: pressed.Load},
+				carrot.ComboStep{Edge: pressed.Load, Within: 300 * time.Millisecond},
+			)
+			mu.Lock()
+			results = append(results, ok)
+			mu.Unlock()
+		}
+	}, carrot.WithClock(clock))
+	frame := func(down bool, elapsed time.Duration) {
+		pressed.Store(down)
+		clock.Add(elapsed)
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	frame(false, 0)
+	// double tap
+	frame(true, 0)
+	frame(false, 100*time.Millisecond)
+	frame(true, 100*time.Millisecond)
+	frame(false, 0)
+	// too slow
+	frame(true, 0)
+	frame(false, 100*time.Millisecond)
+	frame(false, 300*time.Millisecond)
+	frame(false, 0)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(results, []bool{true, false}) {
+		t.Error("expected a double tap then a timeout, got", results)
+	}
+	script.Close()
+}
+
+func TestStartSiblingFromChild(t *testing.T) {
+	var spawned atomic.Int32
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 3; i++ {
+			ctrl.StartAsync(func(child *carrot.Control) {
+				for j := 0; j < 3; j++ {
+					child.Parent().(*carrot.Control).StartAsync(func(*carrot.Control) {
+						spawned.Add(1)
+					})
+					child.Yield()
+				}
+			})
+		}
+		ctrl.Abyss()
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		// no sleeps, so that the next update takes the
+		// lock again before the child gets to it
+		for i := 0; i < 1000; i++ {
+			script.Update()
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("starting a sibling from a child should not deadlock the update")
+	}
+	if n := spawned.Load(); n != 9 {
+		t.Error("all siblings should run", n)
+	}
+	script.Close()
+}
@@ -0,0 +1,52 @@
+package carrot
+
+import (
+	"context"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// A Ctl is the subset of *Control methods that are
+// commonly used inside coroutines. Functions that only need
+// these methods can accept a Ctl instead of *Control,
+// so that they can be unit tested with a fake, such as
+// carrottest.FakeControl, without running a real script.
+//
+//	func patrol(ctrl carrot.Ctl) { ... }
+//	script := carrot.Start(func(ctrl *carrot.Control) { patrol(ctrl) })
+type Ctl interface {
+	Yield()
+	MaybeYield(time.Duration)
+	Delay(int)
+	Sleep(time.Duration)
+	SleepExact(time.Duration) time.Duration
+	YieldWhile(func() bool)
+	YieldUntil(func() bool)
+	YieldWhileVar(*bool)
+	YieldUntilVar(*bool)
+	YieldWhileAtomic(*atomic.Bool)
+	YieldUntilAtomic(*atomic.Bool)
+	YieldUntilAny(...func() bool) int
+	YieldUntilAll(...func() bool)
+	Abyss()
+	AwaitContext(func(ctx context.Context) error) error
+	AfterChan(time.Duration) <-chan time.Time
+
+	StartAsync(Coroutine, ...Option) SubControl
+	Cancel()
+	Restart()
+	Transition(Coroutine)
+
+	Now() time.Time
+	Alpha() float64
+	Rand() *rand.Rand
+	Value(key any) any
+	SetValue(key, value any)
+	Checkpoint(string)
+	SetProgress(float64)
+	SetStatus(string)
+	Logf(string, ...any)
+}
+
+var _ Ctl = (*Control)(nil)
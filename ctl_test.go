@@ -0,0 +1,49 @@
+package carrot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+	"github.com/nvlled/carrot/carrottest"
+)
+
+func waitForDoor(ctrl carrot.Ctl, isOpen func() bool) {
+	ctrl.Sleep(time.Second)
+	ctrl.YieldUntil(isOpen)
+	ctrl.Checkpoint("entered")
+}
+
+func TestFakeControl(t *testing.T) {
+	fake := carrottest.NewFakeControl()
+	start := fake.Now()
+	open := false
+	fake.OnYield = func() {
+		if fake.Frames() >= 5 {
+			open = true
+		}
+	}
+
+	if !fake.Run(func(ctrl carrot.Ctl) { waitForDoor(ctrl, func() bool { return open }) }) {
+		t.Fatal("coroutine should have finished")
+	}
+	if fake.Frames() != 5 {
+		t.Error("wrong number of frames", fake.Frames())
+	}
+	if elapsed := fake.Now().Sub(start); elapsed != time.Second {
+		t.Error("wrong elapsed time", elapsed)
+	}
+	calls := fake.Calls()
+	if len(calls) != 3 || calls[0] != "Sleep(1s)" || calls[1] != "YieldUntil()" {
+		t.Error("wrong calls", calls)
+	}
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		waitForDoor(ctrl, func() bool { return true })
+	}, carrot.WithClock(fixedClock{now: time.Unix(0, 0)}))
+	script.Update()
+	time.Sleep(updateDelay)
+	script.Advance(time.Second, 0)
+	time.Sleep(updateDelay)
+	carrottest.AssertReached(t, script, "entered")
+}
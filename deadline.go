@@ -0,0 +1,97 @@
+package carrot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Returned by script.UpdateDeadline(), wrapped
+// in a *DeadlineError.
+var ErrDeadlineExceeded = errors.New("script update deadline exceeded")
+
+// A DeadlineError tells which coroutine did not yield
+// in time during script.UpdateDeadline().
+type DeadlineError struct {
+	// ID of the coroutine that was still running.
+	ID int64
+	// Name of the coroutine function.
+	Name string
+}
+
+func (err *DeadlineError) Error() string {
+	return fmt.Sprintf("%v: coroutine %v (%v) did not yield",
+		ErrDeadlineExceeded, err.ID, err.Name)
+}
+
+func (err *DeadlineError) Unwrap() error {
+	return ErrDeadlineExceeded
+}
+
+// Same as Update(), but doesn't wait past the duration for
+// coroutines that are still running since they were last
+// resumed, which would otherwise block the game loop for
+// as long as the coroutine doesn't yield. Such coroutines,
+// along with their child coroutines, are skipped until
+// a later update, and a *DeadlineError describing the first
+// of them is returned. Returns nil if all coroutines were
+// updated in time.
+//
+//	if err := script.UpdateDeadline(2 * time.Millisecond); err != nil {
+//		log.Println(err) // the coroutine is still running
+//	}
+func (script *Script) UpdateDeadline(d time.Duration) error {
+	script.lockUpdate()
+	defer script.updateMu.Unlock()
+	return script.updateDeadline(d)
+}
+
+func (script *Script) updateDeadline(d time.Duration) error {
+	ctrl := script.baseControl
+	ctrl.deadline.Store(time.Now().Add(d).UnixNano())
+	script.update(nil)
+	ctrl.deadline.Store(0)
+	if err := ctrl.overrun; err != nil {
+		ctrl.overrun = nil
+		return err
+	}
+	return nil
+}
+
+// Same as Update(), but only resumes the coroutines that
+// are already waiting at a yield point, without blocking.
+// Returns false if a coroutine was still running since it
+// was last resumed, in which case it's skipped along with
+// its child coroutines, or if the script is being updated by
+// another goroutine, in which case nothing is done. Useful for
+// opportunistic updates from loops that can't afford to block.
+// See also UpdateDeadline().
+func (script *Script) TryUpdate() bool {
+	if !script.updateMu.TryLock() {
+		return false
+	}
+	defer script.updateMu.Unlock()
+	return script.updateDeadline(0) == nil
+}
+
+// Resumes the coroutine, giving up if the deadline of
+// UpdateDeadline() passes first. Returns false if it gave up.
+func (ctrl *Control) resumeLeft() bool {
+	root := ctrl.root()
+	deadline := root.deadline.Load()
+	if deadline == 0 {
+		ctrl.yieldLeft()
+		return true
+	}
+	if ctrl.kanata.YieldLeftUntil(time.Unix(0, deadline)) {
+		return true
+	}
+	if root.overrun == nil {
+		root.overrun = &DeadlineError{
+			ID:   ctrl.ID,
+			Name: coroutineName(ctrl.coroutine),
+		}
+	}
+	ctrl.Logf("deadline exceeded")
+	return false
+}
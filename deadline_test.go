@@ -0,0 +1,75 @@
+package carrot_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestUpdateDeadline(t *testing.T) {
+	block := make(chan struct{})
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Yield()
+		<-block
+		ctrl.Yield()
+	})
+	for i := 0; i < 2; i++ {
+		if err := script.UpdateDeadline(time.Second); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(updateDelay)
+	}
+
+	start := time.Now()
+	err := script.UpdateDeadline(10 * time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Error("update should not wait past the deadline", elapsed)
+	}
+	var deadlineErr *carrot.DeadlineError
+	if !errors.Is(err, carrot.ErrDeadlineExceeded) || !errors.As(err, &deadlineErr) || deadlineErr.ID == 0 {
+		t.Fatal("expected a deadline error, got", err)
+	}
+
+	close(block)
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		if err := script.UpdateDeadline(time.Second); err != nil {
+			t.Error(err)
+		}
+		time.Sleep(updateDelay)
+	}
+	if !script.IsDone() {
+		t.Error("script should end once unblocked")
+	}
+}
+
+func TestTryUpdate(t *testing.T) {
+	block := make(chan struct{})
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Yield()
+		<-block
+		ctrl.Yield()
+	})
+	// give the goroutine of the script time to start
+	time.Sleep(updateDelay)
+	for i := 0; i < 2; i++ {
+		if !script.TryUpdate() {
+			t.Fatal("parked coroutine should be updated")
+		}
+		time.Sleep(updateDelay)
+	}
+	if script.TryUpdate() {
+		t.Error("busy coroutine should not be updated")
+	}
+
+	close(block)
+	time.Sleep(updateDelay)
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.TryUpdate()
+		time.Sleep(updateDelay)
+	}
+	if !script.IsDone() {
+		t.Error("script should end once unblocked")
+	}
+}
@@ -0,0 +1,73 @@
+package carrot
+
+import (
+	"sync"
+	"time"
+)
+
+// Returns a coroutine that waits for the duration before
+// running the given coroutine. If the returned coroutine
+// is started again while waiting, for instance with
+// Transition(), Restart() or StartAsync(), the earlier
+// trigger is dropped and the wait starts over. This is
+// useful for coalescing repeated triggers into one run
+// after they stop.
+//
+//	search := carrot.Debounce(300*time.Millisecond, searchCoroutine)
+//	onInput(func() { script.Transition(search) })
+func Debounce(duration time.Duration, coroutine Coroutine) Coroutine {
+	var mu sync.Mutex
+	var generation int
+	return func(ctrl *Control) {
+		mu.Lock()
+		generation++
+		gen := generation
+		mu.Unlock()
+
+		ctrl.Sleep(duration)
+
+		mu.Lock()
+		superseded := gen != generation
+		mu.Unlock()
+		if superseded {
+			return
+		}
+		coroutine(ctrl)
+	}
+}
+
+// Returns a coroutine that runs the given coroutine
+// at most once for every duration. The first trigger
+// runs immediately, the triggers after it within the same
+// duration are coalesced into one run once the duration
+// has passed since the last run.
+//
+//	attack := carrot.Throttle(time.Second, attackCoroutine)
+//	onButton(func() { script.Transition(attack) })
+func Throttle(duration time.Duration, coroutine Coroutine) Coroutine {
+	var mu sync.Mutex
+	var generation int
+	var lastRun time.Time
+	return func(ctrl *Control) {
+		mu.Lock()
+		generation++
+		gen := generation
+		wait := lastRun.Add(duration).Sub(ctrl.Now())
+		mu.Unlock()
+
+		if wait > 0 {
+			ctrl.Sleep(wait)
+		}
+
+		mu.Lock()
+		superseded := gen != generation
+		if !superseded {
+			lastRun = ctrl.Now()
+		}
+		mu.Unlock()
+		if superseded {
+			return
+		}
+		coroutine(ctrl)
+	}
+}
@@ -0,0 +1,60 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestDebounce(t *testing.T) {
+	var runs atomic.Int32
+	debounced := carrot.Debounce(5*time.Millisecond, func(ctrl *carrot.Control) {
+		runs.Add(1)
+	})
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 3; i++ {
+			ctrl.StartAsync(debounced)
+			ctrl.Yield()
+		}
+		ctrl.Sleep(20 * time.Millisecond)
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if runs.Load() != 1 {
+		t.Error("expected repeated triggers to run once, got", runs.Load())
+	}
+}
+
+func TestThrottle(t *testing.T) {
+	var runs atomic.Int32
+	throttled := carrot.Throttle(10*time.Millisecond, func(ctrl *carrot.Control) {
+		runs.Add(1)
+	})
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 4; i++ {
+			ctrl.StartAsync(throttled)
+			ctrl.Yield()
+		}
+		if runs.Load() != 1 {
+			t.Error("expected first trigger to run immediately, got", runs.Load())
+		}
+		ctrl.Sleep(30 * time.Millisecond)
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if runs.Load() != 2 {
+		t.Error("expected triggers to be coalesced into two runs, got", runs.Load())
+	}
+}
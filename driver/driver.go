@@ -0,0 +1,107 @@
+// Package driver runs carrot scripts from the frame callback of
+// a desktop UI toolkit, such as GLFW or Fyne, so that UI flows
+// can be scripted with coroutines while the UI is only ever
+// touched from the UI thread.
+//
+// The package does not depend on any toolkit, the driver
+// only needs Frame() to be called on every frame.
+//
+//	Example with GLFW:
+//	runtime.LockOSThread()
+//	d := driver.New(nil)
+//	for !window.ShouldClose() {
+//		glfw.PollEvents()
+//		d.Frame()
+//		draw()
+//		window.SwapBuffers()
+//	}
+//
+//	Example with Fyne:
+//	d := driver.New(nil)
+//	anim := fyne.NewAnimation(time.Hour, func(float32) { d.Frame() })
+//	anim.RepeatCount = fyne.AnimationRepeatForever
+//	anim.Start()
+//
+//	// in a coroutine started with d.Manager.Start()
+//	driver.Call(d, ctrl, func() struct{} {
+//		label.SetText("loading")
+//		return struct{}{}
+//	})
+package driver
+
+import (
+	"sync"
+
+	"github.com/nvlled/carrot"
+)
+
+// A Driver updates the scripts of a manager on every frame,
+// and runs the functions queued with RunOnUpdate() on the
+// thread that calls Frame(), the UI thread.
+type Driver struct {
+	// The scripts that are updated.
+	Manager *carrot.Manager
+
+	mu    sync.Mutex
+	queue []func()
+}
+
+// Creates a driver for the manager.
+// A nil manager creates a new one.
+func New(manager *carrot.Manager) *Driver {
+	if manager == nil {
+		manager = carrot.NewManager()
+	}
+	return &Driver{Manager: manager}
+}
+
+// Runs the queued functions, then updates the scripts.
+// Must be called on the UI thread, once per frame.
+func (d *Driver) Frame() {
+	d.runQueue()
+	d.Manager.Update()
+}
+
+// Queues fn to be called on the UI thread on the next
+// Frame(), before the scripts are updated. Can be called from
+// any thread, for instance from a coroutine or a worker.
+//
+//	Note: fn must not block, since it runs inside Frame().
+func (d *Driver) RunOnUpdate(fn func()) {
+	d.mu.Lock()
+	d.queue = append(d.queue, fn)
+	d.mu.Unlock()
+}
+
+// Returns the number of functions waiting
+// for the next Frame().
+func (d *Driver) Queued() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.queue)
+}
+
+// functions queued while running are
+// called on the next frame
+func (d *Driver) runQueue() {
+	d.mu.Lock()
+	queue := d.queue
+	d.queue = nil
+	d.mu.Unlock()
+	for _, fn := range queue {
+		fn()
+	}
+}
+
+// Calls fn on the UI thread on the next Frame(), with the
+// other functions queued with RunOnUpdate(), and yields until
+// it is done. Returns the result of fn. Since the queue runs
+// before the scripts are updated, a script of d.Manager gets
+// the result within the same Frame(). See carrot.CallOn().
+// Panics when cancelled, or returns carrot.ErrCancelled if the
+// cancel policy is not CancelPanic.
+//
+//	Note: fn must not block, since it runs inside Frame().
+func Call[T any](d *Driver, ctrl *carrot.Control, fn func() T) (T, error) {
+	return carrot.CallOn(ctrl, d.RunOnUpdate, fn)
+}
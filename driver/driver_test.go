@@ -0,0 +1,63 @@
+package driver_test
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+	"github.com/nvlled/carrot/driver"
+)
+
+func TestCall(t *testing.T) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	d := driver.New(nil)
+	// only touched on the UI thread
+	label := ""
+	var result atomic.Value
+	d.Manager.Start(func(ctrl *carrot.Control) {
+		text, _ := driver.Call(d, ctrl, func() string {
+			label = "loading"
+			return label
+		})
+		result.Store(text)
+	})
+	for i := 0; i < 5; i++ {
+		d.Frame()
+		time.Sleep(10 * time.Millisecond)
+	}
+	if label != "loading" || result.Load() != "loading" {
+		t.Error("expected the label to be set on the UI thread, got", label, result.Load())
+	}
+	if n := d.Queued(); n != 0 {
+		t.Error("expected an empty queue, got", n)
+	}
+}
+
+func TestCallQueued(t *testing.T) {
+	d := driver.New(nil)
+	// set while Frame() runs, fn must only be called then
+	var inFrame, calledInFrame atomic.Bool
+	var result atomic.Value
+	// not a script of d.Manager, updated separately
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		text, _ := driver.Call(d, ctrl, func() string {
+			calledInFrame.Store(inFrame.Load())
+			return "queued"
+		})
+		result.Store(text)
+	})
+	for i := 0; i < 5 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(10 * time.Millisecond)
+		inFrame.Store(true)
+		d.Frame()
+		inFrame.Store(false)
+	}
+	if !calledInFrame.Load() || result.Load() != "queued" {
+		t.Error("expected fn to be called by Frame(), got", calledInFrame.Load(), result.Load())
+	}
+}
@@ -0,0 +1,85 @@
+package carrot
+
+import "time"
+
+// Yields until fn changes from false to true. Unlike YieldUntil(),
+// this does not return right away when fn is already true, it waits
+// for fn to become false and then true again, for instance to wait
+// until a button is pressed rather than held. fn is checked once
+// per frame, so changes that are undone within the same
+// frame are missed.
+// Panics when cancelled.
+//
+//	ctrl.YieldUntilRisingEdge(input.JumpHeld)
+func (ctrl *Control) YieldUntilRisingEdge(fn func() bool) {
+	prev := fn()
+	ctrl.YieldUntil(func() bool {
+		value := fn()
+		rising := value && !prev
+		prev = value
+		return rising
+	})
+}
+
+// Yields until fn changes from true to false, for instance
+// to wait until a button is released. See YieldUntilRisingEdge().
+// Panics when cancelled.
+func (ctrl *Control) YieldUntilFallingEdge(fn func() bool) {
+	prev := fn()
+	ctrl.YieldUntil(func() bool {
+		value := fn()
+		falling := !value && prev
+		prev = value
+		return falling
+	})
+}
+
+// A ComboStep is a step of YieldUntilCombo().
+type ComboStep struct {
+	// The condition whose rising edge completes the step,
+	// see YieldUntilRisingEdge().
+	Edge func() bool
+
+	// Maximum time to complete the step, measured from the
+	// end of the previous step, or from the start of the
+	// combo for the first step. Zero means no limit.
+	Within time.Duration
+}
+
+// Yields until the rising edges of the steps happen in order,
+// each one within the time limit of its step, for inputs such
+// as double-taps or quarter-circle motions. Returns false as
+// soon as a step is not completed in time. The time is measured
+// with the clock of the script, see ctrl.Now(). Returns false
+// if cancelled with a cancel policy that does not panic.
+// Panics when cancelled.
+//
+//	doubleTap := ctrl.YieldUntilCombo(
+//		carrot.ComboStep{Edge: input.Pressed},
+//		carrot.ComboStep{Edge: input.Pressed, Within: 300 * time.Millisecond},
+//	)
+func (ctrl *Control) YieldUntilCombo(steps ...ComboStep) bool {
+	for _, step := range steps {
+		var deadline time.Time
+		if step.Within > 0 {
+			deadline = ctrl.Now().Add(step.Within)
+		}
+		ctrl.beginWait()
+		prev := step.Edge()
+		for {
+			value := step.Edge()
+			if value && !prev {
+				break
+			}
+			prev = value
+			if !deadline.IsZero() && !ctrl.Now().Before(deadline) {
+				return false
+			}
+			ctrl.yield(WaitCondition)
+			if ctrl.cancelReturns() {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,35 @@
+package carrot
+
+// Starts a child coroutine that updates the script once per
+// frame, as if the script's coroutine were a child of ctrl.
+// The child ends when the script is done. When the child is
+// cancelled, for instance because ctrl is cancelled, the
+// script is cancelled too and updated until it's done,
+// resuming it first if it's paused.
+// Useful for reusing behaviours that are packaged as scripts.
+//
+//	script := carrot.Start(patrol)
+//	sub := ctrl.Embed(script)
+//	sub.Join(ctrl)
+//
+//	Note: The script must not be updated elsewhere,
+//	such as by a Manager, while it's embedded.
+func (ctrl *Control) Embed(script *Script, options ...Option) SubControl {
+	return ctrl.StartAsync(func(ctrl *Control) {
+		defer func() {
+			if script.IsDone() || script.closed {
+				return
+			}
+			script.lockUpdate()
+			defer script.updateMu.Unlock()
+			if !script.closed {
+				script.finish()
+			}
+		}()
+		ctrl.beginWait()
+		for !script.IsDone() && !script.closed && !ctrl.cancelReturns() {
+			script.Update()
+			ctrl.yield(WaitCondition)
+		}
+	}, options...)
+}
@@ -0,0 +1,91 @@
+package carrot_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestEmbed(t *testing.T) {
+	var steps atomic.Int32
+	inner := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 5; i++ {
+			steps.Add(1)
+			ctrl.Yield()
+		}
+	})
+	var joined atomic.Bool
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Embed(inner).Join(ctrl)
+		joined.Store(true)
+	})
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !joined.Load() || !inner.IsDone() || steps.Load() != 5 {
+		t.Error("embedded script should run to completion", steps.Load())
+	}
+}
+
+func TestEmbedCancel(t *testing.T) {
+	var cleanedUp atomic.Bool
+	inner := carrot.Start(func(ctrl *carrot.Control) {
+		defer cleanedUp.Store(true)
+		ctrl.Abyss()
+	})
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Embed(inner)
+		ctrl.Abyss()
+	})
+	for i := 0; i < 5; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if inner.IsDone() {
+		t.Fatal("embedded script should still be running")
+	}
+
+	script.Cancel()
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !script.IsDone() || !inner.IsDone() || !cleanedUp.Load() {
+		t.Error("cancelling the parent should cancel the embedded script")
+	}
+	if !errors.Is(inner.Err(), carrot.ErrCancelled) {
+		t.Error("embedded script should be cancelled")
+	}
+}
+
+func TestEmbedCancelPaused(t *testing.T) {
+	var cleanedUp atomic.Bool
+	inner := carrot.Start(func(ctrl *carrot.Control) {
+		defer cleanedUp.Store(true)
+		ctrl.Abyss()
+	})
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Embed(inner)
+		ctrl.Abyss()
+	})
+	group := carrot.NewGroup()
+	group.Add(inner)
+	for i := 0; i < 5; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	group.PauseAll()
+
+	script.Cancel()
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !script.IsDone() || !inner.IsDone() || !cleanedUp.Load() {
+		t.Error("cancelling the parent should cancel the paused embedded script")
+	}
+}
@@ -0,0 +1,86 @@
+package carrot
+
+import "sync"
+
+// An Outbox holds the values emitted by the coroutines of
+// a script until they are collected on the main thread
+// with Drain(). Useful for scripts that generate commands
+// for the engine, such as render operations or sound cues.
+// See Emitter().
+type Outbox[T any] struct {
+	mu    sync.Mutex
+	items []T
+}
+
+type outboxKey[T any] struct{}
+
+// Returns the outbox of the script for values of type T,
+// creating it if needed. All coroutines in the same script
+// share the same outbox for a given type.
+//
+//	Example:
+//	em := carrot.Emitter[SoundCue](ctrl)
+//	em.Emit(SoundCue{Name: "jump"})
+//	// on the main thread, after script.Update()
+//	for _, cue := range carrot.Drain[SoundCue](script) { ... }
+func Emitter[T any](ctrl *Control) *Outbox[T] {
+	root := ctrl.root()
+	root.valuesMu.Lock()
+	defer root.valuesMu.Unlock()
+	if em, ok := root.values[outboxKey[T]{}].(*Outbox[T]); ok {
+		return em
+	}
+	if root.values == nil {
+		root.values = map[any]any{}
+	}
+	em := &Outbox[T]{}
+	root.values[outboxKey[T]{}] = em
+	return em
+}
+
+// Adds a value to the outbox. Does not yield.
+// Can be called from any thread.
+func (em *Outbox[T]) Emit(value T) {
+	em.mu.Lock()
+	em.items = append(em.items, value)
+	em.mu.Unlock()
+}
+
+// Removes and returns all the values emitted so far.
+func (em *Outbox[T]) Drain() []T {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	items := em.items
+	em.items = nil
+	return items
+}
+
+// Removes and returns the values of type T emitted by the
+// coroutines of the script since the last call.
+// When called after every Update(), this returns
+// the values emitted during the update.
+// Returns nil if nothing was emitted.
+func Drain[T any](script *Script) []T {
+	root := script.baseControl
+	root.valuesMu.RLock()
+	em, _ := root.values[outboxKey[T]{}].(*Outbox[T])
+	root.valuesMu.RUnlock()
+	if em == nil {
+		return nil
+	}
+	return em.Drain()
+}
+
+// an Outbox of any type, see script.drainAll()
+type anyOutbox interface {
+	drainAny() []any
+}
+
+func (em *Outbox[T]) drainAny() []any {
+	items := em.Drain()
+	values := make([]any, len(items))
+	for i, item := range items {
+		values[i] = item
+	}
+	return values
+}
@@ -0,0 +1,44 @@
+package carrot_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestEmitter(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		child := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			carrot.Emitter[string](ctrl).Emit("child")
+		})
+		em := carrot.Emitter[int](ctrl)
+		for i := 0; i < 3; i++ {
+			em.Emit(i)
+			em.Emit(i * 10)
+			ctrl.Yield()
+		}
+		child.Join(ctrl)
+	})
+
+	var frames []string
+	var strs []string
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+		if values := carrot.Drain[int](script); values != nil {
+			frames = append(frames, fmt.Sprint(values))
+		}
+		strs = append(strs, carrot.Drain[string](script)...)
+	}
+	if fmt.Sprint(frames) != "[[0 0] [1 10] [2 20]]" {
+		t.Error("wrong values", frames)
+	}
+	if fmt.Sprint(strs) != "[child]" {
+		t.Error("wrong values", strs)
+	}
+	if carrot.Drain[float64](script) != nil {
+		t.Error("nothing was emitted")
+	}
+}
@@ -0,0 +1,82 @@
+package carrot
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// An ErrGroup is a collection of child coroutines working
+// on parts of the same task, similar to golang.org/x/sync/errgroup.
+// The first child that returns an error or panics cancels
+// the other children. See ctrl.Group().
+type ErrGroup struct {
+	// children are started under the holder,
+	// so that they can be cancelled together
+	holder SubControl
+
+	running atomic.Int32
+	failed  atomic.Bool
+	cancel  sync.Once
+	err     error
+	errMu   sync.Mutex
+}
+
+// Creates a new ErrGroup for starting child coroutines.
+//
+//	g := ctrl.Group()
+//	g.Go(loadLevel)
+//	g.Go(loadSounds)
+//	if err := g.Wait(ctrl); err != nil { ... }
+func (ctrl *Control) Group() *ErrGroup {
+	return &ErrGroup{
+		holder: ctrl.StartAsync(func(ctrl *Control) {
+			ctrl.Abyss()
+		}),
+	}
+}
+
+// Starts a new child coroutine in the group. If fn returns
+// an error or panics, the other children are cancelled.
+func (group *ErrGroup) Go(fn func(*Control) error, options ...Option) {
+	group.running.Add(1)
+	group.holder.(*Control).StartAsync(func(ctrl *Control) {
+		defer group.running.Add(-1)
+		defer func() {
+			if err := recover(); err != nil {
+				if err == ErrCancelled {
+					panic(err)
+				}
+				group.fail(&PanicError{Value: err})
+			}
+		}()
+		if err := fn(ctrl); err != nil {
+			group.fail(err)
+		}
+	}, options...)
+}
+
+// Yields until all children are done, or until one of
+// them fails and the rest are cancelled. Returns the first
+// error, or nil if all children finished normally.
+// Panics when cancelled.
+func (group *ErrGroup) Wait(ctrl *Control) error {
+	ctrl.YieldUntil(func() bool {
+		return group.running.Load() == 0 || group.failed.Load()
+	})
+	group.cancel.Do(group.holder.Cancel)
+	group.holder.Join(ctrl)
+
+	group.errMu.Lock()
+	defer group.errMu.Unlock()
+	return group.err
+}
+
+func (group *ErrGroup) fail(err error) {
+	group.errMu.Lock()
+	if group.err == nil {
+		group.err = err
+	}
+	group.errMu.Unlock()
+	group.failed.Store(true)
+	group.cancel.Do(group.holder.Cancel)
+}
@@ -0,0 +1,67 @@
+package carrot_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestErrGroup(t *testing.T) {
+	errFailed := errors.New("failed")
+	var sum atomic.Int32
+	var okErr, failErr, panicErr error
+	var siblingFinished atomic.Bool
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		g := ctrl.Group()
+		for i := 1; i <= 3; i++ {
+			n := int32(i)
+			g.Go(func(ctrl *carrot.Control) error {
+				ctrl.Delay(int(n))
+				sum.Add(n)
+				return nil
+			})
+		}
+		okErr = g.Wait(ctrl)
+
+		g = ctrl.Group()
+		g.Go(func(ctrl *carrot.Control) error {
+			ctrl.Delay(2)
+			return errFailed
+		})
+		g.Go(func(ctrl *carrot.Control) error {
+			ctrl.Delay(100)
+			siblingFinished.Store(true)
+			return nil
+		})
+		failErr = g.Wait(ctrl)
+
+		g = ctrl.Group()
+		g.Go(func(ctrl *carrot.Control) error {
+			ctrl.Yield()
+			panic("oops")
+		})
+		panicErr = g.Wait(ctrl)
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if okErr != nil || sum.Load() != 6 {
+		t.Error("all children should finish", okErr, sum.Load())
+	}
+	if failErr != errFailed {
+		t.Error("expected the first error", failErr)
+	}
+	if siblingFinished.Load() {
+		t.Error("sibling should be cancelled")
+	}
+	if _, ok := panicErr.(*carrot.PanicError); !ok {
+		t.Error("expected a panic error", panicErr)
+	}
+}
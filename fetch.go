@@ -0,0 +1,100 @@
+package carrot
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Sends the request with the client on the default worker pool,
+// and yields until the response headers are received. The request
+// is sent with ctrl.Context(), so it is aborted when the coroutine
+// is cancelled or ends. A nil client uses http.DefaultClient.
+// Panics when cancelled, or returns ErrCancelled if the cancel
+// policy is not CancelPanic. A response that arrives after the
+// coroutine was cancelled is closed.
+//
+// The response body should be read with NewReader(), so that
+// the coroutine yields while waiting for the data instead of
+// blocking the Update(), and closed once done.
+//
+//	Example:
+//	req, _ := http.NewRequest("GET", url, nil)
+//	resp, err := carrot.Fetch(ctrl, nil, req)
+//	if err != nil { ... }
+//	defer resp.Body.Close()
+//	data, err := io.ReadAll(carrot.NewReader(ctrl, resp.Body))
+func Fetch(ctrl *Control, client *http.Client, req *http.Request) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	ctx := ctrl.Context()
+	req = req.WithContext(ctx)
+	return awaitTask(ctrl, func() (*http.Response, error) {
+		return client.Do(req)
+	}, func(resp *http.Response) {
+		// the coroutine is gone and won't close it
+		if resp != nil {
+			resp.Body.Close()
+		}
+	})
+}
+
+// A Reader reads from another reader on the default
+// worker pool, yielding while waiting for the data.
+// See NewReader().
+type Reader struct {
+	ctrl    *Control
+	r       io.Reader
+	buf     []byte
+	pending bool
+}
+
+// Returns a reader that yields while reading from r, for
+// reading streams such as response bodies from a coroutine.
+// Each Read() runs on the default worker pool, and the result
+// is copied to the caller's buffer once done, so the buffer
+// is not written to after the coroutine is cancelled.
+// Panics when cancelled.
+//
+//	Note: A reader must only be used from the coroutine
+//	that created it. A blocked read keeps a worker busy
+//	until it returns, closing r usually unblocks it.
+func NewReader(ctrl *Control, r io.Reader) *Reader {
+	return &Reader{ctrl: ctrl, r: r}
+}
+
+// Reads up to len(p) bytes, yielding until some data
+// is available or an error occurs. Returns ErrCancelled
+// if the coroutine was cancelled during an earlier read,
+// and the cancel policy does not panic.
+func (reader *Reader) Read(p []byte) (int, error) {
+	if reader.pending {
+		return 0, ErrCancelled
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if cap(reader.buf) < len(p) {
+		reader.buf = make([]byte, len(p))
+	}
+	buf := reader.buf[:len(p)]
+
+	var n int
+	var err error
+	var done atomic.Bool
+	reader.pending = true
+	DefaultWorkerPool().Submit(reader.ctrl, func() {
+		defer done.Store(true)
+		n, err = reader.r.Read(buf)
+	})
+	reader.ctrl.YieldUntilAtomic(&done)
+	if !done.Load() {
+		// the read still owns the buffer
+		reader.buf = nil
+		return 0, ErrCancelled
+	}
+	reader.pending = false
+	copy(p, buf[:n])
+	return n, err
+}
@@ -0,0 +1,138 @@
+package carrot_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestFetch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			fmt.Fprintf(w, "chunk%v;", i)
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	var body atomic.Value
+	var fetchErr atomic.Value
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		resp, err := carrot.Fetch(ctrl, nil, req)
+		if err != nil {
+			fetchErr.Store(err)
+			return
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(carrot.NewReader(ctrl, resp.Body))
+		if err != nil {
+			fetchErr.Store(err)
+		}
+		body.Store(string(data))
+	})
+
+	for i := 0; i < 1000 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(time.Millisecond)
+	}
+	if err := fetchErr.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if data := body.Load(); data != "chunk0;chunk1;chunk2;" {
+		t.Error("unexpected body", data)
+	}
+}
+
+func TestFetchCancel(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-unblock:
+		}
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		carrot.Fetch(ctrl, nil, req)
+		ctrl.Checkpoint("fetched")
+	})
+	for i := 0; i < 5; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Cancel()
+	script.Update()
+	time.Sleep(updateDelay)
+	if !script.IsDone() {
+		t.Error("cancelling should not wait for the response")
+	}
+	if len(script.Checkpoints()) != 0 {
+		t.Error("fetch should not return after cancelling")
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (fn roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+type closeTracker struct {
+	io.Reader
+	closed atomic.Bool
+}
+
+func (body *closeTracker) Close() error {
+	body.closed.Store(true)
+	return nil
+}
+
+func TestFetchCancelLateResponse(t *testing.T) {
+	release := make(chan struct{})
+	body := &closeTracker{Reader: strings.NewReader("late")}
+	// ignores the context of the request, so the
+	// response arrives after the coroutine is cancelled
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		<-release
+		return &http.Response{StatusCode: 200, Body: body, Request: req}, nil
+	})}
+
+	var fetchErr atomic.Value
+	var gotResp atomic.Bool
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		req, _ := http.NewRequest("GET", "http://example.invalid", nil)
+		resp, err := carrot.Fetch(ctrl, client, req)
+		gotResp.Store(resp != nil)
+		fetchErr.Store(err)
+	}, carrot.WithCancelPolicy(carrot.CancelError))
+
+	script.Update()
+	script.Cancel()
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if err, _ := fetchErr.Load().(error); err != carrot.ErrCancelled || gotResp.Load() {
+		t.Error("Fetch should return ErrCancelled without a response", err)
+	}
+
+	close(release)
+	for i := 0; i < 100 && !body.closed.Load(); i++ {
+		time.Sleep(updateDelay)
+	}
+	if !body.closed.Load() {
+		t.Error("the late response should be closed")
+	}
+}
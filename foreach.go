@@ -0,0 +1,51 @@
+package carrot
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Starts a child coroutine for each item, with at most
+// maxConcurrent children running at a time, and yields
+// until all of them are done. A maxConcurrent of zero or less
+// runs all items at the same time.
+// Panics when cancelled.
+func ForEach[T any](ctrl *Control, items []T, maxConcurrent int, fn func(*Control, T)) {
+	if maxConcurrent <= 0 {
+		maxConcurrent = len(items)
+	}
+
+	var running atomic.Int32
+	hasSlot := func() bool { return int(running.Load()) < maxConcurrent }
+
+	for _, item := range items {
+		item := item
+		ctrl.YieldUntil(hasSlot)
+		running.Add(1)
+		ctrl.StartAsync(func(ctrl *Control) {
+			defer running.Add(-1)
+			fn(ctrl, item)
+		})
+	}
+
+	ctrl.YieldUntil(func() bool { return running.Load() == 0 })
+}
+
+// Calls fn for each i from 0 to n-1, yielding whenever the
+// coroutine has been running longer than the budget since
+// it was last resumed. Spreads large loops across frames
+// without placing MaybeYield() by hand.
+// Panics when cancelled, or with a policy other than
+// CancelPanic, stops without calling fn for the
+// remaining items, see WithCancelPolicy().
+func ForRange(ctrl *Control, n int, budget time.Duration, fn func(i int)) {
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			ctrl.MaybeYield(budget)
+		}
+		if ctrl.cancelReturns() {
+			return
+		}
+		fn(i)
+	}
+}
@@ -0,0 +1,96 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestForEach(t *testing.T) {
+	var running, maxRunning, sum atomic.Int32
+	items := []int32{1, 2, 3, 4, 5, 6, 7}
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		carrot.ForEach(ctrl, items, 3, func(ctrl *carrot.Control, n int32) {
+			count := running.Add(1)
+			defer running.Add(-1)
+			for {
+				max := maxRunning.Load()
+				if count <= max || maxRunning.CompareAndSwap(max, count) {
+					break
+				}
+			}
+			ctrl.Delay(int(n))
+			sum.Add(n)
+		})
+		if running.Load() != 0 {
+			t.Error("ForEach returned while children are still running")
+		}
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if sum.Load() != 28 {
+		t.Error("not all items were processed", sum.Load())
+	}
+	if maxRunning.Load() != 3 {
+		t.Error("expected at most 3 running children, got", maxRunning.Load())
+	}
+}
+
+func TestForRange(t *testing.T) {
+	var visited []int
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		carrot.ForRange(ctrl, 100, time.Second, func(i int) {
+			visited = append(visited, i)
+		})
+		ctrl.Yield()
+		carrot.ForRange(ctrl, 3, time.Millisecond, func(i int) {
+			time.Sleep(2 * time.Millisecond)
+			visited = append(visited, i)
+		})
+	})
+
+	frames := 0
+	for !script.IsDone() {
+		script.Update()
+		frames++
+	}
+	if len(visited) != 103 {
+		t.Error("wrong number of iterations", len(visited))
+	}
+	if frames < 4 || frames > 5 {
+		t.Error("wrong number of frames", frames)
+	}
+}
+
+func TestForRangeCancel(t *testing.T) {
+	var visited atomic.Int32
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		carrot.ForRange(ctrl, 1000, 0, func(i int) {
+			visited.Add(1)
+		})
+	}, carrot.WithCancelPolicy(carrot.CancelError))
+
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Cancel()
+	for i := 0; i < 10 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if !script.IsDone() {
+		t.Fatal("script should be done after cancel")
+	}
+	if n := visited.Load(); n >= 10 {
+		t.Error("ForRange should stop when cancelled", n)
+	}
+}
@@ -0,0 +1,76 @@
+package carrot
+
+// A Group is a collection of related scripts,
+// for instance all enemy scripts in a scene,
+// that can be controlled together. A script
+// can belong to multiple groups.
+//
+//	Note: Methods are all concurrent-safe.
+type Group struct {
+	scripts *sliceSet[*Script]
+
+	// scripts to be removed after update
+	doneScripts []*Script
+}
+
+// Creates a new empty group.
+func NewGroup() *Group {
+	return &Group{
+		scripts: newSliceSet[*Script](),
+	}
+}
+
+// Adds a script to the group.
+func (group *Group) Add(script *Script) {
+	group.scripts.Add(script)
+}
+
+// Removes a script from the group.
+// The script is not cancelled.
+func (group *Group) Remove(script *Script) {
+	group.scripts.Remove(script)
+}
+
+// Returns the number of scripts in the group.
+func (group *Group) Len() int {
+	return group.scripts.Len()
+}
+
+// Calls Update() on all scripts in the group,
+// then removes the scripts that are done.
+//
+//	Note: A script that belongs to several groups
+//	should be only updated from one of them,
+//	otherwise it will be updated more than once per frame.
+func (group *Group) Update() {
+	group.scripts.Each(func(script *Script) {
+		script.Update()
+		if script.IsDone() {
+			group.doneScripts = append(group.doneScripts, script)
+		}
+	})
+	for _, script := range group.doneScripts {
+		group.scripts.Remove(script)
+	}
+	group.doneScripts = group.doneScripts[:0]
+}
+
+// Cancels all scripts in the group.
+func (group *Group) CancelAll() {
+	group.scripts.Each((*Script).Cancel)
+}
+
+// Restarts all scripts in the group.
+func (group *Group) RestartAll() {
+	group.scripts.Each((*Script).Restart)
+}
+
+// Pauses all scripts in the group.
+func (group *Group) PauseAll() {
+	group.scripts.Each((*Script).Pause)
+}
+
+// Resumes all paused scripts in the group.
+func (group *Group) ResumeAll() {
+	group.scripts.Each((*Script).Resume)
+}
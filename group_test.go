@@ -0,0 +1,51 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestGroup(t *testing.T) {
+	enemies := carrot.NewGroup()
+	all := carrot.NewGroup()
+	count := atomic.Int32{}
+
+	for i := 0; i < 5; i++ {
+		script := carrot.Start(func(ctrl *carrot.Control) {
+			for {
+				count.Add(1)
+				ctrl.Yield()
+			}
+		})
+		enemies.Add(script)
+		all.Add(script)
+	}
+
+	all.Update()
+	all.Update()
+	time.Sleep(updateDelay)
+	n := count.Load()
+	if n < 5 {
+		t.Error("wrong count", n)
+	}
+
+	enemies.PauseAll()
+	all.Update()
+	all.Update()
+	time.Sleep(updateDelay)
+	if count.Load() != n {
+		t.Error("paused scripts should not be updated", count.Load())
+	}
+
+	enemies.ResumeAll()
+	enemies.CancelAll()
+	for all.Len() > 0 {
+		all.Update()
+	}
+	if count.Load() != n {
+		t.Error("cancelled scripts should not be updated", count.Load())
+	}
+}
@@ -0,0 +1,66 @@
+package carrot
+
+// A Handle is used to wait for the result of
+// a child coroutine started with Go().
+type Handle[T any] struct {
+	sub *Control
+	// generation of the sub when it was started, so that
+	// the handle isn't fooled once the sub is reused,
+	// see ctrl.Join()
+	gen    uint64
+	result T
+}
+
+// Starts a new child coroutine that returns a value,
+// similar to ctrl.StartAsync(). Use handle.Await(ctrl) to wait
+// for the result.
+//
+// If the child panics, the panic is recovered
+// and reported with handle.Err() instead.
+func Go[T any](ctrl *Control, fn func(*Control) T) *Handle[T] {
+	handle := &Handle[T]{}
+	sub := ctrl.StartAsync(func(ctrl *Control) {
+		handle.result = fn(ctrl)
+	}).(*Control)
+	handle.sub = sub
+	handle.gen = sub.generation.Load()
+	return handle
+}
+
+// Yields until the child coroutine is done,
+// then returns the result. The zero value is
+// returned if the child was cancelled or panicked,
+// see Err().
+// Panics when cancelled, or returns the zero value
+// if the cancel policy is not CancelPanic.
+func (handle *Handle[T]) Await(ctrl *Control) T {
+	ctrl.YieldUntil(handle.IsDone)
+	if !handle.IsDone() {
+		var zero T
+		return zero
+	}
+	return handle.result
+}
+
+// Returns ErrCancelled if the child coroutine was cancelled,
+// a *PanicError if it panicked, or nil otherwise.
+// Only valid once IsDone() is true.
+func (handle *Handle[T]) Err() error {
+	if !handle.IsDone() {
+		return nil
+	}
+	return handle.sub.errOf(handle.gen)
+}
+
+// Returns true if the child coroutine has finished,
+// was cancelled, or panicked.
+func (handle *Handle[T]) IsDone() bool {
+	return handle.sub.generation.Load() != handle.gen || handle.sub.IsDone()
+}
+
+// Cancels the child coroutine.
+func (handle *Handle[T]) Cancel() {
+	if handle.sub.generation.Load() == handle.gen {
+		handle.sub.Cancel()
+	}
+}
@@ -0,0 +1,94 @@
+package carrot_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestHandleAwait(t *testing.T) {
+	var sum int
+	var panicErr, cancelErr error
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		a := carrot.Go(ctrl, func(ctrl *carrot.Control) int {
+			ctrl.Delay(3)
+			return 10
+		})
+		b := carrot.Go(ctrl, func(ctrl *carrot.Control) int {
+			ctrl.Delay(5)
+			return 20
+		})
+		c := carrot.Go(ctrl, func(ctrl *carrot.Control) int {
+			ctrl.Yield()
+			panic("oops")
+		})
+		d := carrot.Go(ctrl, func(ctrl *carrot.Control) int {
+			ctrl.Abyss()
+			return 0
+		})
+		d.Cancel()
+
+		sum = a.Await(ctrl) + b.Await(ctrl)
+		c.Await(ctrl)
+		d.Await(ctrl)
+		panicErr = c.Err()
+		cancelErr = d.Err()
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if sum != 30 {
+		t.Error("wrong sum", sum)
+	}
+	var pe *carrot.PanicError
+	if !errors.As(panicErr, &pe) || pe.Value != "oops" {
+		t.Error("expected panic error", panicErr)
+	}
+	if cancelErr != carrot.ErrCancelled {
+		t.Error("expected cancel error", cancelErr)
+	}
+}
+
+func TestHandleCancelledByOthers(t *testing.T) {
+	var results []int
+	var errs []error
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		// cancelled before it starts, not through the handle
+		early := carrot.Go(ctrl, func(ctrl *carrot.Control) int {
+			return 1
+		})
+		ctrl.Children()[0].Cancel()
+		results = append(results, early.Await(ctrl))
+		errs = append(errs, early.Err())
+
+		// returns normally once cancelled
+		late := carrot.Go(ctrl, func(ctrl *carrot.Control) int {
+			for ctrl.YieldErr() == nil {
+			}
+			return 2
+		})
+		ctrl.Yield()
+		late.Cancel()
+		late.Await(ctrl)
+		errs = append(errs, late.Err())
+	}, carrot.WithCancelPolicy(carrot.CancelError))
+
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !script.IsDone() {
+		t.Fatal("Await should return once the child is cancelled")
+	}
+	if len(results) != 1 || results[0] != 0 {
+		t.Error("cancelled child should have no result", results)
+	}
+	if len(errs) != 2 || errs[0] != carrot.ErrCancelled || errs[1] != carrot.ErrCancelled {
+		t.Error("expected cancel errors", errs)
+	}
+}
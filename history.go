@@ -0,0 +1,156 @@
+package carrot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// A HistoryKind is the kind of a HistoryEvent.
+type HistoryKind int
+
+const (
+	// A coroutine was resumed on Update().
+	HistoryResume HistoryKind = iota
+	// A coroutine yielded, see HistoryEvent.Reason.
+	HistoryYield
+	// A coroutine function was started or restarted.
+	HistoryStart
+	// A coroutine function returned, panicked,
+	// or was cancelled.
+	HistoryEnd
+	// A coroutine transitioned to another function.
+	HistoryTransition
+	// A coroutine was cancelled, see HistoryEvent.Cause.
+	// Not recorded for restarts and transitions.
+	HistoryCancel
+)
+
+func (kind HistoryKind) String() string {
+	switch kind {
+	case HistoryResume:
+		return "resume"
+	case HistoryYield:
+		return "yield"
+	case HistoryStart:
+		return "start"
+	case HistoryEnd:
+		return "end"
+	case HistoryTransition:
+		return "transition"
+	case HistoryCancel:
+		return "cancel"
+	}
+	return "unknown"
+}
+
+// A HistoryEvent is a state change of a coroutine
+// in a script. See script.History().
+type HistoryEvent struct {
+	Kind HistoryKind
+	// Number of the Update() of the script
+	// the event happened in, starting from 1.
+	Frame int64
+	Time  time.Time
+	// ID of the coroutine.
+	ID int64
+	// Name of the coroutine function. For transitions,
+	// the name of the function transitioned to.
+	Name string
+	// Set for yields.
+	Reason WaitReason
+	Label  string
+	// Set for cancellations.
+	Cause CancelCause
+	// Set for ends, nil if the coroutine returned normally.
+	Err error
+}
+
+func (event HistoryEvent) String() string {
+	s := fmt.Sprintf("frame %v: coroutine %v %v", event.Frame, event.ID, event.Kind)
+	switch event.Kind {
+	case HistoryYield:
+		s += fmt.Sprintf(" (%v)", event.Reason)
+		if event.Label != "" {
+			s += fmt.Sprintf(" %q", event.Label)
+		}
+	case HistoryStart, HistoryTransition:
+		s += " " + event.Name
+	case HistoryCancel:
+		s += fmt.Sprintf(" (%v)", event.Cause)
+	case HistoryEnd:
+		if event.Err != nil {
+			s += fmt.Sprintf(" (%v)", event.Err)
+		}
+	}
+	return s
+}
+
+// a ring buffer of the last events of a script
+type historyLog struct {
+	mu     sync.Mutex
+	events []HistoryEvent
+	next   int
+	full   bool
+}
+
+// Records the last size state changes of the coroutines in
+// the script, such as resumes, yields, transitions and
+// cancellations, to be returned by script.History(). Useful for
+// including what a script did over the last frames in a bug
+// report. Disabled by default, since it costs a lock
+// on every yield. Only has effect when used with
+// Start() or Create().
+func WithHistory(size int) Option {
+	return func(ctrl *Control) {
+		if size > 0 {
+			ctrl.historyLog = &historyLog{events: make([]HistoryEvent, size)}
+		}
+	}
+}
+
+// Returns the last n recorded events of the script, oldest
+// first. Returns nil if the script was not started
+// with WithHistory().
+func (script *Script) History(n int) []HistoryEvent {
+	log := script.baseControl.historyLog
+	if log == nil || n <= 0 {
+		return nil
+	}
+	log.mu.Lock()
+	defer log.mu.Unlock()
+	count := log.next
+	if log.full {
+		count = len(log.events)
+	}
+	if n > count {
+		n = count
+	}
+	events := make([]HistoryEvent, n)
+	for i := range events {
+		index := (log.next - n + i + len(log.events)) % len(log.events)
+		events[i] = log.events[index]
+	}
+	return events
+}
+
+// Records an event if the script of the coroutine keeps a
+// history. The fields not set by the caller are filled in.
+func (ctrl *Control) recordHistory(event HistoryEvent) {
+	root := ctrl.root()
+	log := root.historyLog
+	if log == nil {
+		return
+	}
+	event.Frame = root.frame.Load()
+	event.Time = time.Now()
+	event.ID = ctrl.ID
+	log.mu.Lock()
+	log.events[log.next] = event
+	log.next++
+	if log.next == len(log.events) {
+		log.next = 0
+		log.full = true
+	}
+	log.mu.Unlock()
+}
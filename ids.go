@@ -0,0 +1,33 @@
+package carrot
+
+import "sync/atomic"
+
+// An IDSource generates the IDs of coroutines in a script,
+// so that the IDs are the same on every run as long as
+// the coroutines are started in the same order.
+// Useful for reproducible logs, replays and snapshot tests.
+// See WithIDSource().
+//
+//	Note: Coroutines started without an IDSource
+//	get their IDs from a package-wide counter.
+type IDSource struct {
+	seed int64
+	next atomic.Int64
+}
+
+// Creates a new ID source. The first ID is seed+1.
+func NewIDSource(seed int64) *IDSource {
+	src := &IDSource{seed: seed}
+	src.Reset()
+	return src
+}
+
+// Returns a new ID.
+func (src *IDSource) Next() int64 {
+	return src.next.Add(1)
+}
+
+// Resets the source, so that the next ID is seed+1 again.
+func (src *IDSource) Reset() {
+	src.next.Store(src.seed)
+}
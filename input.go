@@ -0,0 +1,69 @@
+package carrot
+
+// Queues an input event, such as a key press, for the coroutines
+// of the script to receive with ctrl.NextInput(). Events posted
+// between two updates are delivered together on the next Update(),
+// in the order they were posted. Can be called from any thread.
+func (script *Script) PostInput(event any) {
+	ctrl := script.baseControl
+	ctrl.inputMu.Lock()
+	ctrl.inputPending = append(ctrl.inputPending, event)
+	ctrl.inputMu.Unlock()
+}
+
+// Yields until an input event posted with script.PostInput() is
+// available, and returns it. Returns right away if there is one
+// already. Each event is received by only one coroutine,
+// the first one to ask for it. Returns nil if cancelled
+// with a cancel policy that does not panic.
+// Panics when cancelled.
+//
+//	for {
+//		switch event := ctrl.NextInput().(type) {
+//		case KeyPress:
+//			...
+//		}
+//	}
+func (ctrl *Control) NextInput() any {
+	root := ctrl.root()
+	var event any
+	ctrl.YieldUntil(func() bool {
+		var ok bool
+		event, ok = root.popInput()
+		return ok
+	})
+	return event
+}
+
+// Returns the next input event like NextInput(),
+// but without waiting. Returns false if
+// there is none.
+func (ctrl *Control) PollInput() (any, bool) {
+	return ctrl.root().popInput()
+}
+
+func (ctrl *Control) popInput() (any, bool) {
+	ctrl.inputMu.Lock()
+	defer ctrl.inputMu.Unlock()
+	if len(ctrl.inputQueue) == 0 {
+		return nil, false
+	}
+	event := ctrl.inputQueue[0]
+	ctrl.inputQueue[0] = nil
+	ctrl.inputQueue = ctrl.inputQueue[1:]
+	return event, true
+}
+
+// Makes the events posted since the last update
+// available, called at the start of the update.
+func (ctrl *Control) deliverInputs() {
+	ctrl.inputMu.Lock()
+	if len(ctrl.inputPending) > 0 {
+		ctrl.inputQueue = append(ctrl.inputQueue, ctrl.inputPending...)
+		for i := range ctrl.inputPending {
+			ctrl.inputPending[i] = nil
+		}
+		ctrl.inputPending = ctrl.inputPending[:0]
+	}
+	ctrl.inputMu.Unlock()
+}
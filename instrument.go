@@ -0,0 +1,23 @@
+package carrot
+
+import "sync/atomic"
+
+var instrumentationOff atomic.Bool
+
+// Enables or disables the debug instrumentation of all
+// scripts: the logs of Logf() and WithLogger(), the wait labels
+// of YieldNamed() and similar methods, and the counters of
+// Stats(). Enabled by default. Release builds can disable it
+// to skip the bookkeeping on every yield, in which case
+// WaitLabel() returns an empty string and the stats
+// stop counting.
+func SetInstrumentation(enable bool) {
+	instrumentationOff.Store(!enable)
+}
+
+// Returns true if the instrumentation is enabled,
+// see SetInstrumentation(). Small enough to be
+// inlined at each instrumented call.
+func instrumented() bool {
+	return !instrumentationOff.Load()
+}
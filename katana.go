@@ -1,5 +1,10 @@
 package carrot
 
+import (
+	"sync/atomic"
+	"time"
+)
+
 // katana is used to simulate coroutine behaviour.
 // Consider the following:
 // | main thread (left)     | coroutine (right)
@@ -32,6 +37,11 @@ package carrot
 // - am weebo
 type katana struct {
 	c chan void
+	// number of times the coroutine started waiting
+	// on the right, and the number of times it was
+	// resumed from the left, see isWaiting()
+	waits    atomic.Int64
+	handoffs atomic.Int64
 }
 
 func newKatana() *katana {
@@ -45,13 +55,62 @@ func newKatana() *katana {
 // until YieldRight() is called.
 func (k *katana) YieldLeft() {
 	k.c <- none
+	k.handoffs.Add(1)
+	<-k.c
+}
+
+// Same as YieldLeft(), but gives up and returns false
+// if the coroutine is not waiting in YieldRight() by
+// the deadline, for instance because it's still running
+// since it was last resumed. The coroutine is
+// not resumed in that case.
+func (k *katana) YieldLeftUntil(deadline time.Time) bool {
+	select {
+	case k.c <- none:
+	default:
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return false
+		}
+		timer := time.NewTimer(remaining)
+		defer timer.Stop()
+		select {
+		case k.c <- none:
+		case <-timer.C:
+			return false
+		}
+	}
+	k.handoffs.Add(1)
 	<-k.c
+	return true
 }
 
 // Yields control from the coroutine
 // to the main thread. It will not return
 // until YieldLeft() is called.
 func (k *katana) YieldRight() {
+	k.waits.Add(1)
 	<-k.c
 	k.c <- none
 }
+
+// Same as YieldRight(), but also returns
+// false once quit is closed.
+func (k *katana) YieldRightOrQuit(quit <-chan void) bool {
+	k.waits.Add(1)
+	select {
+	case <-k.c:
+		k.c <- none
+		return true
+	case <-quit:
+		return false
+	}
+}
+
+// Returns true if the coroutine is waiting in YieldRight()
+// for the next YieldLeft(). Must be called from the left,
+// since a YieldLeft() on another thread could be
+// resuming the coroutine at the same time.
+func (k *katana) isWaiting() bool {
+	return k.waits.Load() > k.handoffs.Load()
+}
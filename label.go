@@ -0,0 +1,63 @@
+package carrot
+
+import "time"
+
+// Same as Yield(), but labels the wait, so that tools
+// can tell what the coroutine is waiting for.
+// See WaitLabel().
+// Panics when cancelled.
+func (ctrl *Control) YieldNamed(label string) {
+	defer ctrl.setWaitLabel(label)()
+	ctrl.Yield()
+}
+
+// Same as Sleep(), but labels the wait.
+// See WaitLabel().
+// Panics when cancelled.
+func (ctrl *Control) SleepNamed(label string, duration time.Duration) {
+	defer ctrl.setWaitLabel(label)()
+	ctrl.Sleep(duration)
+}
+
+// Same as YieldUntil(), but labels the wait.
+// See WaitLabel().
+// Panics when cancelled.
+//
+//	ctrl.YieldUntilNamed("waiting-for-door", door.IsOpen)
+func (ctrl *Control) YieldUntilNamed(label string, fn func() bool) {
+	defer ctrl.setWaitLabel(label)()
+	ctrl.YieldUntil(fn)
+}
+
+// Returns the label of the current wait, given to
+// YieldNamed(), SleepNamed() or YieldUntilNamed(). Returns
+// an empty string if the coroutine is not in a labeled wait,
+// or if the instrumentation is disabled with SetInstrumentation().
+// Can be called from any thread.
+func (ctrl *Control) WaitLabel() string {
+	if label := ctrl.waitLabel.Load(); label != nil {
+		return *label
+	}
+	return ""
+}
+
+func noRestore() {}
+
+// Sets the wait label, and returns a
+// function that restores the previous one.
+func (ctrl *Control) setWaitLabel(label string) func() {
+	if !instrumented() {
+		return noRestore
+	}
+	return ctrl.swapWaitLabel(label)
+}
+
+// split from setWaitLabel() so that the label
+// is only allocated when instrumented
+func (ctrl *Control) swapWaitLabel(label string) func() {
+	prev := ctrl.waitLabel.Swap(&label)
+	ctrl.tracef("waiting for %v", label)
+	return func() {
+		ctrl.waitLabel.Store(prev)
+	}
+}
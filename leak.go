@@ -0,0 +1,157 @@
+package carrot
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var leakTracking atomic.Bool
+
+var leakRegistry = struct {
+	mu      sync.Mutex
+	scripts map[*Script]*leakEntry
+}{scripts: map[*Script]*leakEntry{}}
+
+type leakEntry struct {
+	created time.Time
+	stack   string
+
+	// unix nanoseconds of the last Update()
+	lastUpdate atomic.Int64
+}
+
+// A Leak is a script that is still alive. See LeakReport().
+type Leak struct {
+	ID int64
+	// Name of the current coroutine function of the script.
+	Name   string
+	Tags   []string
+	Status string
+	// Label of the current wait of the main coroutine,
+	// see ctrl.WaitLabel().
+	WaitLabel string
+	// Time since the script was created.
+	Age time.Duration
+	// Time since the script was last updated, or
+	// the same as Age if it was never updated.
+	SinceUpdate time.Duration
+	// Stack trace of where the script was created.
+	Stack string
+}
+
+func (leak Leak) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "script %v (%v) alive for %v, last updated %v ago",
+		leak.ID, leak.Name, leak.Age, leak.SinceUpdate)
+	if len(leak.Tags) > 0 {
+		fmt.Fprintf(&b, ", tags %v", leak.Tags)
+	}
+	if leak.Status != "" {
+		fmt.Fprintf(&b, ", status %q", leak.Status)
+	}
+	if leak.WaitLabel != "" {
+		fmt.Fprintf(&b, ", waiting for %q", leak.WaitLabel)
+	}
+	fmt.Fprintf(&b, "\ncreated at:\n%v", leak.Stack)
+	return b.String()
+}
+
+// Enables or disables tracking of the scripts created with
+// Start() or Create(), for LeakReport(). Only the scripts
+// created while tracking is enabled are reported.
+// Disabled by default, since it records a stack trace
+// for every script.
+func TrackLeaks(enable bool) {
+	leakTracking.Store(enable)
+	if !enable {
+		leakRegistry.mu.Lock()
+		leakRegistry.scripts = map[*Script]*leakEntry{}
+		leakRegistry.mu.Unlock()
+	}
+}
+
+// Returns the tracked scripts that are still alive, meaning
+// they are not done yet, in the order they were created.
+// Intended to be called at the end of a test or at program exit
+// to find scripts that nobody cancels or updates anymore.
+// See TrackLeaks().
+func LeakReport() []Leak {
+	now := time.Now()
+	leakRegistry.mu.Lock()
+	defer leakRegistry.mu.Unlock()
+
+	var leaks []Leak
+	for script, entry := range leakRegistry.scripts {
+		if script.IsDone() {
+			delete(leakRegistry.scripts, script)
+			continue
+		}
+		ctrl := script.baseControl
+		sinceUpdate := now.Sub(entry.created)
+		if at := entry.lastUpdate.Load(); at != 0 {
+			sinceUpdate = now.Sub(time.Unix(0, at))
+		}
+		leaks = append(leaks, Leak{
+			ID:          ctrl.ID,
+			Name:        coroutineName(ctrl.currentCoroutine()),
+			Tags:        ctrl.Tags(),
+			Status:      ctrl.Status(),
+			WaitLabel:   ctrl.WaitLabel(),
+			Age:         now.Sub(entry.created),
+			SinceUpdate: sinceUpdate,
+			Stack:       entry.stack,
+		})
+	}
+	sort.Slice(leaks, func(i, j int) bool {
+		return leaks[i].Age > leaks[j].Age
+	})
+	return leaks
+}
+
+func (script *Script) trackLeak() {
+	if !leakTracking.Load() {
+		return
+	}
+	entry := &leakEntry{
+		created: time.Now(),
+		stack:   string(debug.Stack()),
+	}
+	script.leak = entry
+	leakRegistry.mu.Lock()
+	leakRegistry.scripts[script] = entry
+	leakRegistry.mu.Unlock()
+}
+
+// Tracks the script again when it's restarted after it was done.
+func (script *Script) retrackLeak() {
+	if !leakTracking.Load() {
+		return
+	}
+	leakRegistry.mu.Lock()
+	leakRegistry.scripts[script] = script.leak
+	leakRegistry.mu.Unlock()
+}
+
+func (script *Script) untrackLeak() {
+	leakRegistry.mu.Lock()
+	delete(leakRegistry.scripts, script)
+	leakRegistry.mu.Unlock()
+}
+
+func coroutineName(coroutine Coroutine) string {
+	if coroutine == nil {
+		return "none"
+	}
+	fn := runtime.FuncForPC(reflect.ValueOf(coroutine).Pointer())
+	if fn == nil {
+		return "unknown"
+	}
+	return fn.Name()
+}
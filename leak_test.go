@@ -0,0 +1,49 @@
+package carrot_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func forgottenEnemy(ctrl *carrot.Control) {
+	ctrl.Abyss()
+}
+
+func TestLeakReport(t *testing.T) {
+	carrot.TrackLeaks(true)
+	defer carrot.TrackLeaks(false)
+
+	finished := carrot.Start(func(ctrl *carrot.Control) {})
+	leaked := carrot.Start(forgottenEnemy, carrot.WithTags("enemy"))
+	for i := 0; i < 3; i++ {
+		finished.Update()
+		leaked.Update()
+		time.Sleep(updateDelay)
+	}
+
+	leaks := carrot.LeakReport()
+	if len(leaks) != 1 {
+		t.Fatal("wrong number of leaks", leaks)
+	}
+	leak := leaks[0]
+	if !strings.HasSuffix(leak.Name, "forgottenEnemy") {
+		t.Error("wrong name", leak.Name)
+	}
+	if !strings.Contains(leak.Stack, "leak_test.go") {
+		t.Error("stack should include where the script was created", leak.Stack)
+	}
+	if !strings.Contains(leak.String(), "[enemy]") {
+		t.Error("wrong report", leak)
+	}
+
+	leaked.Cancel()
+	leaked.Update()
+	time.Sleep(updateDelay)
+	leaked.Update()
+	if leaks := carrot.LeakReport(); len(leaks) != 0 {
+		t.Error("cancelled script should not be reported", leaks)
+	}
+}
@@ -0,0 +1,61 @@
+package carrot
+
+import "golang.org/x/exp/slices"
+
+// Links the scripts so that cancelling either one cancels the
+// other, for instance for entity pairs such as a vehicle and its
+// driver whose behaviors must end together even though neither
+// is the parent of the other. Links are symmetric and
+// transitive, cancelling a script cancels every script
+// linked to it, directly or not.
+// Restarts and transitions are not propagated.
+// See also Unlink().
+func Link(a, b *Script) {
+	if a == b {
+		return
+	}
+	a.addLink(b)
+	b.addLink(a)
+}
+
+// Removes the link between the scripts made with Link().
+func Unlink(a, b *Script) {
+	a.removeLink(b)
+	b.removeLink(a)
+}
+
+// Returns the scripts linked to the script with Link().
+func (script *Script) Links() []*Script {
+	script.linksMu.Lock()
+	defer script.linksMu.Unlock()
+	return slices.Clone(script.links)
+}
+
+func (script *Script) addLink(other *Script) {
+	script.linksMu.Lock()
+	defer script.linksMu.Unlock()
+	if !slices.Contains(script.links, other) {
+		script.links = append(script.links, other)
+	}
+}
+
+func (script *Script) removeLink(other *Script) {
+	script.linksMu.Lock()
+	defer script.linksMu.Unlock()
+	if i := slices.Index(script.links, other); i >= 0 {
+		script.links = slices.Delete(script.links, i, i+1)
+	}
+}
+
+// Called on Update() when the script is cancelled,
+// cancels the linked scripts that are still running.
+// The linked scripts are cancelled on their next update.
+func (script *Script) cancelLinks() {
+	for _, other := range script.Links() {
+		ctrl := other.baseControl
+		if other.IsDone() || ctrl.isCanceled() || ctrl.isCancelling() {
+			continue
+		}
+		ctrl.cancelWith(CauseLinked)
+	}
+}
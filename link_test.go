@@ -0,0 +1,51 @@
+package carrot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestLink(t *testing.T) {
+	start := func() *carrot.Script {
+		return carrot.Start(func(ctrl *carrot.Control) { ctrl.Abyss() })
+	}
+	vehicle, driver, passenger := start(), start(), start()
+	carrot.Link(vehicle, driver)
+	carrot.Link(driver, passenger)
+	update := func() {
+		for i := 0; i < 3; i++ {
+			for _, script := range []*carrot.Script{vehicle, driver, passenger} {
+				script.Update()
+			}
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update()
+	vehicle.Restart()
+	update()
+	if driver.IsDone() {
+		t.Error("restarts should not be propagated")
+	}
+	driver.Cancel()
+	update()
+	if !vehicle.IsDone() || !passenger.IsDone() {
+		t.Error("cancelling a script should cancel the linked scripts")
+	}
+
+	a, b := start(), start()
+	carrot.Link(a, b)
+	carrot.Unlink(a, b)
+	a.Cancel()
+	for i := 0; i < 3; i++ {
+		a.Update()
+		b.Update()
+		time.Sleep(updateDelay)
+	}
+	if b.IsDone() || len(b.Links()) != 0 {
+		t.Error("unlinked scripts should not be cancelled")
+	}
+	b.Close()
+}
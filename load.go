@@ -0,0 +1,156 @@
+package carrot
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"sync/atomic"
+)
+
+// size of the reads of LoadFile() and LoadAll(),
+// the progress is updated after each read
+const loadChunkSize = 256 * 1024
+
+type loadedFile interface {
+	io.ReadCloser
+	Stat() (fs.FileInfo, error)
+}
+
+// replaced in tests to slow down the reads
+var openLoadFile = func(path string) (loadedFile, error) {
+	return os.Open(path)
+}
+
+// Reads the whole file on the default worker pool, yielding until
+// done. The progress of the coroutine is set to the fraction
+// of the file read so far on every frame, see ctrl.SetProgress().
+// Reading stops early when the coroutine is cancelled.
+// Panics when cancelled.
+func LoadFile(ctrl *Control, path string) ([]byte, error) {
+	files, err := LoadAll(ctrl, []string{path}, 1)
+	if err != nil {
+		return nil, err
+	}
+	return files[0], nil
+}
+
+// Reads the files on the default worker pool, at most maxConcurrent
+// at a time, yielding until all are read. Returns the contents of
+// the files in the order of paths, or the first error. The progress
+// of the coroutine is set to the fraction of all the files read so
+// far on every frame, as for a loading screen. Reading stops early
+// when the coroutine is cancelled, or once a file fails to load.
+// Panics when cancelled.
+//
+//	Example:
+//	files, err := carrot.LoadAll(ctrl, paths, 4)
+//	// meanwhile, on the main thread
+//	drawLoadingBar(script.Progress())
+func LoadAll(ctrl *Control, paths []string, maxConcurrent int) ([][]byte, error) {
+	if ctrl.isStubbed() {
+		ctrl.countStubbed()
+		return nil, ErrStubbed
+	}
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	ctx, cancel := context.WithCancel(ctrl.Context())
+	defer cancel()
+
+	type loaded struct {
+		index int
+		data  []byte
+		err   error
+	}
+	results := make(chan loaded, len(paths))
+	// float64 bits of the progress of each file
+	progress := make([]atomic.Uint64, len(paths))
+	files := make([][]byte, len(paths))
+
+	updateProgress := func() {
+		if len(paths) == 0 {
+			return
+		}
+		total := 0.0
+		for i := range progress {
+			total += math.Float64frombits(progress[i].Load())
+		}
+		ctrl.SetProgress(total / float64(len(paths)))
+	}
+
+	ctrl.SetProgress(0)
+	next, running := 0, 0
+	for next < len(paths) || running > 0 {
+		for next < len(paths) && running < maxConcurrent {
+			index := next
+			DefaultWorkerPool().Submit(ctrl, func() {
+				data, err := loadFile(ctx, paths[index], &progress[index])
+				results <- loaded{index, data, err}
+			})
+			next++
+			running++
+		}
+
+		ctrl.YieldUntil(func() bool {
+			updateProgress()
+			return len(results) > 0
+		})
+		if ctrl.cancelReturns() {
+			return nil, ErrCancelled
+		}
+		for len(results) > 0 {
+			result := <-results
+			running--
+			if result.err != nil {
+				return nil, result.err
+			}
+			files[result.index] = result.data
+		}
+		updateProgress()
+	}
+	ctrl.SetProgress(1)
+	return files, nil
+}
+
+func loadFile(ctx context.Context, path string, progress *atomic.Uint64) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	file, err := openLoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	size := int64(0)
+	if info, err := file.Stat(); err == nil {
+		size = info.Size()
+	}
+	data := make([]byte, 0, size+1)
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(data) == cap(data) {
+			data = append(data, 0)[:len(data)]
+		}
+		end := len(data) + loadChunkSize
+		if end > cap(data) {
+			end = cap(data)
+		}
+		n, err := file.Read(data[len(data):end])
+		data = data[:len(data)+n]
+		if size > 0 {
+			progress.Store(math.Float64bits(math.Min(1, float64(len(data))/float64(size))))
+		}
+		if err == io.EOF {
+			progress.Store(math.Float64bits(1))
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
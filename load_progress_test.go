@@ -0,0 +1,65 @@
+package carrot
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// slowFile only returns a chunk each time step receives
+type slowFile struct {
+	*os.File
+	step chan struct{}
+}
+
+func (file slowFile) Read(p []byte) (int, error) {
+	<-file.step
+	return file.File.Read(p)
+}
+
+func TestLoadAllProgressPerChunk(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big")
+	if err := os.WriteFile(path, make([]byte, 4*loadChunkSize), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	step := make(chan struct{}, 1)
+	openLoadFile = func(path string) (loadedFile, error) {
+		file, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		return slowFile{file, step}, nil
+	}
+	defer func() {
+		openLoadFile = func(path string) (loadedFile, error) { return os.Open(path) }
+	}()
+
+	script := Start(func(ctrl *Control) {
+		if _, err := LoadAll(ctrl, []string{path}, 1); err != nil {
+			t.Error(err)
+		}
+	})
+	defer script.Cancel()
+
+	step <- struct{}{}
+	var progress float64
+	for i := 0; i < 1000 && progress == 0; i++ {
+		script.Update()
+		time.Sleep(time.Millisecond)
+		progress = script.Progress()
+	}
+	if progress <= 0 || progress >= 1 {
+		t.Fatal("progress should go up part-way through the file:", progress)
+	}
+
+	close(step)
+	for i := 0; i < 1000 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(time.Millisecond)
+	}
+	if !script.IsDone() || script.Progress() != 1 {
+		t.Error("expected the load to finish", script.IsDone(), script.Progress())
+	}
+}
@@ -0,0 +1,72 @@
+package carrot_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestLoadAll(t *testing.T) {
+	dir := t.TempDir()
+	var paths []string
+	var contents [][]byte
+	for i := 0; i < 5; i++ {
+		path := filepath.Join(dir, string(rune('a'+i)))
+		data := bytes.Repeat([]byte{byte(i)}, 1000*(i+1))
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+		contents = append(contents, data)
+	}
+
+	var files atomic.Value
+	var loadErr atomic.Value
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		result, err := carrot.LoadAll(ctrl, paths, 2)
+		if err != nil {
+			loadErr.Store(err)
+			return
+		}
+		files.Store(result)
+	})
+	for i := 0; i < 1000 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(time.Millisecond)
+	}
+	if err := loadErr.Load(); err != nil {
+		t.Fatal(err)
+	}
+	result, _ := files.Load().([][]byte)
+	if len(result) != len(contents) {
+		t.Fatal("expected", len(contents), "files, got", len(result))
+	}
+	for i := range result {
+		if !bytes.Equal(result[i], contents[i]) {
+			t.Error("wrong contents of file", i)
+		}
+	}
+	if progress := script.Progress(); progress != 1 {
+		t.Error("progress should be 1 when done, got", progress)
+	}
+}
+
+func TestLoadFileError(t *testing.T) {
+	var loadErr atomic.Value
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		_, err := carrot.LoadFile(ctrl, filepath.Join(t.TempDir(), "missing"))
+		loadErr.Store(err)
+	})
+	for i := 0; i < 1000 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(time.Millisecond)
+	}
+	if err, _ := loadErr.Load().(error); !os.IsNotExist(err) {
+		t.Error("expected a not exist error, got", err)
+	}
+}
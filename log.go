@@ -15,6 +15,8 @@ func logSome(in *Control, format string, args ...any) {
 	log.Printf(fmt.Sprintf("[coroutine-%v] ", in.ID)+format, args...)
 }
 
+// Enables the logs of all scripts, except
+// the ones started with WithLogger().
 func SetLogging(enable bool) {
 	if enable {
 		logFn = logSome
@@ -22,3 +24,81 @@ func SetLogging(enable bool) {
 		logFn = logNone
 	}
 }
+
+// A Logger receives the logs of a script, see WithLogger().
+// A *log.Logger is a Logger.
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// A LogLevel sets which logs of a script are
+// emitted, see WithLogger().
+type LogLevel uint32
+
+const (
+	// Only the logs of ctrl.Logf() and the start, end
+	// and errors of the coroutines.
+	LogDebug LogLevel = iota
+
+	// Also the logs of every yield and wait label,
+	// which are emitted on every frame.
+	LogTrace
+)
+
+func (level LogLevel) String() string {
+	switch level {
+	case LogDebug:
+		return "debug"
+	case LogTrace:
+		return "trace"
+	}
+	return "unknown"
+}
+
+// Sends the logs of the script and its child coroutines to
+// the logger, at the given level, regardless of SetLogging().
+// A nil logger discards the logs of the script, even
+// when SetLogging(true) is set. Only has effect when
+// used with Start() or Create().
+//
+//	Example:
+//	logger := log.New(os.Stderr, "[cutscene] ", log.LstdFlags)
+//	carrot.Start(cutscene, carrot.WithLogger(logger, carrot.LogTrace))
+func WithLogger(logger Logger, level LogLevel) Option {
+	return func(ctrl *Control) {
+		ctrl.logger = logger
+		ctrl.logLevel = level
+		ctrl.hasLogger = true
+	}
+}
+
+func (ctrl *Control) logAt(level LogLevel, format string, args ...any) {
+	if !instrumented() {
+		return
+	}
+	root := ctrl.root()
+	if !root.hasLogger {
+		logFn(ctrl, format, args...)
+		return
+	}
+	if root.logger == nil || level > root.logLevel {
+		return
+	}
+	root.logger.Printf(fmt.Sprintf("[coroutine-%v] ", ctrl.ID)+format, args...)
+}
+
+// Logs yield-level events, only emitted by loggers set
+// with WithLogger() and LogTrace, or by SetLogging(true).
+func (ctrl *Control) tracef(format string, args ...any) {
+	ctrl.logAt(LogTrace, format, args...)
+}
+
+// Returns true if the yields of the coroutine are logged,
+// checked before formatting a log on every yield.
+func (ctrl *Control) tracesYields() bool {
+	if !instrumented() {
+		return false
+	}
+	root := ctrl.root()
+	return root.hasLogger && root.logger != nil && root.logLevel >= LogTrace
+}
@@ -0,0 +1,60 @@
+package carrot_test
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWithLogger(t *testing.T) {
+	run := func(level carrot.LogLevel) string {
+		var out syncBuffer
+		script := carrot.Start(func(ctrl *carrot.Control) {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				ctrl.Logf("hello from child")
+				ctrl.YieldNamed("door")
+			})
+			ctrl.Delay(3)
+		}, carrot.WithLogger(log.New(&out, "", 0), level))
+		for i := 0; i < 5; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+		script.Close()
+		return out.String()
+	}
+
+	debug := run(carrot.LogDebug)
+	if !strings.Contains(debug, "hello from child") {
+		t.Error("expected the logs of child coroutines, got", debug)
+	}
+	if strings.Contains(debug, "yield") || strings.Contains(debug, "waiting for door") {
+		t.Error("yields should not be logged at debug level, got", debug)
+	}
+	trace := run(carrot.LogTrace)
+	if !strings.Contains(trace, "yield (frame)") || !strings.Contains(trace, "waiting for door") {
+		t.Error("yields should be logged at trace level, got", trace)
+	}
+}
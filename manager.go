@@ -0,0 +1,526 @@
+package carrot
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// A Manager is used to update a number of scripts together.
+// Scripts that are done are automatically removed
+// from the manager on update.
+//
+//	Note: Methods are all concurrent-safe, scripts
+//	can be added or removed inside a coroutine. Calls to
+//	the update methods are serialized, as with script.Update().
+type Manager struct {
+	mu      sync.Mutex
+	entries []*managerEntry
+
+	// held during the update methods
+	updateMu sync.Mutex
+	// entries being updated, reused every frame
+	tempEntries []*managerEntry
+
+	frame int
+
+	ids *IDSource
+
+	paused bool
+
+	// background scripts are updated every nth frame
+	backgroundRate int
+
+	// set by SetImportance()
+	importanceFn func(*Script) float64
+
+	// scripts given back with Release(), reused by Acquire()
+	free []*Script
+
+	// locks of the shards of the scripts, see WithShard()
+	shards map[string]*managerShard
+	// the worker of each shard, reused every frame
+	// by UpdateParallel()
+	shardWorkers map[string]int
+}
+
+type managerEntry struct {
+	script *Script
+
+	// the shard of the script when it was added
+	shard string
+
+	// the last frame the script was updated,
+	// only written while holding the manager's mu
+	lastFrame int
+}
+
+// The settings of the frame being updated, copied
+// while holding the manager's mu in beginFrame().
+type managerFrame struct {
+	number     int
+	rate       int
+	importance func(*Script) float64
+}
+
+const defaultBackgroundRate = 4
+
+// the update interval of scripts with
+// an importance of zero or less
+const maxImportanceInterval = 60
+
+// Creates a new empty manager.
+func NewManager() *Manager {
+	return &Manager{
+		ids:            NewIDSource(0),
+		backgroundRate: defaultBackgroundRate,
+	}
+}
+
+// Creates a new script and adds it to the manager.
+// The IDs of the script are taken from manager.IDSource().
+func (manager *Manager) Start(coroutine Coroutine, options ...Option) *Script {
+	options = append([]Option{WithIDSource(manager.ids)}, options...)
+	script := Start(coroutine, options...)
+	manager.Add(script)
+	return script
+}
+
+// Same as Start(), but reuses a script given back with
+// Release() if there is one, along with its control and
+// goroutine. The reused script gets a new ID, and starts
+// with no hooks, tags, values or stats, as if it was new.
+// Useful for games that create and end many short-lived
+// entities, to avoid the setup cost of new scripts.
+func (manager *Manager) Acquire(coroutine Coroutine, options ...Option) *Script {
+	manager.mu.Lock()
+	var script *Script
+	if n := len(manager.free); n > 0 {
+		script = manager.free[n-1]
+		manager.free[n-1] = nil
+		manager.free = manager.free[:n-1]
+	}
+	manager.mu.Unlock()
+	if script == nil {
+		return manager.Start(coroutine, options...)
+	}
+
+	options = append([]Option{WithIDSource(manager.ids)}, options...)
+	script.reuse(coroutine, options)
+	manager.Add(script)
+	return script
+}
+
+// Removes the script from the manager, and keeps it for
+// reuse with Acquire(). If the script is not done yet, it's
+// cancelled and updated until it's done. The script must
+// not be used after it's released. Closed scripts are only
+// removed, since they can't be reused.
+//
+//	Note: Must not be called by the coroutines of the script
+//	that is released.
+func (manager *Manager) Release(script *Script) {
+	manager.Remove(script)
+	if script.closed {
+		return
+	}
+	script.stop()
+
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.free = append(manager.free, script)
+}
+
+// Returns the number of released scripts
+// that are kept for reuse.
+func (manager *Manager) FreeLen() int {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return len(manager.free)
+}
+
+// Returns the ID source used for the scripts
+// started with manager.Start(). See WithIDSource().
+func (manager *Manager) IDSource() *IDSource {
+	return manager.ids
+}
+
+// Adds the script to the manager. Does nothing
+// if the script is already added. Scripts are updated in
+// order of priority, see WithPriority(), and in the
+// order they were added for the same priority.
+func (manager *Manager) Add(script *Script) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for _, e := range manager.entries {
+		if e.script == script {
+			return
+		}
+	}
+	// keep entries sorted by priority, in order of
+	// adding for entries with the same priority
+	priority := script.Priority()
+	index := len(manager.entries)
+	for index > 0 && manager.entries[index-1].script.Priority() < priority {
+		index--
+	}
+	shard := script.Shard()
+	if shard != "" {
+		script.baseControl.shardMu.Store(manager.acquireShard(shard))
+	}
+	manager.entries = slices.Insert(manager.entries, index, &managerEntry{
+		script:    script,
+		shard:     shard,
+		lastFrame: manager.frame,
+	})
+	if manager.paused {
+		script.baseControl.freezeClock(freezeManager)
+	}
+}
+
+// Pauses all scripts in the manager, including the scripts
+// added later. Update() does nothing until ResumeAll() is called,
+// and the clocks of the scripts are stopped so that pending
+// Sleep() calls continue where they left off.
+// Scripts paused with script.Pause() stay paused after ResumeAll().
+func (manager *Manager) PauseAll() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.paused = true
+	for _, e := range manager.entries {
+		e.script.baseControl.freezeClock(freezeManager)
+	}
+}
+
+// Resumes the scripts paused with PauseAll().
+func (manager *Manager) ResumeAll() {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.paused = false
+	for _, e := range manager.entries {
+		e.script.baseControl.unfreezeClock(freezeManager)
+	}
+}
+
+// Returns true if PauseAll() was called.
+func (manager *Manager) IsPaused() bool {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return manager.paused
+}
+
+// Removes the script from the manager.
+// The script is not cancelled.
+func (manager *Manager) Remove(script *Script) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for i, e := range manager.entries {
+		if e.script == script {
+			manager.removeAt(i)
+			return
+		}
+	}
+}
+
+// Sets the manager to only update background scripts every
+// nth frame, see Background(). The scripts are staggered by ID
+// so that they are not all updated on the same frame.
+// Sleep() is not slowed down since it is based on the clock,
+// but Delay() and Yield() take n times longer.
+// A value of n <= 1 updates background scripts on every frame.
+// Default is 4.
+func (manager *Manager) UpdateEvery(n int) {
+	if n < 1 {
+		n = 1
+	}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.backgroundRate = n
+}
+
+// Sets a function that rates the importance of each script
+// on every frame, for instance by the distance of its entity
+// to the camera, to update the less important scripts less
+// often. A script with an importance of 1 or more is updated on
+// every frame, 0.5 every other frame, 0.25 every 4th frame, and
+// so on, up to every 60th frame for an importance of zero or
+// less. As with UpdateEvery(), the scripts are staggered by ID,
+// and Sleep() is not slowed down. Background scripts use the
+// larger of the two intervals. A nil fn updates all scripts
+// on every frame.
+//
+//	manager.SetImportance(func(script *carrot.Script) float64 {
+//		return 10 / enemies[script].DistanceTo(camera)
+//	})
+func (manager *Manager) SetImportance(fn func(*Script) float64) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.importanceFn = fn
+}
+
+// Returns the number of scripts in the manager.
+func (manager *Manager) Len() int {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	return len(manager.entries)
+}
+
+// Cancels all scripts with the given tag.
+// See WithTags().
+func (manager *Manager) CancelTagged(tag string) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for _, e := range manager.entries {
+		if e.script.HasTag(tag) {
+			e.script.Cancel()
+		}
+	}
+}
+
+// Returns the script in the manager with the given name,
+// or nil if there is none. Unlike carrot.Lookup(), scripts that
+// are done are also found until they are removed from the
+// manager. See WithName().
+func (manager *Manager) Lookup(name string) *Script {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for i := len(manager.entries) - 1; i >= 0; i-- {
+		if script := manager.entries[i].script; script.Name() == name {
+			return script
+		}
+	}
+	return nil
+}
+
+// Returns the number of scripts with the given tag.
+func (manager *Manager) CountTagged(tag string) int {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	count := 0
+	for _, e := range manager.entries {
+		if e.script.HasTag(tag) {
+			count++
+		}
+	}
+	return count
+}
+
+// Calls Update() on all scripts, in order of priority.
+func (manager *Manager) Update() {
+	manager.updateMu.Lock()
+	defer manager.updateMu.Unlock()
+	entries, frame := manager.beginFrame()
+	for _, e := range entries {
+		if frame.isUpdateTurn(e) {
+			e.script.Update()
+		}
+	}
+	manager.endFrame(entries, frame)
+}
+
+// Same as Update(), but updates the scripts on up to n
+// goroutines at the same time, and waits for all of them.
+// The scripts of a shard are all updated on the same goroutine,
+// in order of priority, see WithShard(). The other scripts are
+// spread over the goroutines. A value of n <= 1 is the same
+// as Update().
+//
+//	Note: Scripts without a shard may run at the same time as
+//	any other script, so they must not share unsynchronized state.
+func (manager *Manager) UpdateParallel(n int) {
+	if n <= 1 {
+		manager.Update()
+		return
+	}
+	manager.updateMu.Lock()
+	defer manager.updateMu.Unlock()
+	entries, frame := manager.beginFrame()
+
+	batches := make([][]*managerEntry, n)
+	if manager.shardWorkers == nil {
+		manager.shardWorkers = map[string]int{}
+	}
+	next := 0
+	for _, e := range entries {
+		if !frame.isUpdateTurn(e) {
+			continue
+		}
+		worker := next
+		if e.shard != "" {
+			if w, ok := manager.shardWorkers[e.shard]; ok {
+				worker = w
+			} else {
+				manager.shardWorkers[e.shard] = worker
+				next = (next + 1) % n
+			}
+		} else {
+			next = (next + 1) % n
+		}
+		batches[worker] = append(batches[worker], e)
+	}
+	for key := range manager.shardWorkers {
+		delete(manager.shardWorkers, key)
+	}
+
+	var wg sync.WaitGroup
+	for _, batch := range batches {
+		if len(batch) == 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(batch []*managerEntry) {
+			defer wg.Done()
+			for _, e := range batch {
+				e.script.Update()
+			}
+		}(batch)
+	}
+	wg.Wait()
+	manager.endFrame(entries, frame)
+}
+
+// Calls UpdateWithAlpha() on all scripts.
+func (manager *Manager) UpdateWithAlpha(alpha float64) {
+	manager.updateMu.Lock()
+	defer manager.updateMu.Unlock()
+	entries, frame := manager.beginFrame()
+	for _, e := range entries {
+		if frame.isUpdateTurn(e) {
+			e.script.UpdateWithAlpha(alpha)
+		}
+	}
+	manager.endFrame(entries, frame)
+}
+
+// Updates as many scripts as it fits in the given time budget.
+// Scripts with higher priority are updated first, so the
+// scripts with lower priority are the ones deferred when
+// the budget runs out, see WithPriority(). Among scripts with
+// the same priority, the deferred ones are updated first on the
+// subsequent calls, in a round-robin manner, so that none of
+// them is left behind. At least one script is updated on
+// every call, unless none of them is due on this frame, see
+// UpdateEvery() and SetImportance(). See also MaxLag().
+func (manager *Manager) UpdateBudget(budget time.Duration) {
+	manager.updateMu.Lock()
+	defer manager.updateMu.Unlock()
+	entries, frame := manager.beginFrame()
+	// entries are sorted by priority already, the stable
+	// sort keeps the order of adding for ties. lastFrame is
+	// only written by the updates, which are serialized
+	slices.SortStableFunc(entries, func(a, b *managerEntry) bool {
+		pa, pb := a.script.Priority(), b.script.Priority()
+		if pa != pb {
+			return pa > pb
+		}
+		return a.lastFrame < b.lastFrame
+	})
+	startTime := time.Now()
+	updated := 0
+	for i, e := range entries {
+		if updated > 0 && time.Since(startTime) >= budget {
+			entries = entries[:i]
+			break
+		}
+		if frame.isUpdateTurn(e) {
+			e.script.Update()
+			updated++
+		}
+	}
+	manager.endFrame(entries, frame)
+}
+
+// Returns the highest number of frames a script has
+// not been updated. This will be zero if all
+// scripts were updated on the last frame.
+func (manager *Manager) MaxLag() int {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	lag := 0
+	for _, e := range manager.entries {
+		if manager.frame-e.lastFrame > lag {
+			lag = manager.frame - e.lastFrame
+		}
+	}
+	return lag
+}
+
+// Must be called while holding updateMu.
+func (manager *Manager) beginFrame() ([]*managerEntry, managerFrame) {
+	// entries are copied so that the lock is not held
+	// while updating, coroutines may add scripts
+	// at the same time
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.tempEntries = manager.tempEntries[:0]
+	if manager.paused {
+		return manager.tempEntries, managerFrame{}
+	}
+	manager.frame++
+	manager.tempEntries = append(manager.tempEntries, manager.entries...)
+	return manager.tempEntries, managerFrame{
+		number:     manager.frame,
+		rate:       manager.backgroundRate,
+		importance: manager.importanceFn,
+	}
+}
+
+// Returns false if the entry is a background or unimportant
+// script that is skipped on this frame. Skipped scripts are
+// not counted for MaxLag(), since they are not behind.
+func (frame managerFrame) isUpdateTurn(e *managerEntry) bool {
+	var rate int64 = 1
+	if e.script.IsBackground() {
+		rate = int64(frame.rate)
+	}
+	if frame.importance != nil {
+		if n := importanceInterval(frame.importance(e.script)); n > rate {
+			rate = n
+		}
+	}
+	if rate <= 1 {
+		return true
+	}
+	return (int64(frame.number)+e.script.baseControl.ID)%rate == 0
+}
+
+// Returns every how many frames a script
+// with the importance is updated.
+func importanceInterval(importance float64) int64 {
+	if importance >= 1 {
+		return 1
+	}
+	// also true for NaN
+	if !(importance > 1.0/maxImportanceInterval) {
+		return maxImportanceInterval
+	}
+	return int64(math.Round(1 / importance))
+}
+
+// Marks the entries as updated on the frame, whether they were
+// due or not, and removes the scripts that are done.
+func (manager *Manager) endFrame(updated []*managerEntry, frame managerFrame) {
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	for i, e := range updated {
+		e.lastFrame = frame.number
+		updated[i] = nil
+	}
+	for i := len(manager.entries) - 1; i >= 0; i-- {
+		if manager.entries[i].script.IsDone() {
+			manager.removeAt(i)
+		}
+	}
+}
+
+func (manager *Manager) removeAt(index int) {
+	e := manager.entries[index]
+	e.script.baseControl.unfreezeClock(freezeManager)
+	if e.shard != "" {
+		e.script.baseControl.shardMu.Store(nil)
+		manager.releaseShard(e.shard)
+	}
+	manager.entries = append(manager.entries[:index], manager.entries[index+1:]...)
+}
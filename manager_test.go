@@ -0,0 +1,559 @@
+package carrot_test
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+	"golang.org/x/exp/slices"
+)
+
+func TestManagerUpdate(t *testing.T) {
+	manager := carrot.NewManager()
+	count := atomic.Int32{}
+	for i := 0; i < 10; i++ {
+		manager.Start(func(ctrl *carrot.Control) {
+			for i := 0; i < 5; i++ {
+				count.Add(1)
+				ctrl.Yield()
+			}
+		})
+	}
+
+	for manager.Len() > 0 {
+		manager.Update()
+	}
+	if count.Load() != 50 {
+		t.Error("wrong count", count.Load())
+	}
+}
+
+func TestManagerUpdateBudget(t *testing.T) {
+	manager := carrot.NewManager()
+	count := atomic.Int32{}
+	for i := 0; i < 10; i++ {
+		manager.Start(func(ctrl *carrot.Control) {
+			for {
+				count.Add(1)
+				time.Sleep(time.Millisecond)
+				ctrl.Yield()
+			}
+		})
+	}
+
+	frames := 0
+	for count.Load() < 10 {
+		manager.UpdateBudget(time.Microsecond)
+		time.Sleep(2 * time.Millisecond)
+		frames++
+	}
+	if frames < 5 {
+		t.Error("should take several frames to update all scripts", frames)
+	}
+	if lag := manager.MaxLag(); lag >= 10 {
+		t.Error("lag is too large", lag)
+	}
+}
+
+func TestManagerTags(t *testing.T) {
+	manager := carrot.NewManager()
+	for i := 0; i < 3; i++ {
+		manager.Start(func(ctrl *carrot.Control) { ctrl.Abyss() }, carrot.WithTags("enemy"))
+	}
+	manager.Start(func(ctrl *carrot.Control) { ctrl.Abyss() }, carrot.WithTags("enemy", "boss"))
+	manager.Start(func(ctrl *carrot.Control) { ctrl.Abyss() }, carrot.WithTags("vfx"))
+
+	if n := manager.CountTagged("enemy"); n != 4 {
+		t.Error("wrong enemy count", n)
+	}
+	if n := manager.CountTagged("boss"); n != 1 {
+		t.Error("wrong boss count", n)
+	}
+
+	manager.CancelTagged("enemy")
+	for manager.Len() > 1 {
+		manager.Update()
+	}
+	if n := manager.CountTagged("vfx"); n != 1 {
+		t.Error("wrong vfx count", n)
+	}
+}
+
+func TestManagerIDSource(t *testing.T) {
+	run := func() []int64 {
+		var mu sync.Mutex
+		var ids []int64
+		record := func(ctrl *carrot.Control) {
+			mu.Lock()
+			ids = append(ids, ctrl.ID)
+			mu.Unlock()
+		}
+		manager := carrot.NewManager()
+		for i := 0; i < 2; i++ {
+			manager.Start(func(ctrl *carrot.Control) {
+				record(ctrl)
+				ctrl.StartAsync(record).Join(ctrl)
+			})
+		}
+		for manager.Len() > 0 {
+			manager.Update()
+			time.Sleep(updateDelay)
+		}
+		sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+		return ids
+	}
+
+	first := run()
+	second := run()
+	if fmt.Sprint(first) != "[1 2 3 4]" || fmt.Sprint(first) != fmt.Sprint(second) {
+		t.Error("IDs should be the same on every run", first, second)
+	}
+}
+
+func TestManagerSpawn(t *testing.T) {
+	var count atomic.Int32
+	enemy := carrot.Template(func(ctrl *carrot.Control) {
+		count.Add(1)
+		ctrl.Yield()
+	}, carrot.WithTags("enemy"))
+
+	manager := carrot.NewManager()
+	for i := 0; i < 10; i++ {
+		manager.Spawn(enemy)
+	}
+	boss := manager.Spawn(enemy, carrot.WithTags("boss"))
+	if !boss.HasTag("enemy") || !boss.HasTag("boss") {
+		t.Error("spawned script should have both tags")
+	}
+	if n := manager.CountTagged("enemy"); n != 11 {
+		t.Error("wrong enemy count", n)
+	}
+	if n := manager.CountTagged("boss"); n != 1 {
+		t.Error("extra options should not change the template", n)
+	}
+
+	for manager.Len() > 0 {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	if count.Load() != 11 {
+		t.Error("all spawned scripts should run", count.Load())
+	}
+}
+
+type manualClock struct{ nanos atomic.Int64 }
+
+func (clock *manualClock) Now() time.Time { return time.Unix(0, clock.nanos.Load()) }
+
+func (clock *manualClock) Add(d time.Duration) { clock.nanos.Add(int64(d)) }
+
+func TestManagerPauseAll(t *testing.T) {
+	clock := &manualClock{}
+	manager := carrot.NewManager()
+	script := manager.Start(func(ctrl *carrot.Control) {
+		ctrl.Sleep(10 * time.Second)
+		ctrl.Checkpoint("woke")
+	}, carrot.WithClock(clock))
+	update := func() {
+		for i := 0; i < 5; i++ {
+			manager.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+	woke := func() bool { return len(script.Checkpoints()) > 0 }
+
+	update()
+	clock.Add(5 * time.Second)
+	manager.PauseAll()
+	clock.Add(time.Hour)
+	update()
+	if woke() {
+		t.Fatal("paused script should not be updated")
+	}
+
+	manager.ResumeAll()
+	update()
+	if woke() {
+		t.Fatal("the time while paused should not count")
+	}
+	clock.Add(5 * time.Second)
+	update()
+	if !woke() {
+		t.Error("sleep should end after resuming")
+	}
+}
+
+func TestPauseAll(t *testing.T) {
+	var count atomic.Int32
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for {
+			count.Add(1)
+			ctrl.Yield()
+		}
+	})
+	defer carrot.ResumeAll()
+
+	script.Update()
+	script.Update()
+	carrot.PauseAll()
+	n := count.Load()
+	for i := 0; i < 5; i++ {
+		script.Update()
+	}
+	if count.Load() != n {
+		t.Error("script should not be resumed while paused")
+	}
+
+	script.Pause()
+	carrot.ResumeAll()
+	script.Update()
+	if count.Load() != n {
+		t.Error("script paused by itself should stay paused")
+	}
+	script.Resume()
+	script.Update()
+	script.Update()
+	if count.Load() == n {
+		t.Error("script should be resumed")
+	}
+	script.Cancel()
+	script.Update()
+}
+
+func TestPauseAllClock(t *testing.T) {
+	clock := &manualClock{}
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Sleep(10 * time.Second)
+		ctrl.Checkpoint("woke")
+	}, carrot.WithClock(clock))
+	defer carrot.ResumeAll()
+	update := func() {
+		for i := 0; i < 5; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+	woke := func() bool { return len(script.Checkpoints()) > 0 }
+
+	update()
+	clock.Add(5 * time.Second)
+	// no update happens while paused
+	carrot.PauseAll()
+	clock.Add(time.Hour)
+	carrot.ResumeAll()
+	update()
+	if woke() {
+		t.Fatal("the time while paused should not count")
+	}
+	clock.Add(5 * time.Second)
+	update()
+	if !woke() {
+		t.Error("sleep should end after resuming")
+	}
+	script.Cancel()
+	script.Update()
+}
+
+func TestManagerBackground(t *testing.T) {
+	manager := carrot.NewManager()
+	manager.UpdateEvery(3)
+
+	var frames [2]atomic.Int32
+	counter := func(i int) carrot.Coroutine {
+		return func(ctrl *carrot.Control) {
+			for {
+				frames[i].Add(1)
+				ctrl.Yield()
+			}
+		}
+	}
+	manager.Start(counter(0))
+	far := manager.Start(counter(1), carrot.Background())
+	if !far.IsBackground() {
+		t.Fatal("script should be in the background")
+	}
+
+	for i := 0; i < 31; i++ {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	near, background := frames[0].Load(), frames[1].Load()
+	if near < 29 || background < 9 || background > 11 {
+		t.Error("wrong number of frames", near, background)
+	}
+	if lag := manager.MaxLag(); lag != 0 {
+		t.Error("skipped background scripts should not lag", lag)
+	}
+
+	far.SetBackground(false)
+	for i := 0; i < 10; i++ {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	if n := frames[1].Load() - background; n < 9 {
+		t.Error("script should be updated every frame", n)
+	}
+}
+
+func TestManagerAcquireRelease(t *testing.T) {
+	manager := carrot.NewManager()
+	var cleanedUp atomic.Int32
+	var runs atomic.Int32
+	var id atomic.Int64
+	entity := func(ctrl *carrot.Control) {
+		defer cleanedUp.Add(1)
+		runs.Add(1)
+		id.Store(ctrl.ID)
+		ctrl.SetValue("hp", 10)
+		ctrl.Abyss()
+	}
+
+	first := manager.Acquire(entity, carrot.WithTags("enemy"))
+	for i := 0; i < 3; i++ {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	firstID := id.Load()
+	manager.Release(first)
+	if cleanedUp.Load() != 1 || manager.Len() != 0 || manager.FreeLen() != 1 {
+		t.Fatal("released script should be cancelled and removed", cleanedUp.Load(), manager.Len())
+	}
+
+	second := manager.Acquire(entity)
+	if second != first {
+		t.Fatal("released script should be reused")
+	}
+	if second.HasTag("enemy") || second.Value("hp") != nil {
+		t.Error("reused script should be reset")
+	}
+	for i := 0; i < 3; i++ {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	if id.Load() == firstID {
+		t.Error("reused script should get a new ID")
+	}
+	if runs.Load() != 2 || second.IsDone() || manager.Len() != 1 {
+		t.Error("reused script should run the new coroutine", runs.Load())
+	}
+	if manager.FreeLen() != 0 {
+		t.Error("free list should be empty", manager.FreeLen())
+	}
+}
+
+func TestPreAllocForManager(t *testing.T) {
+	manager := carrot.NewManager()
+	carrot.PreAllocForManager(manager, 3)
+	if manager.FreeLen() != 3 {
+		t.Fatal("expected 3 free scripts, got", manager.FreeLen())
+	}
+
+	var ran atomic.Bool
+	script := manager.Acquire(func(ctrl *carrot.Control) {
+		ran.Store(true)
+	})
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	if !ran.Load() || manager.FreeLen() != 2 {
+		t.Error("pre-allocated script should be used", manager.FreeLen())
+	}
+}
+
+func TestLookup(t *testing.T) {
+	manager := carrot.NewManager()
+	boss := manager.Start(func(ctrl *carrot.Control) {
+		ctrl.Delay(2)
+	}, carrot.WithName("test-boss"))
+
+	if carrot.Lookup("test-boss") != boss || manager.Lookup("test-boss") != boss {
+		t.Error("the script should be found by name")
+	}
+	if carrot.Lookup("test-missing") != nil {
+		t.Error("unknown names should not be found")
+	}
+	for i := 0; i < 5; i++ {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	if carrot.Lookup("test-boss") != nil {
+		t.Error("the script should be removed from the registry once done")
+	}
+
+	named := carrot.Start(func(ctrl *carrot.Control) { ctrl.Abyss() }, carrot.WithName("test-named"))
+	if !slices.Contains(carrot.RegisteredNames(), "test-named") {
+		t.Error("expected the name to be registered, got", carrot.RegisteredNames())
+	}
+	named.Close()
+	if carrot.Lookup("test-named") != nil {
+		t.Error("the script should be removed from the registry once closed")
+	}
+}
+
+func TestManagerPriority(t *testing.T) {
+	manager := carrot.NewManager()
+	var order []string
+	start := func(name string, priority int) {
+		script := manager.Start(func(ctrl *carrot.Control) {
+			ctrl.Yield()
+		}, carrot.WithPriority(priority))
+		// hooks are called in Update(), in the order
+		// the scripts are updated
+		script.OnStart(func() { order = append(order, name) })
+	}
+	start("low", -1)
+	start("a", 0)
+	start("high", 5)
+	start("b", 0)
+
+	manager.Update()
+	if result := strings.Join(order, " "); result != "high a b low" {
+		t.Error("wrong order", result)
+	}
+}
+
+func TestManagerUpdateBudgetPriority(t *testing.T) {
+	manager := carrot.NewManager()
+	var high, low atomic.Int32
+	for i := 0; i < 3; i++ {
+		manager.Start(func(ctrl *carrot.Control) {
+			for {
+				high.Add(1)
+				time.Sleep(time.Millisecond)
+				ctrl.Yield()
+			}
+		}, carrot.WithPriority(1))
+	}
+	manager.Start(func(ctrl *carrot.Control) {
+		for {
+			low.Add(1)
+			ctrl.Yield()
+		}
+	})
+
+	for i := 0; i < 5; i++ {
+		manager.UpdateBudget(time.Microsecond)
+		time.Sleep(2 * time.Millisecond)
+	}
+	if high.Load() < 3 {
+		t.Error("high priority scripts should be updated", high.Load())
+	}
+	if low.Load() != 0 {
+		t.Error("low priority script should be deferred", low.Load())
+	}
+}
+
+func TestManagerImportance(t *testing.T) {
+	manager := carrot.NewManager()
+
+	var frames [3]atomic.Int32
+	importance := map[*carrot.Script]float64{}
+	for i, value := range []float64{1, 0.25, 0} {
+		i := i
+		script := manager.Start(func(ctrl *carrot.Control) {
+			for {
+				frames[i].Add(1)
+				ctrl.Yield()
+			}
+		})
+		importance[script] = value
+	}
+	manager.SetImportance(func(script *carrot.Script) float64 {
+		return importance[script]
+	})
+
+	for i := 0; i < 120; i++ {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	near, far, hidden := frames[0].Load(), frames[1].Load(), frames[2].Load()
+	if near < 118 || far < 29 || far > 31 || hidden < 1 || hidden > 3 {
+		t.Error("wrong number of frames", near, far, hidden)
+	}
+	if lag := manager.MaxLag(); lag != 0 {
+		t.Error("skipped scripts should not lag", lag)
+	}
+
+	manager.SetImportance(nil)
+	for i := 0; i < 10; i++ {
+		manager.Update()
+		time.Sleep(updateDelay)
+	}
+	if n := frames[2].Load() - hidden; n < 9 {
+		t.Error("script should be updated every frame", n)
+	}
+}
+
+func TestManagerUpdateBudgetNotDue(t *testing.T) {
+	manager := carrot.NewManager()
+	var hidden, near atomic.Int32
+	first := manager.Start(func(ctrl *carrot.Control) {
+		for {
+			hidden.Add(1)
+			ctrl.Yield()
+		}
+	}, carrot.WithPriority(1))
+	manager.Start(func(ctrl *carrot.Control) {
+		for {
+			near.Add(1)
+			ctrl.Yield()
+		}
+	})
+	manager.SetImportance(func(script *carrot.Script) float64 {
+		if script == first {
+			return 0
+		}
+		return 1
+	})
+
+	for i := 0; i < 10; i++ {
+		manager.UpdateBudget(0)
+		time.Sleep(updateDelay)
+	}
+	// the first script is due at most once in 10 frames,
+	// and only then uses up the budget
+	if n := near.Load(); n < 9 {
+		t.Error("a script should be updated on every call", n, hidden.Load())
+	}
+}
+
+func TestManagerConcurrentUpdate(t *testing.T) {
+	manager := carrot.NewManager()
+	for i := 0; i < 5; i++ {
+		manager.Start(func(ctrl *carrot.Control) {
+			for {
+				ctrl.Yield()
+			}
+		}, carrot.Background())
+	}
+	manager.SetImportance(func(*carrot.Script) float64 { return 0.5 })
+
+	var wg sync.WaitGroup
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				switch i {
+				case 0:
+					manager.Update()
+				case 1:
+					manager.UpdateBudget(time.Millisecond)
+				default:
+					manager.MaxLag()
+					manager.UpdateEvery(j%4 + 1)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	if n := manager.Len(); n != 5 {
+		t.Error("scripts should still be running", n)
+	}
+}
@@ -0,0 +1,104 @@
+package carrot
+
+// An Option configures a coroutine when it is started.
+type Option func(ctrl *Control)
+
+// Sets the priority of a child coroutine or a script.
+// Within a frame, children with higher priority are resumed
+// before those with lower priority. Children with the same
+// priority are resumed in the order they were started.
+// Children with negative priority are considered low-priority,
+// and may be skipped for the frame when the parent's
+// frame budget is exceeded. See also SetFrameBudget().
+// Likewise, a Manager updates scripts with higher priority
+// first, see manager.UpdateBudget().
+// Default priority is 0.
+func WithPriority(priority int) Option {
+	return func(ctrl *Control) {
+		ctrl.priority.Store(int64(priority))
+	}
+}
+
+// Keeps the child coroutine running when the parent
+// is restarted with RestartKeepChildren().
+func KeepOnRestart() Option {
+	return func(ctrl *Control) {
+		ctrl.keepOnRestart = true
+	}
+}
+
+// Marks the script as a background script, which
+// a Manager updates less often. See manager.UpdateEvery().
+// Only has effect when used with Start() or Create().
+func Background() Option {
+	return func(ctrl *Control) {
+		ctrl.background.Store(true)
+	}
+}
+
+// Resumes the coroutines of the script one at a time,
+// waiting for each one to yield before resuming the next,
+// so they never run at the same time and always run in
+// the same order. Used by carrottest.Stress() to make
+// the interleavings reproducible.
+// Only has effect when used with Start() or Create().
+//
+//	Note: A coroutine that blocks without yielding,
+//	for instance on a channel, blocks the update as well.
+func Serialized() Option {
+	return func(ctrl *Control) {
+		ctrl.serialized.Store(true)
+	}
+}
+
+// Puts the script in a shard. Coroutines of scripts in the
+// same shard never run at the same time, so scripts that change
+// the same entity or world region don't race with each other,
+// while scripts in other shards still run in parallel.
+// manager.UpdateParallel() also updates the scripts of a shard
+// on the same goroutine. Only has effect when used with Start()
+// or Create(), and while the script is in a Manager.
+//
+//	Note: A coroutine holds its shard until it yields,
+//	so it must not block waiting on another coroutine
+//	of the same shard, for instance on a channel.
+func WithShard(key string) Option {
+	return func(ctrl *Control) {
+		ctrl.shard = key
+	}
+}
+
+// Attaches tags to a script or coroutine, used
+// for filtering and bulk operations, for instance
+// manager.CancelTagged("enemy").
+func WithTags(tags ...string) Option {
+	return func(ctrl *Control) {
+		ctrl.tagsMu.Lock()
+		ctrl.tags = append(ctrl.tags, tags...)
+		ctrl.tagsMu.Unlock()
+	}
+}
+
+// Sets the source of the IDs of the script and
+// its child coroutines. Only has effect when used with
+// Start() or Create(). A nil source uses the
+// package-wide counter.
+func WithIDSource(src *IDSource) Option {
+	return func(ctrl *Control) {
+		if src == nil {
+			return
+		}
+		ctrl.ids = src
+		ctrl.ID = src.Next()
+	}
+}
+
+// Sets the clock that is used by the script for Sleep()
+// and ctrl.Now(). Only has effect when used with Start()
+// or Create(), child coroutines always use the
+// clock of their script.
+func WithClock(clock Clock) Option {
+	return func(ctrl *Control) {
+		ctrl.clock = clock
+	}
+}
@@ -0,0 +1,27 @@
+package carrot_test
+
+import (
+	"testing"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestWithPriority(t *testing.T) {
+	ctrl := carrot.NewControl()
+	noop := func(*carrot.Control) {}
+	low := ctrl.StartAsync(noop, carrot.WithPriority(-1))
+	normal := ctrl.StartAsync(noop)
+	high := ctrl.StartAsync(noop, carrot.WithPriority(10))
+	normal2 := ctrl.StartAsync(noop)
+
+	expected := []carrot.SubControl{high, normal, normal2, low}
+	children := ctrl.Children()
+	if len(children) != len(expected) {
+		t.Fatal("wrong number of subs", len(children))
+	}
+	for i, sub := range children {
+		if sub != expected[i] {
+			t.Errorf("wrong order at %v: %v", i, sub)
+		}
+	}
+}
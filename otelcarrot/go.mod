@@ -0,0 +1,17 @@
+module github.com/nvlled/carrot/otelcarrot
+
+go 1.19
+
+require (
+	github.com/nvlled/carrot v0.0.0
+	go.opentelemetry.io/otel v1.11.2
+	go.opentelemetry.io/otel/sdk v1.11.2
+	go.opentelemetry.io/otel/trace v1.11.2
+)
+
+// replace directives of the carrot module are not
+// inherited, so its replace of mud is repeated here
+replace (
+	github.com/nvlled/carrot => ../
+	github.com/nvlled/mud => /home/nvlled/code/mud
+)
@@ -0,0 +1,79 @@
+// Package otelcarrot adapts an OpenTelemetry TracerProvider
+// to carrot.Tracer, so that each run of a coroutine is
+// recorded as an OpenTelemetry span.
+//
+// It's a separate module so that carrot itself does
+// not depend on OpenTelemetry.
+//
+//	script := carrot.Start(fn, otelcarrot.WithTracerProvider(ctx, provider))
+package otelcarrot
+
+import (
+	"context"
+	"errors"
+
+	"github.com/nvlled/carrot"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The instrumentation scope of the tracers
+// created with NewTracer().
+const ScopeName = "github.com/nvlled/carrot/otelcarrot"
+
+// Attribute keys set on the spans.
+const (
+	IDKey        = attribute.Key("carrot.id")
+	CancelledKey = attribute.Key("carrot.cancelled")
+)
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+// Returns a carrot.Tracer that opens spans with a tracer
+// of the provider. A nil provider uses trace.NewNoopTracerProvider().
+func NewTracer(provider trace.TracerProvider) carrot.Tracer {
+	if provider == nil {
+		provider = trace.NewNoopTracerProvider()
+	}
+	return tracer{provider.Tracer(ScopeName)}
+}
+
+// Same as carrot.WithTracer(ctx, NewTracer(provider)).
+func WithTracerProvider(ctx context.Context, provider trace.TracerProvider) carrot.Option {
+	return carrot.WithTracer(ctx, NewTracer(provider))
+}
+
+func (t tracer) StartSpan(ctx context.Context, name string, id int64) (context.Context, carrot.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(IDKey.Int64(id)))
+	return ctx, otelSpan{span}
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+func (s otelSpan) AddEvent(name string, attrs map[string]string) {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		kvs = append(kvs, attribute.String(k, v))
+	}
+	s.span.AddEvent(name, trace.WithAttributes(kvs...))
+}
+
+// Cancelled coroutines are not errors, they are marked with
+// the carrot.cancelled attribute instead. Panics are recorded
+// as errors and set the status of the span.
+func (s otelSpan) End(err error) {
+	switch {
+	case err == nil:
+	case errors.Is(err, carrot.ErrCancelled):
+		s.span.SetAttributes(CancelledKey.Bool(true))
+	default:
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
@@ -0,0 +1,66 @@
+package otelcarrot_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+	"github.com/nvlled/carrot/otelcarrot"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const updateDelay = time.Millisecond
+
+func TestTracerProvider(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			panic("failed")
+		})
+		ctrl.YieldUntil(sub.IsDone)
+		ctrl.Transition(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+	}, otelcarrot.WithTracerProvider(context.Background(), provider))
+	for i := 0; i < 6; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Cancel()
+	script.Update()
+	time.Sleep(updateDelay)
+
+	spans := recorder.Ended()
+	if len(spans) != 3 {
+		t.Fatal("expected 3 spans, got", len(spans))
+	}
+	child, first, second := spans[0], spans[1], spans[2]
+
+	if child.Parent().SpanID() != first.SpanContext().SpanID() {
+		t.Error("the span of the child should be a child of its parent's span")
+	}
+	if child.Status().Code != codes.Error || len(child.Events()) == 0 {
+		t.Error("the panic should be recorded as an error", child.Status())
+	}
+	if events := first.Events(); len(events) != 1 || events[0].Name != "transition" {
+		t.Error("expected a transition event", events)
+	}
+	if first.Status().Code == codes.Error {
+		t.Error("the first run should end normally", first.Status())
+	}
+	var cancelled bool
+	for _, attr := range second.Attributes() {
+		if attr.Key == otelcarrot.CancelledKey {
+			cancelled = attr.Value.AsBool()
+		}
+	}
+	if !cancelled || second.Status().Code == codes.Error {
+		t.Error("a cancelled run should be marked, not failed", second.Attributes(), second.Status())
+	}
+}
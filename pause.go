@@ -0,0 +1,108 @@
+package carrot
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// reasons for freezing the clock of a script,
+// the clock runs again once all are removed
+const (
+	freezeScript uint32 = 1 << iota
+	freezeManager
+	freezeGlobal
+	freezeSimulation
+)
+
+var allPaused atomic.Bool
+
+// the running scripts, so that PauseAll() can
+// stop their clocks without waiting for Update()
+var running = struct {
+	mu      sync.Mutex
+	scripts map[*Script]struct{}
+}{scripts: map[*Script]struct{}{}}
+
+// Pauses all scripts. Scripts are not resumed on
+// Update() until ResumeAll() is called, and their clocks
+// are stopped so that pending Sleep() calls continue
+// where they left off. Scripts paused with script.Pause()
+// stay paused after ResumeAll().
+// See also manager.PauseAll().
+func PauseAll() {
+	running.mu.Lock()
+	defer running.mu.Unlock()
+	allPaused.Store(true)
+	for script := range running.scripts {
+		script.baseControl.freezeClock(freezeGlobal)
+	}
+}
+
+// Resumes the scripts paused with PauseAll().
+func ResumeAll() {
+	running.mu.Lock()
+	defer running.mu.Unlock()
+	allPaused.Store(false)
+	for script := range running.scripts {
+		script.baseControl.unfreezeClock(freezeGlobal)
+	}
+}
+
+// Returns true if PauseAll() was called.
+func IsAllPaused() bool {
+	return allPaused.Load()
+}
+
+func (script *Script) trackRunning() {
+	running.mu.Lock()
+	defer running.mu.Unlock()
+	running.scripts[script] = struct{}{}
+	if allPaused.Load() {
+		script.baseControl.freezeClock(freezeGlobal)
+	}
+}
+
+func (script *Script) untrackRunning() {
+	running.mu.Lock()
+	delete(running.scripts, script)
+	running.mu.Unlock()
+}
+
+// Stops the clock of the script, as seen by ctrl.Now(),
+// until unfreezeClock() is called with the same reason.
+func (ctrl *Control) freezeClock(reason uint32) {
+	if ctrl.frozen.Load()&reason != 0 {
+		return
+	}
+	ctrl.freezeMu.Lock()
+	defer ctrl.freezeMu.Unlock()
+	frozen := ctrl.frozen.Load()
+	if frozen&reason != 0 {
+		return
+	}
+	if frozen == 0 {
+		ctrl.frozenAt.Store(ctrl.clockNow().UnixNano())
+	}
+	ctrl.frozen.Store(frozen | reason)
+}
+
+func (ctrl *Control) unfreezeClock(reason uint32) {
+	if ctrl.frozen.Load()&reason == 0 {
+		return
+	}
+	ctrl.freezeMu.Lock()
+	defer ctrl.freezeMu.Unlock()
+	frozen := ctrl.frozen.Load()
+	if frozen&reason == 0 {
+		return
+	}
+	frozen &^= reason
+	if frozen == 0 {
+		// shift the clock back by the time spent frozen
+		at := time.Unix(0, ctrl.frozenAt.Load())
+		ctrl.clockOffset.Add(int64(at.Sub(ctrl.clockNow())))
+		ctrl.frozenAt.Store(0)
+	}
+	ctrl.frozen.Store(frozen)
+}
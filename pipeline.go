@@ -0,0 +1,44 @@
+package carrot
+
+// Starts a child coroutine that pushes values to the
+// returned queue, which can then be passed on to Pipeline().
+// The queue is closed once the child coroutine ends.
+func Produce[T any](ctrl *Control, capacity int, fn func(*Control, *Queue[T])) *Queue[T] {
+	out := NewQueue[T](capacity)
+	ctrl.StartAsync(func(ctrl *Control) {
+		defer out.Close()
+		fn(ctrl, out)
+	})
+	return out
+}
+
+// Starts a child coroutine that calls fn on each value popped
+// from the in queue. fn may push any number of values to
+// the returned queue, to be consumed by the next stage.
+//
+// When the in queue is closed and empty, the stage ends
+// and closes its output queue, so that the stages after
+// it end as well. When the stage ends early, for instance
+// when it's cancelled, the in queue is closed too, so
+// that the stages before it stop on their next Push().
+//
+//	paths := carrot.Produce(ctrl, 4, listAssets)
+//	data := carrot.Pipeline(ctrl, paths, 4, loadAsset)
+//	for asset, ok := data.Pop(ctrl); ok; asset, ok = data.Pop(ctrl) {
+//		...
+//	}
+func Pipeline[In, Out any](ctrl *Control, in *Queue[In], capacity int, fn func(*Control, In, *Queue[Out])) *Queue[Out] {
+	out := NewQueue[Out](capacity)
+	ctrl.StartAsync(func(ctrl *Control) {
+		defer in.Close()
+		defer out.Close()
+		for {
+			value, ok := in.Pop(ctrl)
+			if !ok {
+				break
+			}
+			fn(ctrl, value, out)
+		}
+	})
+	return out
+}
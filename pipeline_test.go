@@ -0,0 +1,75 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestPipeline(t *testing.T) {
+	var result []int
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		numbers := carrot.Produce(ctrl, 2, func(ctrl *carrot.Control, out *carrot.Queue[int]) {
+			for i := 1; i <= 5; i++ {
+				out.Push(ctrl, i)
+			}
+		})
+		squares := carrot.Pipeline(ctrl, numbers, 2, func(ctrl *carrot.Control, n int, out *carrot.Queue[int]) {
+			ctrl.Yield()
+			out.Push(ctrl, n*n)
+		})
+		for n, ok := squares.Pop(ctrl); ok; n, ok = squares.Pop(ctrl) {
+			result = append(result, n)
+		}
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	expected := []int{1, 4, 9, 16, 25}
+	if len(result) != len(expected) {
+		t.Fatal("wrong result", result)
+	}
+	for i := range expected {
+		if result[i] != expected[i] {
+			t.Fatal("wrong result", result)
+		}
+	}
+}
+
+func TestPipelineCancel(t *testing.T) {
+	var produced atomic.Int32
+	var producerDone atomic.Bool
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		numbers := carrot.Produce(ctrl, 1, func(ctrl *carrot.Control, out *carrot.Queue[int]) {
+			defer producerDone.Store(true)
+			for i := 0; ; i++ {
+				out.Push(ctrl, i)
+				produced.Add(1)
+			}
+		})
+		carrot.Pipeline(ctrl, numbers, 1, func(ctrl *carrot.Control, n int, out *carrot.Queue[int]) {
+			ctrl.Abyss()
+		})
+		stage := ctrl.Children()[1]
+		ctrl.Delay(3)
+		stage.Cancel()
+		ctrl.YieldUntil(producerDone.Load)
+	})
+
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if !script.IsDone() {
+		t.Error("producer was not stopped after the stage was cancelled")
+	}
+	if n := produced.Load(); n > 3 {
+		t.Error("producer did not wait on a full queue", n)
+	}
+}
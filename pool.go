@@ -1,26 +1,207 @@
 package carrot
 
 import (
+	"sync"
+	"time"
+
 	"github.com/nvlled/mud"
 )
 
 // TODO: use arena
 var coroutinePool = mud.NewPool()
 
+// bookkeeping of coroutinePool, see SetPoolLimits()
+var poolState struct {
+	mu sync.Mutex
+	// number of controls in the pool
+	pooled int
+	// number of controls allocated and not yet freed
+	live int
+	// highest live count since the last shrink
+	peak      int
+	lastCheck time.Time
+
+	min, max int
+}
+
+// how often the pool is checked for shrinking
+const poolShrinkInterval = time.Second
+
 func init() {
 	PreAllocCoroutines(5)
 }
 
-// Pre-allocate a number of coroutine.
+// Pre-allocate a number of coroutine. The goroutines of the
+// coroutines are started before returning, so that
+// allocating them later, for instance on the first frames
+// after a scene load, doesn't pay for starting goroutines.
 func PreAllocCoroutines(count int) {
-	mud.PreAlloc(coroutinePool, NewControl, count)
+	ctrls := newWarmControls(count)
+	i := 0
+	poolState.mu.Lock()
+	defer poolState.mu.Unlock()
+	mud.PreAlloc(coroutinePool, func() *Control {
+		ctrl := ctrls[i]
+		i++
+		return ctrl
+	}, len(ctrls))
+	poolState.pooled += len(ctrls)
+}
+
+// Sets the number of coroutines kept in the pool for reuse.
+// Coroutines that are freed while the pool already has max
+// coroutines are stopped instead, ending their goroutines.
+// The pool is also shrunk over time, down to the highest
+// number of coroutines in use during the last second, but not
+// below min. A max of zero or less means no limit. The
+// default is no limits.
+func SetPoolLimits(min, max int) {
+	if min < 0 {
+		min = 0
+	}
+	if max > 0 && min > max {
+		min = max
+	}
+	poolState.mu.Lock()
+	defer poolState.mu.Unlock()
+	poolState.min = min
+	poolState.max = max
+	if max > 0 {
+		shrinkPoolLocked(max)
+	}
+}
+
+// Shrinks the pool right away, down to the minimum set
+// with SetPoolLimits(). The goroutines of the
+// removed coroutines are ended.
+func ShrinkPool() {
+	poolState.mu.Lock()
+	defer poolState.mu.Unlock()
+	shrinkPoolLocked(0)
+	poolState.peak = poolState.live
+	poolState.lastCheck = time.Now()
 }
 
-func allocCoroutine() *Control {
+// Returns the number of coroutines in the pool, and
+// the number of pooled coroutines currently in use.
+func PoolLen() (pooled, live int) {
+	poolState.mu.Lock()
+	defer poolState.mu.Unlock()
+	return poolState.pooled, poolState.live
+}
+
+// Pre-allocates a number of scripts for manager.Acquire(),
+// with their goroutines already started.
+// See also PreAllocCoroutines().
+func PreAllocForManager(manager *Manager, count int) {
+	ctrls := newWarmControls(count)
+	scripts := make([]*Script, len(ctrls))
+	for i, ctrl := range ctrls {
+		scripts[i] = &Script{baseControl: ctrl}
+		ctrl.initialize(nil)
+	}
+	manager.mu.Lock()
+	defer manager.mu.Unlock()
+	manager.free = append(manager.free, scripts...)
+}
+
+// Creates the controls, and waits until
+// all of their goroutines have started.
+func newWarmControls(count int) []*Control {
+	if count < 0 {
+		count = 0
+	}
+	ctrls := make([]*Control, count)
+	for i := range ctrls {
+		ctrls[i] = NewControl()
+	}
+	for _, ctrl := range ctrls {
+		<-ctrl.ready
+	}
+	return ctrls
+}
+
+func allocCoroutine(owner *Control) *Control {
+	if owner.root().syncPool {
+		co := allocSyncCoroutine()
+		co.owner.Store(owner)
+		if poolTracing.Load() {
+			traceAlloc(co, owner)
+		}
+		return co
+	}
+	poolState.mu.Lock()
+	defer poolState.mu.Unlock()
 	co := mud.Alloc(coroutinePool, NewControl)
+	co.owner.Store(owner)
+	if poolTracing.Load() {
+		traceAlloc(co, owner)
+	}
+	if poolState.pooled > 0 {
+		poolState.pooled--
+	}
+	poolState.live++
+	if poolState.live > poolState.peak {
+		poolState.peak = poolState.live
+	}
 	return co
 }
 
-func freeCoroutine(co *Control) {
-	mud.Free(coroutinePool, co)
+// Puts the control back in the pool, unless the owner,
+// the parent that allocated it, no longer owns it.
+func freeCoroutine(co, owner *Control) {
+	if !co.owner.CompareAndSwap(owner, nil) {
+		// the control is already in the pool, or was handed
+		// out again, freeing it would give it two owners
+		kind := DoubleFree
+		if co.owner.Load() != nil {
+			kind = StaleFree
+		}
+		if poolTracing.Load() {
+			traceBadFree(co, kind)
+		}
+		co.Logf("ignored free: %v", kind)
+		return
+	}
+	if poolTracing.Load() {
+		traceFree(co)
+	}
+	if co.syncEntry != nil {
+		freeSyncCoroutine(co)
+		return
+	}
+	poolState.mu.Lock()
+	defer poolState.mu.Unlock()
+	if poolState.live > 0 {
+		poolState.live--
+	}
+	if poolState.max > 0 && poolState.pooled >= poolState.max {
+		close(co.quit)
+	} else {
+		mud.Free(coroutinePool, co)
+		poolState.pooled++
+	}
+
+	now := time.Now()
+	if now.Sub(poolState.lastCheck) >= poolShrinkInterval {
+		if !poolState.lastCheck.IsZero() {
+			shrinkPoolLocked(poolState.peak)
+		}
+		poolState.peak = poolState.live
+		poolState.lastCheck = now
+	}
+}
+
+// Stops pooled coroutines until there are at most size
+// of them, but not less than the minimum pool size.
+// Must be called with poolState.mu held.
+func shrinkPoolLocked(size int) {
+	if size < poolState.min {
+		size = poolState.min
+	}
+	for poolState.pooled > size {
+		co := mud.Alloc(coroutinePool, NewControl)
+		poolState.pooled--
+		close(co.quit)
+	}
 }
@@ -0,0 +1,49 @@
+package carrot
+
+import "testing"
+
+func TestFreeCoroutineOwner(t *testing.T) {
+	TracePool(true)
+	defer TracePool(false)
+
+	owner, other := NewControl(), NewControl()
+	co := allocCoroutine(owner)
+	freeCoroutine(co, owner)
+	pooled, _ := PoolLen()
+
+	freeCoroutine(co, owner)
+	if n, _ := PoolLen(); n != pooled {
+		t.Error("double free should not put the control back in the pool", n, pooled)
+	}
+
+	// allocate until the control is handed out again
+	var held []*Control
+	for i := 0; i < 100; i++ {
+		c := allocCoroutine(other)
+		held = append(held, c)
+		if c == co {
+			break
+		}
+	}
+	if co.owner.Load() != other {
+		t.Fatal("control should be allocated again")
+	}
+	freeCoroutine(co, owner)
+	if co.owner.Load() != other {
+		t.Error("stale free should not take the control from its new owner")
+	}
+
+	var kinds []PoolMisuseKind
+	for _, misuse := range PoolTraceReport() {
+		if misuse.ID == co.ID && misuse.Kind != NeverFreed {
+			kinds = append(kinds, misuse.Kind)
+		}
+	}
+	if len(kinds) != 2 || kinds[0] != DoubleFree || kinds[1] != StaleFree {
+		t.Error("ignored frees should be reported", kinds)
+	}
+
+	for _, c := range held {
+		freeCoroutine(c, other)
+	}
+}
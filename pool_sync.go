@@ -0,0 +1,60 @@
+package carrot
+
+import (
+	"runtime"
+	"sync"
+)
+
+// Child coroutines of scripts started with WithSyncPool()
+// are kept here instead of coroutinePool. The entry is what's
+// pooled, since the control itself is always reachable from
+// its goroutine. Once sync.Pool drops an entry, the finalizer
+// ends the goroutine of the control.
+var syncControlPool sync.Pool
+
+func init() {
+	syncControlPool.New = newSyncPoolEntry
+}
+
+type syncPoolEntry struct {
+	ctrl *Control
+}
+
+func newSyncPoolEntry() any {
+	entry := &syncPoolEntry{ctrl: NewControl()}
+	runtime.SetFinalizer(entry, stopSyncPoolEntry)
+	return entry
+}
+
+func stopSyncPoolEntry(entry *syncPoolEntry) {
+	close(entry.ctrl.quit)
+}
+
+// Allocates the child coroutines of the script from a
+// sync.Pool instead of the default pool. Unused coroutines
+// are then released by the garbage collector, with no
+// need for PreAllocCoroutines() or SetPoolLimits(), at the cost
+// of starting new goroutines more often. Useful for server
+// workloads where coroutines are created in bursts.
+// Only has effect when used with Start() or Create().
+func WithSyncPool() Option {
+	return func(ctrl *Control) {
+		ctrl.syncPool = true
+	}
+}
+
+func allocSyncCoroutine() *Control {
+	entry := syncControlPool.Get().(*syncPoolEntry)
+	ctrl := entry.ctrl
+	ctrl.syncEntry = entry
+	return ctrl
+}
+
+// Must be called after the owner is cleared.
+func freeSyncCoroutine(ctrl *Control) {
+	entry := ctrl.syncEntry
+	// the control must not keep its entry
+	// alive, or it would never be finalized
+	ctrl.syncEntry = nil
+	syncControlPool.Put(entry)
+}
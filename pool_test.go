@@ -0,0 +1,66 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestPoolLimits(t *testing.T) {
+	defer carrot.SetPoolLimits(0, 0)
+	carrot.SetPoolLimits(1, 4)
+	if pooled, _ := carrot.PoolLen(); pooled > 4 {
+		t.Fatal("pool should be shrunk to the max, got", pooled)
+	}
+
+	_, liveBefore := carrot.PoolLen()
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 10; i++ {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				ctrl.Yield()
+			})
+		}
+		ctrl.Abyss()
+	})
+	for i := 0; i < 5; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if pooled, live := carrot.PoolLen(); pooled != 4 || live != liveBefore {
+		t.Error("freed coroutines over the max should be stopped", pooled, live)
+	}
+
+	carrot.ShrinkPool()
+	if pooled, _ := carrot.PoolLen(); pooled != 1 {
+		t.Error("pool should be shrunk to the min, got", pooled)
+	}
+	script.Close()
+}
+
+func TestSyncPool(t *testing.T) {
+	_, liveBefore := carrot.PoolLen()
+	var count atomic.Int32
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for round := 0; round < 3; round++ {
+			for i := 0; i < 10; i++ {
+				ctrl.StartAsync(func(ctrl *carrot.Control) {
+					ctrl.Yield()
+					count.Add(1)
+				})
+			}
+			ctrl.Delay(3)
+		}
+	}, carrot.WithSyncPool())
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if count.Load() != 30 {
+		t.Error("expected all children to run, got", count.Load())
+	}
+	if _, live := carrot.PoolLen(); live != liveBefore {
+		t.Error("children should not be allocated from the default pool")
+	}
+}
@@ -0,0 +1,197 @@
+package carrot
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var poolTracing atomic.Bool
+
+var poolTrace = struct {
+	mu      sync.Mutex
+	allocs  map[*Control]*allocRecord
+	misuses []PoolMisuse
+}{allocs: map[*Control]*allocRecord{}}
+
+type allocRecord struct {
+	allocated   time.Time
+	stack       string
+	owner       int64
+	ownerName   string
+	freed       bool
+	freeStack   string
+	reportedUse bool
+}
+
+// A PoolMisuseKind tells what went wrong with
+// a pooled coroutine. See PoolMisuse.
+type PoolMisuseKind int
+
+const (
+	// The coroutine was allocated and not freed yet.
+	NeverFreed PoolMisuseKind = iota
+	// The coroutine was freed while it was already
+	// in the pool. The second free is ignored.
+	DoubleFree
+	// The coroutine was updated after it was freed.
+	UseAfterFree
+	// The coroutine was freed by a parent that no longer
+	// owns it, since it was freed and allocated again
+	// for another owner. The free is ignored.
+	StaleFree
+)
+
+func (kind PoolMisuseKind) String() string {
+	switch kind {
+	case NeverFreed:
+		return "never freed"
+	case DoubleFree:
+		return "freed twice"
+	case UseAfterFree:
+		return "used after free"
+	case StaleFree:
+		return "freed by a previous owner"
+	}
+	return "unknown"
+}
+
+// A PoolMisuse describes a pooled child coroutine that was
+// not freed, or was freed or used incorrectly.
+// See PoolTraceReport().
+type PoolMisuse struct {
+	Kind PoolMisuseKind
+	ID   int64
+	// ID and coroutine name of the parent
+	// that started the coroutine.
+	Owner     int64
+	OwnerName string
+	// Time since the coroutine was allocated.
+	Age time.Duration
+	// Stack traces of where the coroutine was allocated,
+	// where it was first freed, and where the misuse
+	// happened. Empty if not applicable.
+	AllocStack string
+	FreeStack  string
+	Stack      string
+}
+
+func (misuse PoolMisuse) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "coroutine %v %v, owned by %v (%v), allocated %v ago",
+		misuse.ID, misuse.Kind, misuse.Owner, misuse.OwnerName, misuse.Age)
+	fmt.Fprintf(&b, "\nallocated at:\n%v", misuse.AllocStack)
+	if misuse.FreeStack != "" {
+		fmt.Fprintf(&b, "\nfreed at:\n%v", misuse.FreeStack)
+	}
+	if misuse.Stack != "" {
+		fmt.Fprintf(&b, "\n%v at:\n%v", misuse.Kind, misuse.Stack)
+	}
+	return b.String()
+}
+
+// Enables or disables tracing of the child coroutines
+// allocated from the pool, for PoolTraceReport(). Useful for
+// debugging coroutines that interfere with each other
+// because the same control is used by two owners.
+// Disabled by default, since it records stack traces on
+// every allocation and free.
+func TracePool(enable bool) {
+	poolTracing.Store(enable)
+	poolTrace.mu.Lock()
+	poolTrace.allocs = map[*Control]*allocRecord{}
+	poolTrace.misuses = nil
+	poolTrace.mu.Unlock()
+}
+
+// Returns the double frees and uses after free recorded so
+// far, followed by the coroutines that are allocated and not
+// freed yet, oldest first. The latter includes coroutines that
+// are still running, so it's best called when all scripts
+// should be done, such as at the end of a test.
+// See TracePool().
+func PoolTraceReport() []PoolMisuse {
+	now := time.Now()
+	poolTrace.mu.Lock()
+	defer poolTrace.mu.Unlock()
+
+	misuses := append([]PoolMisuse(nil), poolTrace.misuses...)
+	var unfreed []PoolMisuse
+	for ctrl, record := range poolTrace.allocs {
+		if record.freed {
+			continue
+		}
+		unfreed = append(unfreed, record.misuse(NeverFreed, ctrl, now, ""))
+	}
+	sort.Slice(unfreed, func(i, j int) bool {
+		return unfreed[i].Age > unfreed[j].Age
+	})
+	return append(misuses, unfreed...)
+}
+
+func (record *allocRecord) misuse(kind PoolMisuseKind, ctrl *Control, now time.Time, stack string) PoolMisuse {
+	return PoolMisuse{
+		Kind:       kind,
+		ID:         ctrl.ID,
+		Owner:      record.owner,
+		OwnerName:  record.ownerName,
+		Age:        now.Sub(record.allocated),
+		AllocStack: record.stack,
+		FreeStack:  record.freeStack,
+		Stack:      stack,
+	}
+}
+
+func traceAlloc(ctrl, owner *Control) {
+	record := &allocRecord{
+		allocated: time.Now(),
+		stack:     string(debug.Stack()),
+		owner:     owner.ID,
+		ownerName: coroutineName(owner.currentCoroutine()),
+	}
+	poolTrace.mu.Lock()
+	poolTrace.allocs[ctrl] = record
+	poolTrace.mu.Unlock()
+}
+
+func traceFree(ctrl *Control) {
+	stack := string(debug.Stack())
+	poolTrace.mu.Lock()
+	defer poolTrace.mu.Unlock()
+	record := poolTrace.allocs[ctrl]
+	if record == nil {
+		// allocated before tracing was enabled
+		return
+	}
+	record.freed = true
+	record.freeStack = stack
+}
+
+// Records a free that was ignored, see freeCoroutine().
+func traceBadFree(ctrl *Control, kind PoolMisuseKind) {
+	stack := string(debug.Stack())
+	poolTrace.mu.Lock()
+	defer poolTrace.mu.Unlock()
+	record := poolTrace.allocs[ctrl]
+	if record == nil {
+		return
+	}
+	poolTrace.misuses = append(poolTrace.misuses, record.misuse(kind, ctrl, time.Now(), stack))
+}
+
+// Records a use after free if the control is in the pool.
+// Only the first use is recorded.
+func traceUse(ctrl *Control) {
+	poolTrace.mu.Lock()
+	defer poolTrace.mu.Unlock()
+	record := poolTrace.allocs[ctrl]
+	if record == nil || !record.freed || record.reportedUse {
+		return
+	}
+	record.reportedUse = true
+	poolTrace.misuses = append(poolTrace.misuses, record.misuse(UseAfterFree, ctrl, time.Now(), string(debug.Stack())))
+}
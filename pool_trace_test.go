@@ -0,0 +1,49 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestTracePool(t *testing.T) {
+	carrot.TracePool(true)
+	defer carrot.TracePool(false)
+
+	var rootID atomic.Int64
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		rootID.Store(ctrl.ID)
+		for i := 0; i < 3; i++ {
+			ctrl.StartAsync(func(ctrl *carrot.Control) {
+				ctrl.Delay(2)
+			})
+		}
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+		ctrl.Abyss()
+	})
+	for i := 0; i < 10; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	report := carrot.PoolTraceReport()
+	if len(report) != 1 {
+		t.Fatal("expected one unfreed coroutine, got", report)
+	}
+	if report[0].Kind != carrot.NeverFreed || report[0].Owner != rootID.Load() || report[0].AllocStack == "" {
+		t.Error("unexpected report", report[0])
+	}
+
+	script.Cancel()
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if report := carrot.PoolTraceReport(); len(report) != 0 {
+		t.Error("expected no misuse after the script ended, got", report)
+	}
+}
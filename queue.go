@@ -0,0 +1,99 @@
+package carrot
+
+import "sync"
+
+// A Queue is a bounded FIFO queue that is used to pass values
+// between coroutines. Push() yields while the queue is full,
+// and Pop() yields while the queue is empty.
+// See also Pipeline().
+//
+//	Note: Methods are all concurrent-safe.
+//
+//	Note: Push() and Pop() should be only called within
+//	a coroutine since they may yield.
+type Queue[T any] struct {
+	mu       sync.Mutex
+	items    []T
+	capacity int
+	closed   bool
+}
+
+// Creates a new empty queue that holds at most
+// capacity items. A capacity less than 1 is treated as 1.
+func NewQueue[T any](capacity int) *Queue[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Queue[T]{
+		items:    make([]T, 0, capacity),
+		capacity: capacity,
+	}
+}
+
+// Adds a value to the end of the queue. Yields while
+// the queue is full.
+// Panics with ErrCancelled if the queue is closed,
+// which ends the calling coroutine the same way
+// as a cancellation would.
+func (queue *Queue[T]) Push(ctrl *Control, value T) {
+	ctrl.YieldWhile(queue.isFull)
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	if queue.closed {
+		panic(ErrCancelled)
+	}
+	queue.items = append(queue.items, value)
+}
+
+// Removes and returns the value at the front of the queue.
+// Yields while the queue is empty. Returns false if the
+// queue is closed and no values are left.
+// Panics when cancelled.
+func (queue *Queue[T]) Pop(ctrl *Control) (T, bool) {
+	ctrl.YieldWhile(queue.isEmpty)
+
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	var value T
+	if len(queue.items) == 0 {
+		return value, false
+	}
+	value = queue.items[0]
+	queue.items = append(queue.items[:0], queue.items[1:]...)
+	return value, true
+}
+
+// Closes the queue. No more values can be pushed,
+// but the remaining values can still be popped.
+func (queue *Queue[T]) Close() {
+	queue.mu.Lock()
+	queue.closed = true
+	queue.mu.Unlock()
+}
+
+// Returns true if the queue is closed.
+func (queue *Queue[T]) IsClosed() bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return queue.closed
+}
+
+// Returns the number of values in the queue.
+func (queue *Queue[T]) Len() int {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return len(queue.items)
+}
+
+func (queue *Queue[T]) isFull() bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return !queue.closed && len(queue.items) >= queue.capacity
+}
+
+func (queue *Queue[T]) isEmpty() bool {
+	queue.mu.Lock()
+	defer queue.mu.Unlock()
+	return !queue.closed && len(queue.items) == 0
+}
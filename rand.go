@@ -0,0 +1,44 @@
+package carrot
+
+import (
+	"math/rand"
+	"time"
+)
+
+// Sets the seed of the random sources returned by ctrl.Rand()
+// for the script and its child coroutines. Only has effect when
+// used with Start() or Create(). Without a seed, the script is
+// seeded from the current time.
+func WithSeed(seed int64) Option {
+	return func(ctrl *Control) {
+		ctrl.seed = seed
+	}
+}
+
+// Returns a random source of the coroutine. The source is seeded
+// from the seed of the script (see WithSeed()) and the position of
+// the coroutine in the tree, so that the same script started with the
+// same seed draws the same numbers, as long as the child
+// coroutines are started in the same order.
+//
+//	Note: The source is not safe for concurrent use, it should
+//	only be used from within the coroutine.
+func (ctrl *Control) Rand() *rand.Rand {
+	if ctrl.rand == nil {
+		ctrl.rand = rand.New(rand.NewSource(ctrl.seed))
+	}
+	return ctrl.rand
+}
+
+// Returns the seed of a child that is the nth
+// coroutine started by its parent, using splitmix64.
+func childSeed(parent int64, n int64) int64 {
+	x := uint64(parent) + uint64(n)*0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return int64(x ^ (x >> 31))
+}
+
+func timeSeed() int64 {
+	return time.Now().UnixNano()
+}
@@ -0,0 +1,110 @@
+package carrot
+
+import (
+	"sync"
+	"time"
+)
+
+// A RateLimiter limits how often an operation is done,
+// using a token bucket that can be shared by many coroutines,
+// even across scripts. Tokens are added at a fixed rate,
+// up to a maximum burst, and each operation uses up one.
+//
+//	pathfinding := carrot.NewRateLimiter(20, 5)
+//	...
+//	if pathfinding.Wait(ctrl) {
+//		requestPath(target)
+//	}
+//
+//	Note: Waiting coroutines are not served in order,
+//	any of them may get the next token.
+type RateLimiter struct {
+	mu     sync.Mutex
+	clock  Clock
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// Creates a rate limiter that allows perSecond operations
+// per second on average, and up to burst operations at once.
+// The bucket starts full. A burst of less than one is
+// treated as one.
+func NewRateLimiter(perSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		clock:  SystemClock,
+		rate:   perSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// Sets the clock used for adding tokens.
+// Defaults to SystemClock.
+func (limiter *RateLimiter) SetClock(clock Clock) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.clock = clock
+	limiter.last = time.Time{}
+}
+
+// Changes the number of operations allowed per second.
+// Tokens added so far are kept.
+func (limiter *RateLimiter) SetRate(perSecond float64) {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.refill()
+	limiter.rate = perSecond
+}
+
+// Uses up a token if there is one, without yielding.
+// Returns false if there is none.
+func (limiter *RateLimiter) Allow() bool {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.refill()
+	if limiter.tokens < 1 {
+		return false
+	}
+	limiter.tokens--
+	return true
+}
+
+// Returns the number of tokens currently available.
+func (limiter *RateLimiter) Tokens() float64 {
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	limiter.refill()
+	return limiter.tokens
+}
+
+// Yields until a token is available, then uses it up.
+// Returns false if the coroutine was cancelled before
+// that, true otherwise.
+// Panics when cancelled, unless the cancel policy
+// is set to something other than CancelPanic.
+func (limiter *RateLimiter) Wait(ctrl *Control) bool {
+	for !limiter.Allow() {
+		if ctrl.cancelReturns() {
+			return false
+		}
+		ctrl.yield(WaitCondition)
+	}
+	return true
+}
+
+// Must be called with mu held.
+func (limiter *RateLimiter) refill() {
+	now := limiter.clock.Now()
+	if !limiter.last.IsZero() && now.After(limiter.last) {
+		limiter.tokens += now.Sub(limiter.last).Seconds() * limiter.rate
+		if limiter.tokens > limiter.burst {
+			limiter.tokens = limiter.burst
+		}
+	}
+	limiter.last = now
+}
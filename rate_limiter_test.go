@@ -0,0 +1,52 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestRateLimiter(t *testing.T) {
+	clock := &manualClock{}
+	limiter := carrot.NewRateLimiter(10, 2)
+	limiter.SetClock(clock)
+
+	var done atomic.Int32
+	manager := carrot.NewManager()
+	for i := 0; i < 5; i++ {
+		manager.Start(func(ctrl *carrot.Control) {
+			if limiter.Wait(ctrl) {
+				done.Add(1)
+			}
+		})
+	}
+	update := func() {
+		for i := 0; i < 3; i++ {
+			manager.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update()
+	if n := done.Load(); n != 2 {
+		t.Fatal("expected the burst of 2 to go through, got", n)
+	}
+	clock.Add(100 * time.Millisecond)
+	update()
+	if n := done.Load(); n != 3 {
+		t.Fatal("expected one more after 100ms, got", n)
+	}
+	clock.Add(time.Second)
+	update()
+	if n := done.Load(); n != 5 {
+		t.Fatal("expected all to go through, got", n)
+	}
+	if tokens := limiter.Tokens(); tokens != 0 {
+		t.Error("tokens should be capped at the burst, got", tokens)
+	}
+	if limiter.Allow() {
+		t.Error("no tokens should be left")
+	}
+}
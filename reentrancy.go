@@ -0,0 +1,117 @@
+package carrot
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How long a resume or an update waits before checking
+// whether the caller is a coroutine of the script, which
+// would deadlock. The check is delayed since taking the
+// goroutine ID is slow, so it's only done once the frame is
+// already stalled, instead of on every resume.
+const reentrancyCheckDelay = 10 * time.Millisecond
+
+// Returns the ID of the current goroutine.
+// Only used for diagnostics, since it's slow.
+func goroutineID() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	// the stack starts with "goroutine 123 [running]:"
+	s := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(s, ' '); i >= 0 {
+		s = s[:i]
+	}
+	id, _ := strconv.ParseInt(string(s), 10, 64)
+	return id
+}
+
+// Resumes the coroutine, checking for reentrancy if
+// it doesn't yield within reentrancyCheckDelay.
+func (ctrl *Control) yieldLeft() {
+	if ctrl.kanata.YieldLeftUntil(time.Now().Add(reentrancyCheckDelay)) {
+		return
+	}
+	ctrl.checkReentrancy()
+	ctrl.kanata.YieldLeft()
+}
+
+// Locks updateMu, checking for reentrancy if it's
+// not unlocked within reentrancyCheckDelay.
+func (script *Script) lockUpdate() {
+	if script.updateMu.TryLock() {
+		return
+	}
+	deadline := time.Now().Add(reentrancyCheckDelay)
+	locked := false
+	spinUntil(func() bool {
+		locked = script.updateMu.TryLock()
+		return locked || !time.Now().Before(deadline)
+	})
+	if locked {
+		return
+	}
+	script.baseControl.checkTreeReentrancy()
+	script.updateMu.Lock()
+}
+
+// Panics if the coroutine itself is the one resuming it,
+// for instance when a coroutine calls Update() on its own script,
+// which would otherwise deadlock.
+func (ctrl *Control) checkReentrancy() {
+	// a coroutine that is waiting on a yield can't be the caller
+	if !ctrl.active.Load() || goroutineID() != ctrl.gid.Load() {
+		return
+	}
+	ctrl.panicReentrancy()
+}
+
+// Same as checkReentrancy(), but checks the child
+// coroutines as well. Used when Update() has to wait
+// for another updater, since a coroutine of the script
+// waiting there would never be yielded to.
+func (ctrl *Control) checkTreeReentrancy() {
+	gid := goroutineID()
+	var check func(c *Control)
+	check = func(c *Control) {
+		if c.active.Load() && c.gid.Load() == gid {
+			c.panicReentrancy()
+		}
+		c.subControlsMu.RLock()
+		subs := append([]*Control(nil), c.subControls...)
+		c.subControlsMu.RUnlock()
+		for _, sub := range subs {
+			check(sub)
+		}
+	}
+	check(ctrl)
+}
+
+func (ctrl *Control) panicReentrancy() {
+	panic(fmt.Sprintf(
+		"carrot: %v called Update() on its own script at %v, which would deadlock. "+
+			"Update() must only be called outside of the coroutines of the script",
+		ctrl, callSite(),
+	))
+}
+
+// Returns the location of the first caller
+// outside of the package.
+func callSite() string {
+	var pcs [32]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "github.com/nvlled/carrot.") {
+			return fmt.Sprintf("%v:%v", frame.File, frame.Line)
+		}
+		if !more {
+			return "unknown location"
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package carrot
+
+import (
+	"sort"
+	"sync"
+)
+
+var registry = struct {
+	mu      sync.Mutex
+	scripts map[string]*Script
+}{scripts: map[string]*Script{}}
+
+// Names the script, so that it can be found with Lookup() or
+// manager.Lookup(), for instance by console commands or a debug UI.
+// The script is removed from the registry once it's done or closed,
+// and added back if restarted. When scripts share a name, Lookup()
+// returns the most recently started one.
+// Only has effect when used with Start() or Create().
+func WithName(name string) Option {
+	return func(ctrl *Control) {
+		ctrl.scriptName = name
+	}
+}
+
+// Returns the name given with WithName().
+func (script *Script) Name() string {
+	return script.baseControl.scriptName
+}
+
+// Returns the running script with the given name,
+// or nil if there is none. See WithName().
+//
+//	if boss := carrot.Lookup("boss"); boss != nil {
+//		boss.Cancel()
+//	}
+func Lookup(name string) *Script {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	return registry.scripts[name]
+}
+
+// Returns the names of the scripts in
+// the registry, in sorted order.
+func RegisteredNames() []string {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	names := make([]string, 0, len(registry.scripts))
+	for name := range registry.scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (script *Script) register() {
+	script.trackRunning()
+	name := script.Name()
+	if name == "" {
+		return
+	}
+	registry.mu.Lock()
+	registry.scripts[name] = script
+	registry.mu.Unlock()
+}
+
+func (script *Script) unregister() {
+	script.untrackRunning()
+	name := script.Name()
+	if name == "" {
+		return
+	}
+	registry.mu.Lock()
+	// the name may have been taken by a newer script
+	if registry.scripts[name] == script {
+		delete(registry.scripts, name)
+	}
+	registry.mu.Unlock()
+}
@@ -0,0 +1,22 @@
+package carrot
+
+import "time"
+
+// A FrameReport describes what happened during
+// one update of a script. See script.UpdateReport().
+type FrameReport struct {
+	// Number of coroutines that were resumed,
+	// including the child coroutines.
+	Resumed int
+
+	// Number of child coroutines that finished
+	// and were removed during the update.
+	Completed int
+
+	// Number of coroutines that will be restarted
+	// on the next update.
+	PendingRestarts int
+
+	// Time spent on the update.
+	Duration time.Duration
+}
@@ -0,0 +1,104 @@
+package carrot
+
+import "fmt"
+
+// A RestartLoop is a coroutine that restarted too many times
+// within a few frames, see WithRestartGuard().
+type RestartLoop struct {
+	ID int64
+	// Name of the coroutine function it restarted with.
+	Name string
+	// Number of restarts within the frames.
+	Restarts int
+	Frames   int
+}
+
+func (loop RestartLoop) Error() string {
+	return fmt.Sprintf("coroutine %v (%v) restarted %v times within %v frames",
+		loop.ID, loop.Name, loop.Restarts, loop.Frames)
+}
+
+// Returns the number of times the coroutine was restarted,
+// which includes transitions. The first start is not counted.
+// Unlike Stats(), counted even when the instrumentation is
+// disabled. Can be called from any thread.
+func (ctrl *Control) Restarts() int64 {
+	if n := ctrl.starts.Load() - 1; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// Returns the number of restarts of the script's
+// main coroutine. See ctrl.Restarts().
+func (script *Script) Restarts() int64 {
+	return script.baseControl.Restarts()
+}
+
+// Flags a coroutine of the script that restarts more than
+// maxRestarts times within the given number of frames, which
+// is almost always a Transition() or Restart() feedback loop,
+// for instance two states that transition to each other
+// right away. The loop is passed to fn, or if fn is nil,
+// Update() panics with it once the whole update is done,
+// so the script can still be updated or cancelled after
+// recovering. Only has effect when used with Start() or Create().
+//
+//	carrot.Start(enemyAI, carrot.WithRestartGuard(10, 60, func(loop carrot.RestartLoop) {
+//		log.Println(loop)
+//	}))
+func WithRestartGuard(maxRestarts, frames int, fn func(RestartLoop)) Option {
+	return func(ctrl *Control) {
+		ctrl.restartLimit = maxRestarts
+		ctrl.restartWindow = frames
+		ctrl.restartFn = fn
+	}
+}
+
+// Called on the update that restarts the coroutine,
+// keeps the frames of the recent restarts and
+// reports a loop if there are too many of them.
+func (ctrl *Control) checkRestartLoop() {
+	root := ctrl.root()
+	if root.restartLimit <= 0 || ctrl.starts.Load() <= 1 {
+		return
+	}
+	frame := root.frame.Load()
+	ctrl.restartFrames = append(ctrl.restartFrames, frame)
+	if len(ctrl.restartFrames) <= root.restartLimit {
+		return
+	}
+	oldest := ctrl.restartFrames[0]
+	ctrl.restartFrames = append(ctrl.restartFrames[:0], ctrl.restartFrames[1:]...)
+	if frame-oldest >= int64(root.restartWindow) {
+		return
+	}
+	// starts over, so that the same loop
+	// is reported once per window
+	ctrl.restartFrames = ctrl.restartFrames[:0]
+	loop := RestartLoop{
+		ID:       ctrl.ID,
+		Name:     coroutineName(ctrl.coroutine),
+		Restarts: root.restartLimit + 1,
+		Frames:   root.restartWindow,
+	}
+	ctrl.Logf("%v", loop)
+	if root.restartFn == nil {
+		// panicking here would leave the coroutine restarted
+		// but never resumed, see panicRestartLoop()
+		if root.restartLoop == nil {
+			root.restartLoop = &loop
+		}
+		return
+	}
+	root.restartFn(loop)
+}
+
+// Called by the root at the end of the update,
+// panics with the loop found during the update.
+func (ctrl *Control) panicRestartLoop() {
+	if loop := ctrl.restartLoop; loop != nil {
+		ctrl.restartLoop = nil
+		panic(*loop)
+	}
+}
@@ -0,0 +1,104 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestWithRestartGuard(t *testing.T) {
+	var loops []carrot.RestartLoop
+	var ping, pong carrot.Coroutine
+	ping = func(ctrl *carrot.Control) {
+		ctrl.Transition(pong)
+	}
+	pong = func(ctrl *carrot.Control) {
+		ctrl.Transition(ping)
+	}
+	script := carrot.Start(ping, carrot.WithRestartGuard(5, 20, func(loop carrot.RestartLoop) {
+		loops = append(loops, loop)
+	}))
+
+	for i := 0; i < 30; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if len(loops) == 0 {
+		t.Fatal("restart loop should be reported")
+	}
+	if loops[0].Restarts != 6 || loops[0].Frames != 20 {
+		t.Error("wrong loop", loops[0])
+	}
+	if restarts := script.Restarts(); restarts < 6 {
+		t.Error("wrong number of restarts", restarts)
+	}
+	script.Cancel()
+	script.Update()
+}
+
+func TestWithRestartGuardPanics(t *testing.T) {
+	var resumed atomic.Int32
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for {
+			resumed.Add(1)
+			ctrl.Yield()
+		}
+	}, carrot.WithRestartGuard(2, 10, nil))
+
+	update := func() (loop any) {
+		defer func() { loop = recover() }()
+		script.Update()
+		time.Sleep(updateDelay)
+		return nil
+	}
+	var loop any
+	for i := 0; i < 10 && loop == nil; i++ {
+		script.Restart()
+		loop = update()
+	}
+	if _, ok := loop.(carrot.RestartLoop); !ok {
+		t.Fatal("Update() should panic with a RestartLoop", loop)
+	}
+
+	// the restarted coroutine was still resumed
+	// before the panic, and keeps running
+	before := resumed.Load()
+	for i := 0; i < 3; i++ {
+		if loop := update(); loop != nil {
+			t.Fatal("the loop should only be reported once", loop)
+		}
+	}
+	if script.IsDone() || resumed.Load() <= before {
+		t.Error("script should keep running after the panic", resumed.Load(), before)
+	}
+	script.Cancel()
+	update()
+	if !script.IsDone() {
+		t.Error("script should be cancelled")
+	}
+}
+
+func TestRestartGuardSlowRestarts(t *testing.T) {
+	var loops int
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Delay(5)
+	}, carrot.WithRestartGuard(2, 10, func(carrot.RestartLoop) {
+		loops++
+	}))
+
+	for i := 0; i < 60; i++ {
+		if script.IsDone() {
+			script.Restart()
+		}
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if loops != 0 {
+		t.Error("restarts were not in a loop", loops)
+	}
+	if script.Restarts() == 0 {
+		t.Error("script should have restarted")
+	}
+}
@@ -0,0 +1,126 @@
+package carrot
+
+import (
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+// A Scope owns the scripts started with it, which are
+// cancelled together by Close(), for instance all
+// scripts of a scene or a level. Scopes can be nested,
+// closing a scope also closes its inner scopes.
+//
+//	Note: Methods are all concurrent-safe.
+type Scope struct {
+	group *Group
+
+	mu     sync.Mutex
+	inner  []*Scope
+	closed bool
+}
+
+// Creates a new open scope.
+func NewScope() *Scope {
+	return &Scope{group: NewGroup()}
+}
+
+// Creates a scope inside this one, which is closed
+// when this one is closed, and updated with it.
+// If this scope is closed, so is the new one.
+func (scope *Scope) NewScope() *Scope {
+	inner := NewScope()
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	if scope.closed {
+		inner.closed = true
+		return inner
+	}
+	scope.inner = append(scope.inner, inner)
+	return inner
+}
+
+// Starts a script that belongs to the scope, see Start().
+// If the scope is closed, the script is cancelled
+// before it starts.
+func (scope *Scope) Start(coroutine Coroutine, options ...Option) *Script {
+	script := Start(coroutine, options...)
+	// until its goroutine runs, the script looks done, and would
+	// be removed by Update() if it's cancelled before it starts
+	<-script.baseControl.ready
+
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	if scope.closed {
+		script.Cancel()
+	}
+	scope.group.Add(script)
+	return script
+}
+
+// Calls Update() on the scripts of the scope and of its
+// inner scopes, then removes the scripts that are done
+// and the closed inner scopes that have no scripts left.
+// Not needed if the scripts are updated elsewhere,
+// for instance by a Manager.
+func (scope *Scope) Update() {
+	scope.group.Update()
+
+	scope.mu.Lock()
+	inner := slices.Clone(scope.inner)
+	scope.mu.Unlock()
+	for _, s := range inner {
+		s.Update()
+	}
+
+	scope.mu.Lock()
+	open := scope.inner[:0]
+	for _, s := range scope.inner {
+		if !s.IsClosed() || s.Len() > 0 {
+			open = append(open, s)
+		}
+	}
+	scope.inner = open
+	scope.mu.Unlock()
+}
+
+// Cancels all scripts of the scope, and closes the inner
+// scopes. Scripts started afterwards are cancelled right
+// away. As with script.Cancel(), the cancellation takes
+// effect on the next Update() of the scripts.
+func (scope *Scope) Close() {
+	scope.mu.Lock()
+	if scope.closed {
+		scope.mu.Unlock()
+		return
+	}
+	scope.closed = true
+	inner := slices.Clone(scope.inner)
+	scope.mu.Unlock()
+
+	scope.group.CancelAll()
+	for _, s := range inner {
+		s.Close()
+	}
+}
+
+// Returns true if Close() was called on
+// the scope or on one of its outer scopes.
+func (scope *Scope) IsClosed() bool {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	return scope.closed
+}
+
+// Returns the number of scripts in the scope and its
+// inner scopes that have not been removed by Update() yet.
+func (scope *Scope) Len() int {
+	scope.mu.Lock()
+	inner := slices.Clone(scope.inner)
+	scope.mu.Unlock()
+	n := scope.group.Len()
+	for _, s := range inner {
+		n += s.Len()
+	}
+	return n
+}
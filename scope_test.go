@@ -0,0 +1,56 @@
+package carrot_test
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestScope(t *testing.T) {
+	var count atomic.Int32
+	loop := func(ctrl *carrot.Control) {
+		for {
+			count.Add(1)
+			ctrl.Yield()
+		}
+	}
+
+	level := carrot.NewScope()
+	room := level.NewScope()
+	a := level.Start(loop)
+	b := room.Start(loop)
+	c := room.Start(func(ctrl *carrot.Control) {})
+
+	for i := 0; i < 3; i++ {
+		level.Update()
+		time.Sleep(updateDelay)
+	}
+	if count.Load() == 0 {
+		t.Error("scripts should be updated with the scope")
+	}
+	if !c.IsDone() || level.Len() != 2 {
+		t.Error("done scripts should be removed", level.Len())
+	}
+
+	level.Close()
+	if !room.IsClosed() {
+		t.Error("inner scope should be closed")
+	}
+	late := room.Start(loop)
+	for i := 0; i < 2; i++ {
+		level.Update()
+		time.Sleep(updateDelay)
+	}
+	if !a.IsDone() || !b.IsDone() || !late.IsDone() {
+		t.Error("scripts should be done", a.IsDone(), b.IsDone(), late.IsDone())
+	}
+	if level.Len() != 0 {
+		t.Error("scope should be empty", level.Len())
+	}
+	if !errors.Is(late.Err(), carrot.ErrCancelled) {
+		t.Error("late script should be cancelled", late.Err())
+	}
+}
@@ -1,40 +1,368 @@
 package carrot
 
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
 // A Script is an instance of related coroutines running.
 type Script struct {
 	baseControl *Control
+
+	// serializes updates from multiple goroutines
+	updateMu sync.Mutex
+
+	hooksMu   sync.Mutex
+	onStart   []func()
+	onRestart []func()
+	onCancel  []func()
+	onDone    []func()
+
+	// see Link()
+	linksMu sync.Mutex
+	links   []*Script
+
+	// only accessed on Update()
+	started      bool
+	doneNotified bool
+
+	// nil if leaks are not tracked, see TrackLeaks()
+	leak *leakEntry
+
+	// closed when the script is done, see Done()
+	done       chan struct{}
+	doneClosed bool
+	doneErr    error
+	closing    bool
+	closed     bool
 }
 
 // Creates a new coroutine script. Coroutine will only start
 // on the first call to Update().
-func Start(coroutine Coroutine) *Script {
+func Start(coroutine Coroutine, options ...Option) *Script {
 	script := &Script{
 		baseControl: NewControl(),
 	}
 	script.baseControl.initialize(coroutine)
+	for _, opt := range options {
+		opt(script.baseControl)
+	}
+	script.trackLeak()
+	script.register()
 
 	return script
 }
 
 // Creates an inactive coroutine script.
 // To be used with script.Transition(otherCoroutine).
-func Create() *Script {
+func Create(options ...Option) *Script {
 	script := &Script{
 		baseControl: NewControl(),
 	}
 	script.baseControl.initialize(nil)
+	for _, opt := range options {
+		opt(script.baseControl)
+	}
+	script.trackLeak()
+	script.register()
 
 	return script
 }
 
+// Cancels the coroutine and updates the script until
+// it's done, see manager.Release().
+func (script *Script) stop() {
+	script.lockUpdate()
+	defer script.updateMu.Unlock()
+	script.finish()
+	script.closeDone()
+	script.unregister()
+	if script.leak != nil {
+		script.untrackLeak()
+		script.leak = nil
+	}
+}
+
+// Cancels the coroutine and updates the script until it's
+// done, even if it's paused. Must be called while holding
+// updateMu.
+func (script *Script) finish() {
+	ctrl := script.baseControl
+	script.closing = true
+	ctrl.Resume()
+	if !ctrl.IsDone() {
+		ctrl.Cancel()
+	}
+	for !ctrl.IsDone() && ctrl.coroutine != nil {
+		script.update(nil)
+	}
+	script.closing = false
+}
+
+// Resets the script to run the coroutine
+// as if it was new, see manager.Acquire().
+func (script *Script) reuse(coroutine Coroutine, options []Option) {
+	script.hooksMu.Lock()
+	script.onStart = nil
+	script.onRestart = nil
+	script.onCancel = nil
+	script.onDone = nil
+	script.hooksMu.Unlock()
+	script.unregister()
+	for _, other := range script.Links() {
+		Unlink(script, other)
+	}
+	script.started = false
+	script.doneNotified = false
+	script.resetDone()
+
+	script.baseControl.initialize(coroutine)
+	for _, opt := range options {
+		opt(script.baseControl)
+	}
+	script.trackLeak()
+	script.register()
+}
+
 // Update causes blocking calls to Yield(), Delay(), DelayAsync() and RunOnUpdate()
 // to advance one step. Update is normally called repeatedly inside a loop,
 // for instance a game loop, or any application loop in the main thread.
 //
 //	Note: Update is blocking, and will not return until
 //	a Yield() is called inside the coroutine.
+//
+//	Note: Update must not be called by the coroutines of the
+//	script itself, since it would deadlock. It panics instead.
+//
+//	Note: Update can be called from multiple goroutines, for
+//	instance from a job system. The calls are serialized, each
+//	one doing a whole update of the script, in the order the
+//	callers get to it. No update is skipped or merged.
 func (script *Script) Update() {
-	script.baseControl.update()
+	script.lockUpdate()
+	defer script.updateMu.Unlock()
+	script.baseControl.alpha.Store(0)
+	script.update(nil)
+}
+
+// Same as Update(), but also sets the interpolation
+// fraction returned by ctrl.Alpha() for this update.
+// Used with fixed timestep loops, where alpha is the
+// leftover time divided by the timestep.
+// The alpha is clamped between 0 and 1.
+//
+//	for lag >= step { script.Update(); lag -= step }
+//	script.UpdateWithAlpha(float64(lag) / float64(step))
+func (script *Script) UpdateWithAlpha(alpha float64) {
+	alpha = math.Max(0, math.Min(1, alpha))
+	script.lockUpdate()
+	defer script.updateMu.Unlock()
+	script.baseControl.alpha.Store(math.Float64bits(alpha))
+	script.update(nil)
+}
+
+// Same as Update(), but also returns a report of
+// what happened during the update. Useful for debug overlays
+// and for tracking the cost of the script.
+func (script *Script) UpdateReport() FrameReport {
+	var report FrameReport
+	script.lockUpdate()
+	defer script.updateMu.Unlock()
+	startTime := time.Now()
+	script.update(&report)
+	report.Duration = time.Since(startTime)
+	return report
+}
+
+// Adds a middleware that is called on every yield point
+// of the coroutines in the script, such as Yield(), Sleep()
+// or YieldUntil(). The middleware must call next to
+// actually yield. Code before calling next runs before the
+// coroutine is suspended, and code after it runs once the coroutine
+// is resumed. Middlewares added first are called first.
+//
+//	script.Use(func(next carrot.YieldFunc) carrot.YieldFunc {
+//		return func(ctrl *carrot.Control, reason carrot.WaitReason) {
+//			ctrl.Logf("waiting for %v", reason)
+//			next(ctrl, reason)
+//		}
+//	})
+//
+//	Note: next panics with ErrCancelled when the coroutine
+//	is cancelled, use defer if the middleware needs to clean up.
+func (script *Script) Use(middleware func(next YieldFunc) YieldFunc) {
+	ctrl := script.baseControl
+	ctrl.middlewaresMu.Lock()
+	defer ctrl.middlewaresMu.Unlock()
+	ctrl.middlewares = append(ctrl.middlewares, middleware)
+
+	var fn YieldFunc = baseYield
+	for i := len(ctrl.middlewares) - 1; i >= 0; i-- {
+		fn = ctrl.middlewares[i](fn)
+	}
+	ctrl.yieldFn.Store(&fn)
+}
+
+// Freezes the coroutine with the given ID at its next yield.
+// The coroutine will not be resumed on Update() until
+// StepOne() or Continue() is called, while the rest of the
+// script keeps running. Only one coroutine can be frozen
+// at a time. Mainly used for debugging.
+//
+//	Note: Cancel() and Restart() still take effect
+//	on a frozen coroutine.
+func (script *Script) Break(controlID int64) {
+	ctrl := script.baseControl
+	ctrl.breakSteps.Store(0)
+	ctrl.breakID.Store(controlID)
+}
+
+// Resumes the coroutine frozen with Break() on the next Update(),
+// then freezes it again at its next yield.
+func (script *Script) StepOne() {
+	script.baseControl.breakSteps.Add(1)
+}
+
+// Unfreezes the coroutine frozen with Break().
+func (script *Script) Continue() {
+	script.baseControl.breakID.Store(0)
+}
+
+// Runs Update() repeatedly as if the duration has passed,
+// advancing the time of the script by frameStep before
+// each update. Useful for simulating scripts offline,
+// for instance in headless tests or for catching up on a server.
+// A frameStep of zero or less advances the whole
+// duration in one update.
+// See also WithClock().
+func (script *Script) Advance(duration, frameStep time.Duration) {
+	if frameStep <= 0 {
+		frameStep = duration
+	}
+	for duration > 0 {
+		step := frameStep
+		if step > duration {
+			step = duration
+		}
+		script.baseControl.clockOffset.Add(int64(step))
+		script.Update()
+		duration -= step
+	}
+}
+
+func (script *Script) update(report *FrameReport) {
+	ctrl := script.baseControl
+	if script.closed {
+		return
+	}
+	if script.leak != nil {
+		script.leak.lastUpdate.Store(time.Now().UnixNano())
+	}
+	if allPaused.Load() && !script.closing {
+		ctrl.freezeClock(freezeGlobal)
+		return
+	}
+	ctrl.unfreezeClock(freezeGlobal)
+	if ctrl.IsPaused() {
+		return
+	}
+	if ctrl.historyLog != nil || ctrl.restartLimit > 0 {
+		ctrl.frame.Add(1)
+	}
+	ctrl.deliverInputs()
+
+	ctrl.runCalls()
+
+	cancelling := ctrl.isCancelling() && ctrl.IsRunning()
+	starting := !ctrl.isCancelling() && ctrl.isRestarting() && ctrl.hasCoroutine()
+
+	ctrl.update(report)
+
+	if cancelling {
+		script.notify(script.onCancel)
+		switch ctrl.Cause() {
+		case CauseRestart, CauseTransition:
+		default:
+			script.cancelLinks()
+		}
+	}
+	if starting {
+		if script.doneNotified {
+			if script.leak != nil {
+				script.retrackLeak()
+			}
+			script.register()
+		}
+		script.doneNotified = false
+		if script.started {
+			script.notify(script.onRestart)
+		} else {
+			script.started = true
+			script.notify(script.onStart)
+		}
+	}
+	if script.started && !script.doneNotified && ctrl.IsDone() {
+		script.doneNotified = true
+		script.closeDone()
+		script.unregister()
+		script.notify(script.onDone)
+		if script.leak != nil {
+			script.untrackLeak()
+		}
+	}
+	ctrl.panicRestartLoop()
+}
+
+// Registers a function that is called when the coroutine
+// is started for the first time.
+// Callbacks are called on Update(), after the coroutine
+// is resumed.
+func (script *Script) OnStart(fn func()) {
+	script.hooksMu.Lock()
+	script.onStart = append(script.onStart, fn)
+	script.hooksMu.Unlock()
+}
+
+// Registers a function that is called when the coroutine
+// is restarted, including restarts due to Transition().
+// Callbacks are called on Update(), after the coroutine
+// is resumed.
+func (script *Script) OnRestart(fn func()) {
+	script.hooksMu.Lock()
+	script.onRestart = append(script.onRestart, fn)
+	script.hooksMu.Unlock()
+}
+
+// Registers a function that is called when a running
+// coroutine is cancelled.
+// Callbacks are called on Update(), after the cancellation
+// is applied.
+func (script *Script) OnCancel(fn func()) {
+	script.hooksMu.Lock()
+	script.onCancel = append(script.onCancel, fn)
+	script.hooksMu.Unlock()
+}
+
+// Registers a function that is called when the script is done.
+// If the script is restarted, the callback will be
+// called again once it's done.
+// Callbacks are called on Update().
+func (script *Script) OnDone(fn func()) {
+	script.hooksMu.Lock()
+	script.onDone = append(script.onDone, fn)
+	script.hooksMu.Unlock()
+}
+
+func (script *Script) notify(hooks []func()) {
+	script.hooksMu.Lock()
+	hooks = slices.Clone(hooks)
+	script.hooksMu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
 }
 
 // Changes the current coroutine function to a new one. The old
@@ -45,6 +373,13 @@ func (script *Script) Transition(newCoroutine Coroutine) {
 	script.baseControl.Transition(newCoroutine)
 }
 
+// Changes back to the coroutine before the last Transition().
+// Returns false if there is no previous coroutine.
+// See ctrl.TransitionBack().
+func (script *Script) TransitionBack() bool {
+	return script.baseControl.TransitionBack()
+}
+
 // Restarts the coroutine. If the coroutine is still running,
 // it is Cancel()'ed first, then the coroutine
 // is started again.
@@ -54,6 +389,45 @@ func (script *Script) Restart() {
 	script.baseControl.Restart()
 }
 
+// Same as Restart(), but keeps the child coroutines
+// started with the KeepOnRestart() option running.
+// See ctrl.RestartKeepChildren().
+func (script *Script) RestartKeepChildren() {
+	script.baseControl.RestartKeepChildren()
+}
+
+// Pauses the script. The coroutine and all its child coroutines
+// will not be resumed on Update() until Resume() is called.
+// The clock of the script is also stopped, so pending
+// Sleep() calls continue where they left off.
+func (script *Script) Pause() {
+	script.baseControl.freezeClock(freezeScript)
+	script.baseControl.Pause()
+}
+
+// Resumes a paused script.
+func (script *Script) Resume() {
+	script.baseControl.Resume()
+	script.baseControl.unfreezeClock(freezeScript)
+}
+
+// Causes all pending Sleep() and Delay() calls in the script
+// to return on the next Update(). See ctrl.FastForward().
+func (script *Script) FastForward() {
+	script.baseControl.FastForward()
+}
+
+// Wakes up the coroutine if it's parked with
+// ctrl.AbyssUntilWoken(). See ctrl.Wake().
+func (script *Script) Wake() {
+	script.baseControl.Wake()
+}
+
+// Returns true if the script is paused.
+func (script *Script) IsPaused() bool {
+	return script.baseControl.IsPaused()
+}
+
 // Cancels the coroutine. All coroutines started inside
 // the script will be cancelled.
 //
@@ -68,7 +442,68 @@ func (script *Script) IsDone() bool {
 	return script.baseControl.IsDone()
 }
 
-// Use for debugging. Call SetLogging(true) to enable.
+// Returns true if the next Update() won't make any progress,
+// meaning all coroutines of the script are done, paused,
+// or are sleeping until some time after now.
+// Useful for skipping the update of idle scripts.
+// See also ctrl.WaitReason().
+func (script *Script) IsIdle() bool {
+	ctrl := script.baseControl
+	return ctrl.isIdle(ctrl.Now())
+}
+
+// Returns the progress set with ctrl.SetProgress()
+// on the base coroutine of the script.
+func (script *Script) Progress() float64 {
+	return script.baseControl.Progress()
+}
+
+// Returns the status set with ctrl.SetStatus()
+// on the base coroutine of the script.
+func (script *Script) Status() string {
+	return script.baseControl.Status()
+}
+
+// Sets whether the script is a background script,
+// which a Manager updates less often. See Background().
+func (script *Script) SetBackground(background bool) {
+	script.baseControl.background.Store(background)
+}
+
+// Sets whether the coroutines of the script are resumed
+// one at a time, see Serialized().
+func (script *Script) SetSerialized(serialized bool) {
+	script.baseControl.serialized.Store(serialized)
+}
+
+// Returns true if the script is a background script.
+func (script *Script) IsBackground() bool {
+	return script.baseControl.background.Load()
+}
+
+// Returns the priority of the script, see WithPriority().
+func (script *Script) Priority() int {
+	return int(script.baseControl.priority.Load())
+}
+
+// Returns true if the script has the given tag.
+// See WithTags().
+func (script *Script) HasTag(tag string) bool {
+	return script.baseControl.HasTag(tag)
+}
+
+// Returns the checkpoints reached by the coroutines
+// of the script, in the order they were reached.
+// See ctrl.Checkpoint().
+func (script *Script) Checkpoints() []string {
+	ctrl := script.baseControl
+	ctrl.checkpointsMu.Lock()
+	defer ctrl.checkpointsMu.Unlock()
+	return slices.Clone(ctrl.checkpoints)
+}
+
+// Use for debugging. Call SetLogging(true) to enable,
+// or start the script with WithLogger().
 func (script *Script) Logf(format string, args ...any) {
-	logFn(script.baseControl, format, args...)
+	script.baseControl.Logf(format, args...)
 }
@@ -0,0 +1,123 @@
+package carrot
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// The error wrapped by script.Err() when
+// the script finished normally.
+var ErrDone = errors.New("script is done")
+
+// Returned by script.Err(), matches both context.Canceled
+// and the error the script ended with.
+type doneError struct{ err error }
+
+func (e doneError) Error() string        { return context.Canceled.Error() + ": " + e.err.Error() }
+func (e doneError) Unwrap() error        { return e.err }
+func (e doneError) Is(target error) bool { return target == context.Canceled }
+
+var (
+	_ io.Closer       = (*Script)(nil)
+	_ context.Context = (*Script)(nil)
+)
+
+// Cancels the script and updates it until it's done,
+// then releases the goroutine of the script. The script can't
+// be used after Close(), Update() does nothing. Always returns nil.
+// Useful with defer, or with utilities that accept an io.Closer.
+//
+//	Note: Close() blocks until all coroutines of the script end,
+//	and must not be called from the coroutines of the script.
+func (script *Script) Close() error {
+	script.lockUpdate()
+	defer script.updateMu.Unlock()
+	if script.closed {
+		return nil
+	}
+	script.finish()
+	script.closed = true
+	close(script.baseControl.quit)
+
+	script.hooksMu.Lock()
+	script.closeDoneLocked()
+	script.hooksMu.Unlock()
+	script.unregister()
+	if script.leak != nil {
+		script.untrackLeak()
+	}
+	return nil
+}
+
+// Scripts have no deadline, always returns false.
+// Implements context.Context.
+func (script *Script) Deadline() (deadline time.Time, ok bool) {
+	return time.Time{}, false
+}
+
+// Returns a channel that is closed once the script is done,
+// on Update() or when Done() is called. The channel stays closed
+// if the script is restarted afterwards, only a script acquired
+// again from a manager gets a new one. Implements context.Context.
+func (script *Script) Done() <-chan struct{} {
+	script.hooksMu.Lock()
+	defer script.hooksMu.Unlock()
+	if script.baseControl.IsDone() {
+		script.closeDoneLocked()
+	}
+	if script.done == nil {
+		script.done = make(chan struct{})
+	}
+	return script.done
+}
+
+// Returns nil if Done() is not closed yet. Otherwise, returns
+// context.Canceled wrapping the error the script ended with,
+// such as ErrCancelled, or ErrDone if it finished normally.
+// Use errors.Is() to check either. Implements context.Context.
+func (script *Script) Err() error {
+	script.Done()
+	script.hooksMu.Lock()
+	defer script.hooksMu.Unlock()
+	return script.doneErr
+}
+
+// Returns the value set with ctrl.SetValue() on the base
+// coroutine of the script. Implements context.Context.
+func (script *Script) Value(key any) any {
+	return script.baseControl.Value(key)
+}
+
+func (script *Script) closeDone() {
+	script.hooksMu.Lock()
+	defer script.hooksMu.Unlock()
+	script.closeDoneLocked()
+}
+
+func (script *Script) closeDoneLocked() {
+	if script.doneClosed {
+		return
+	}
+	if script.done == nil {
+		script.done = make(chan struct{})
+	}
+	err := script.baseControl.Err()
+	if err == nil {
+		err = ErrDone
+	}
+	script.doneErr = doneError{err}
+	close(script.done)
+	script.doneClosed = true
+}
+
+func (script *Script) resetDone() {
+	script.hooksMu.Lock()
+	defer script.hooksMu.Unlock()
+	if script.doneClosed {
+		script.done = nil
+		script.doneClosed = false
+		script.doneErr = nil
+	}
+}
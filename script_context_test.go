@@ -0,0 +1,108 @@
+package carrot_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestScriptClose(t *testing.T) {
+	var cleanedUp atomic.Int32
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		defer cleanedUp.Add(1)
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			defer cleanedUp.Add(1)
+			ctrl.Abyss()
+		})
+		ctrl.Abyss()
+	})
+	var closer io.Closer = script
+	var cancelled bool
+	script.OnCancel(func() { cancelled = true })
+
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	done := script.Done()
+	if script.Err() != nil {
+		t.Error("running script should have no error")
+	}
+
+	closer.Close()
+	if n := cleanedUp.Load(); n != 2 {
+		t.Error("all coroutines should be cleaned up", n)
+	}
+	if !cancelled {
+		t.Error("OnCancel should be called")
+	}
+	select {
+	case <-done:
+	default:
+		t.Error("Done() should be closed")
+	}
+	if !errors.Is(script.Err(), carrot.ErrCancelled) || !errors.Is(script.Err(), context.Canceled) {
+		t.Error("wrong error", script.Err())
+	}
+	script.Update()
+	closer.Close()
+}
+
+func TestScriptContext(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.SetValue("level", 3)
+		ctrl.Yield()
+	})
+	var ctx context.Context = script
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	<-ctx.Done()
+	if !errors.Is(ctx.Err(), carrot.ErrDone) || !errors.Is(ctx.Err(), context.Canceled) {
+		t.Error("wrong error", ctx.Err())
+	}
+	if ctx.Value("level") != 3 {
+		t.Error("wrong value", ctx.Value("level"))
+	}
+
+	// a context derived from the script is cancelled with it
+	child, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if child.Err() == nil {
+		t.Error("derived context should be cancelled")
+	}
+	script.Close()
+}
+
+func TestScriptDoneAfterRestart(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Yield()
+	})
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	done := script.Done()
+	err := script.Err()
+
+	script.Restart()
+	script.Update()
+	if script.Done() != done {
+		t.Error("Done() should return the same channel after a restart")
+	}
+	if script.Err() != err {
+		t.Error("Err() should not change after a restart", err, script.Err())
+	}
+	script.Close()
+}
+
+func TestScriptCloseNotStarted(t *testing.T) {
+	carrot.Create().Close()
+	carrot.Start(func(ctrl *carrot.Control) { ctrl.Abyss() }).Close()
+}
@@ -0,0 +1,64 @@
+package carrot
+
+import "sync"
+
+// The lock of a shard, shared by the scripts
+// of the shard that are in a manager.
+type managerShard struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// Returns the shard key set with WithShard(),
+// or an empty string if there is none.
+func (script *Script) Shard() string {
+	return script.baseControl.shard
+}
+
+// Returns the lock of the shard, must be called while
+// holding the manager's mu. Shards are removed once their
+// last script is removed, so the map doesn't grow with
+// the number of keys ever used.
+func (manager *Manager) acquireShard(key string) *sync.Mutex {
+	if manager.shards == nil {
+		manager.shards = map[string]*managerShard{}
+	}
+	shard, ok := manager.shards[key]
+	if !ok {
+		shard = &managerShard{}
+		manager.shards[key] = shard
+	}
+	shard.refs++
+	return &shard.mu
+}
+
+// Must be called while holding the manager's mu.
+func (manager *Manager) releaseShard(key string) {
+	shard, ok := manager.shards[key]
+	if !ok {
+		return
+	}
+	shard.refs--
+	if shard.refs <= 0 {
+		delete(manager.shards, key)
+	}
+}
+
+// Acquires the shard of the script, while
+// the coroutine is running.
+func (ctrl *Control) lockShard() {
+	mu := ctrl.root().shardMu.Load()
+	if mu != nil {
+		mu.Lock()
+	}
+	// the script may be moved to another shard
+	// meanwhile, the same lock has to be unlocked
+	ctrl.heldShard = mu
+}
+
+func (ctrl *Control) unlockShard() {
+	if mu := ctrl.heldShard; mu != nil {
+		ctrl.heldShard = nil
+		mu.Unlock()
+	}
+}
@@ -0,0 +1,100 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestShard(t *testing.T) {
+	manager := carrot.NewManager()
+
+	// not synchronized, scripts in the same shard
+	// must not run at the same time
+	regions := map[string]*[]int{"north": {}, "south": {}}
+	for i := 0; i < 20; i++ {
+		key := "north"
+		if i%2 == 0 {
+			key = "south"
+		}
+		region := regions[key]
+		i := i
+		manager.Start(func(ctrl *carrot.Control) {
+			child := ctrl.StartAsync(func(ctrl *carrot.Control) {
+				for j := 0; j < 5; j++ {
+					*region = append(*region, -i)
+					ctrl.Yield()
+				}
+			})
+			for j := 0; j < 10; j++ {
+				*region = append(*region, i)
+				ctrl.Yield()
+			}
+			child.Join(ctrl)
+		}, carrot.WithShard(key))
+	}
+
+	for i := 0; i < 100 && manager.Len() > 0; i++ {
+		manager.UpdateParallel(4)
+	}
+	for manager.Len() > 0 {
+		manager.UpdateParallel(4)
+		time.Sleep(updateDelay)
+	}
+	if n, s := len(*regions["north"]), len(*regions["south"]); n != 150 || s != 150 {
+		t.Error("wrong number of updates", n, s)
+	}
+}
+
+func TestShardParallel(t *testing.T) {
+	manager := carrot.NewManager()
+
+	// serialized scripts only run at the same time when
+	// they are updated on different goroutines
+	north, south := make(chan bool), make(chan bool)
+	meet := func(send, receive chan bool) carrot.Coroutine {
+		return func(ctrl *carrot.Control) {
+			go func() { send <- true }()
+			select {
+			case <-receive:
+			case <-time.After(time.Second):
+				t.Error("scripts of different shards were not updated at the same time")
+			}
+		}
+	}
+	manager.Start(meet(north, south), carrot.WithShard("north"), carrot.Serialized())
+	manager.Start(meet(south, north), carrot.WithShard("south"), carrot.Serialized())
+	for i := 0; i < 10 && manager.Len() > 0; i++ {
+		manager.UpdateParallel(2)
+	}
+	if manager.Len() > 0 {
+		t.Error("scripts should be done")
+	}
+}
+
+func TestShardExclusive(t *testing.T) {
+	manager := carrot.NewManager()
+
+	var running, overlaps atomic.Int32
+	for i := 0; i < 8; i++ {
+		manager.Start(func(ctrl *carrot.Control) {
+			for j := 0; j < 5; j++ {
+				if running.Add(1) > 1 {
+					overlaps.Add(1)
+				}
+				time.Sleep(100 * time.Microsecond)
+				running.Add(-1)
+				ctrl.Yield()
+			}
+		}, carrot.WithShard("north"))
+	}
+	for manager.Len() > 0 {
+		manager.UpdateParallel(4)
+		time.Sleep(updateDelay)
+	}
+	if n := overlaps.Load(); n > 0 {
+		t.Error("scripts of the same shard ran at the same time", n)
+	}
+}
@@ -0,0 +1,60 @@
+package carrot
+
+import "sync/atomic"
+
+// A Shared is a value published by a coroutine and read
+// by the main thread, for instance the current target position
+// or animation state of a script. Publish() and Read() never
+// block each other, and a read never sees a partially
+// written value.
+//
+//	Note: Publish() must only be called from one coroutine
+//	at a time, and Read() only from the main thread.
+//	The value is buffered, so Read() returns the
+//	last value that was fully published.
+type Shared[T any] struct {
+	// three buffers: one owned by the writer, one owned
+	// by the reader, and one in the middle that is
+	// exchanged between the two
+	buffers [3]T
+
+	// index of the middle buffer, with sharedFresh set
+	// if it holds a value not yet seen by the reader
+	middle atomic.Uint32
+
+	// only accessed by the writer
+	back uint32
+	// only accessed by the reader
+	front uint32
+}
+
+const sharedFresh = 1 << 2
+
+// Creates a shared value that reads initial until
+// something is published.
+func NewShared[T any](initial T) *Shared[T] {
+	shared := &Shared[T]{
+		buffers: [3]T{initial, initial, initial},
+		front:   0,
+		back:    2,
+	}
+	shared.middle.Store(1)
+	return shared
+}
+
+// Publishes the value, which will be returned by
+// the following calls to Read(). Does not yield.
+func (shared *Shared[T]) Publish(value T) {
+	shared.buffers[shared.back] = value
+	prev := shared.middle.Swap(shared.back | sharedFresh)
+	shared.back = prev &^ sharedFresh
+}
+
+// Returns the last published value.
+func (shared *Shared[T]) Read() T {
+	if shared.middle.Load()&sharedFresh != 0 {
+		prev := shared.middle.Swap(shared.front)
+		shared.front = prev &^ sharedFresh
+	}
+	return shared.buffers[shared.front]
+}
@@ -0,0 +1,42 @@
+package carrot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestShared(t *testing.T) {
+	type point struct{ X, Y int }
+	target := carrot.NewShared(point{-1, -1})
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		for i := 0; i < 1000; i++ {
+			target.Publish(point{i, i})
+			if i%100 == 0 {
+				ctrl.Yield()
+			}
+		}
+	})
+
+	if p := target.Read(); p != (point{-1, -1}) {
+		t.Error("should read the initial value", p)
+	}
+	last := -1
+	for !script.IsDone() {
+		script.Update()
+		p := target.Read()
+		if p.X != p.Y {
+			t.Fatal("torn read", p)
+		}
+		if p.X < last {
+			t.Fatal("read an older value", p, last)
+		}
+		last = p.X
+		time.Sleep(updateDelay)
+	}
+	if p := target.Read(); p != (point{999, 999}) {
+		t.Error("should read the last value", p)
+	}
+}
@@ -0,0 +1,50 @@
+package carrot
+
+import (
+	"os"
+	"os/signal"
+	"sync/atomic"
+)
+
+// Yields until the process receives one of the signals,
+// then returns it. Panics if no signals are given, unlike
+// signal.Notify() which would relay every signal, including
+// the SIGURG the Go runtime uses for preemption. The coroutine
+// is parked while waiting, so it costs nothing to update.
+// Returns nil if the coroutine was cancelled.
+// Panics when cancelled, unless the cancel policy
+// is set to something other than CancelPanic.
+//
+//	manager.Start(func(ctrl *carrot.Control) {
+//		carrot.NotifySignal(ctrl, os.Interrupt, syscall.SIGTERM)
+//		server.Shutdown()
+//	})
+func NotifySignal(ctrl *Control, signals ...os.Signal) os.Signal {
+	if len(signals) == 0 {
+		panic("carrot: NotifySignal called without signals")
+	}
+	ch := make(chan os.Signal, 1)
+	stop := make(chan struct{})
+	var received atomic.Value
+	signal.Notify(ch, signals...)
+	defer signal.Stop(ch)
+	defer close(stop)
+
+	go func() {
+		select {
+		case sig := <-ch:
+			received.Store(&sig)
+			ctrl.Wake()
+		case <-stop:
+		}
+	}()
+
+	for {
+		if sig, ok := received.Load().(*os.Signal); ok {
+			return *sig
+		}
+		if !ctrl.AbyssUntilWoken() {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,49 @@
+//go:build unix
+
+package carrot_test
+
+import (
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestNotifySignal(t *testing.T) {
+	var received atomic.Value
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		if sig := carrot.NotifySignal(ctrl, syscall.SIGUSR1); sig != nil {
+			received.Store(sig)
+		}
+	})
+	for i := 0; i < 5; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if script.IsDone() || !script.IsIdle() {
+		t.Fatal("script should be parked until the signal")
+	}
+
+	if err := syscall.Kill(os.Getpid(), syscall.SIGUSR1); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if sig, _ := received.Load().(os.Signal); sig != syscall.SIGUSR1 {
+		t.Error("expected SIGUSR1, got", sig)
+	}
+}
+
+func TestNotifySignalNone(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NotifySignal without signals should panic")
+		}
+	}()
+	carrot.NotifySignal(carrot.NewControl())
+}
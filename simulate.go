@@ -0,0 +1,145 @@
+package carrot
+
+import (
+	"runtime"
+	"time"
+)
+
+// A SimulationResult is the outcome of Simulate().
+type SimulationResult struct {
+	// Number of updates that were run.
+	Frames int
+
+	// Time that was simulated, Frames times
+	// the frame duration.
+	Elapsed time.Duration
+
+	// True if the script finished before
+	// all the frames were run.
+	Done bool
+
+	// Stats of the base coroutine of the script,
+	// see script.Stats().
+	Stats Stats
+
+	// Checkpoints reached by the coroutines of the
+	// script, see script.Checkpoints().
+	Checkpoints []string
+
+	// Values emitted to the outboxes of the script during
+	// the simulation, see Emitter(). Values emitted in
+	// earlier frames come first, but values of different
+	// types emitted in the same frame are not ordered.
+	// Use EmittedValues() to get the values of one type.
+	Emitted []any
+}
+
+// Runs the script for the given number of frames without
+// waiting in between, advancing the time of the script by
+// frameDuration before each update, as script.Advance() does.
+// Stops early when the script is done. After each update,
+// waits until all coroutines of the script have yielded,
+// so the values emitted and checkpoints reached are
+// collected in the frame they happened.
+//
+// Useful as a foundation for balancing tools and automated
+// gameplay tests that run many simulated frames per second.
+// The clock of the script is stopped during the simulation,
+// as with script.Pause(), so that the simulated time doesn't
+// depend on the time spent running it, and continues from
+// the simulated time afterwards.
+//
+//	Note: A coroutine that blocks without yielding, for
+//	instance on a channel or on I/O, blocks the simulation
+//	as well. Use ctrl.AwaitContext() for I/O instead.
+func Simulate(script *Script, frames int, frameDuration time.Duration) SimulationResult {
+	// the simulated time must not depend on
+	// the time spent running the updates
+	script.baseControl.freezeClock(freezeSimulation)
+	defer script.baseControl.unfreezeClock(freezeSimulation)
+
+	var result SimulationResult
+	for result.Frames < frames {
+		if script.IsDone() {
+			result.Done = true
+			break
+		}
+		script.baseControl.clockOffset.Add(int64(frameDuration))
+		script.Update()
+		script.baseControl.settle()
+		result.Frames++
+		result.Elapsed += frameDuration
+		result.Emitted = append(result.Emitted, script.drainAll()...)
+	}
+	if !result.Done {
+		result.Done = script.IsDone()
+	}
+	result.Stats = script.Stats()
+	result.Checkpoints = script.Checkpoints()
+	return result
+}
+
+// Returns the values of type T emitted during
+// the simulation, in the order they were emitted.
+func EmittedValues[T any](result SimulationResult) []T {
+	var values []T
+	for _, value := range result.Emitted {
+		if v, ok := value.(T); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// Waits until the coroutine and its children are waiting
+// to be resumed, meaning they have finished running
+// for the last update.
+func (ctrl *Control) settle() {
+	spinUntil(ctrl.isSettled)
+}
+
+// Busy waits until cond returns true, backing off to
+// short sleeps when it takes more than a few spins.
+func spinUntil(cond func() bool) {
+	for spins := 0; !cond(); spins++ {
+		if spins < 100 {
+			runtime.Gosched()
+		} else {
+			time.Sleep(10 * time.Microsecond)
+		}
+	}
+}
+
+func (ctrl *Control) isSettled() bool {
+	if !ctrl.kanata.isWaiting() {
+		return false
+	}
+	ctrl.subControlsMu.RLock()
+	defer ctrl.subControlsMu.RUnlock()
+	for _, sub := range ctrl.subControls {
+		if !sub.isSettled() {
+			return false
+		}
+	}
+	return true
+}
+
+// Removes and returns the values of all the outboxes
+// of the script.
+func (script *Script) drainAll() []any {
+	root := script.baseControl
+	root.valuesMu.RLock()
+	var outboxes []anyOutbox
+	for _, value := range root.values {
+		if outbox, ok := value.(anyOutbox); ok {
+			outboxes = append(outboxes, outbox)
+		}
+	}
+	root.valuesMu.RUnlock()
+
+	var values []any
+	for _, outbox := range outboxes {
+		values = append(values, outbox.drainAny()...)
+	}
+	return values
+}
@@ -0,0 +1,94 @@
+package carrot_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestSimulate(t *testing.T) {
+	type hit struct{ damage int }
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		em := carrot.Emitter[hit](ctrl)
+		for i := 1; i <= 3; i++ {
+			ctrl.Sleep(time.Second)
+			em.Emit(hit{damage: i})
+			ctrl.Checkpoint("hit")
+		}
+	}, carrot.WithClock(fixedClock{now: time.Unix(0, 0)}))
+
+	result := carrot.Simulate(script, 1000, time.Second/10)
+	if !result.Done {
+		t.Error("script should be done")
+	}
+	if result.Frames < 30 || result.Frames > 32 {
+		t.Error("expected around 31 frames, got", result.Frames)
+	}
+	if result.Elapsed != time.Duration(result.Frames)*time.Second/10 {
+		t.Error("wrong elapsed time", result.Elapsed)
+	}
+	expected := []hit{{1}, {2}, {3}}
+	if hits := carrot.EmittedValues[hit](result); !reflect.DeepEqual(hits, expected) {
+		t.Error("expected", expected, "got", hits)
+	}
+	if len(result.Checkpoints) != 3 {
+		t.Error("expected 3 checkpoints, got", result.Checkpoints)
+	}
+	if result.Stats.Resumes == 0 {
+		t.Error("stats should be collected")
+	}
+}
+
+func TestSimulateFrames(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			for i := 0; ; i++ {
+				carrot.Emitter[int](ctrl).Emit(i)
+				ctrl.Yield()
+			}
+		})
+		ctrl.Abyss()
+	})
+	result := carrot.Simulate(script, 100, time.Millisecond)
+	if result.Frames != 100 || result.Done {
+		t.Error("expected 100 frames without finishing, got", result.Frames, result.Done)
+	}
+	values := carrot.EmittedValues[int](result)
+	for i, value := range values {
+		if value != i {
+			t.Fatal("values should be in order, got", values)
+		}
+	}
+	if len(values) != 99 {
+		t.Error("expected a value for each frame after the first, got", len(values))
+	}
+	script.Close()
+}
+
+func TestSimulateSystemClock(t *testing.T) {
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		// slower than the simulated frames, which must not
+		// make the simulated time pass faster
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			for {
+				time.Sleep(3 * time.Millisecond)
+				ctrl.Yield()
+			}
+		})
+		ctrl.Sleep(100 * time.Millisecond)
+		ctrl.Checkpoint("woke")
+		ctrl.Abyss()
+	})
+
+	result := carrot.Simulate(script, 60, time.Millisecond)
+	if len(result.Checkpoints) != 0 {
+		t.Error("simulated time should not include the real time", result.Checkpoints)
+	}
+	result = carrot.Simulate(script, 50, time.Millisecond)
+	if len(result.Checkpoints) != 1 {
+		t.Error("expected the sleep to end, got", result.Checkpoints)
+	}
+	script.Close()
+}
@@ -40,7 +40,15 @@ func (slice *sliceSet[T]) Clear() {
 	slice.items = slice.items[:0]
 }
 
+func (slice *sliceSet[T]) Len() int {
+	slice.mu.RLock()
+	defer slice.mu.RUnlock()
+	return len(slice.items)
+}
+
 func (slice *sliceSet[T]) Each(fn func(x T)) {
+	slice.mu.RLock()
+	defer slice.mu.RUnlock()
 	if len(slice.items) == 0 {
 		return
 	}
@@ -0,0 +1,101 @@
+package carrot
+
+// A ChildState is the state of a child coroutine
+// at the time of a snapshot. See ChildInfo.
+type ChildState uint32
+
+const (
+	// The coroutine has ended and is not restarting.
+	ChildDone ChildState = iota
+
+	// The coroutine is running, waiting on a yield,
+	// or starting on the next update.
+	ChildRunning
+
+	// The coroutine is paused with Pause().
+	ChildPaused
+
+	// The coroutine is cancelled, but has
+	// not ended yet.
+	ChildCancelling
+)
+
+func (state ChildState) String() string {
+	switch state {
+	case ChildDone:
+		return "done"
+	case ChildRunning:
+		return "running"
+	case ChildPaused:
+		return "paused"
+	case ChildCancelling:
+		return "cancelling"
+	}
+	return "unknown"
+}
+
+// A ChildInfo is a copy of the state of a child coroutine,
+// see ctrl.ChildrenSnapshot().
+type ChildInfo struct {
+	ID int64
+	// Name of the current coroutine function.
+	Name       string
+	State      ChildState
+	WaitReason WaitReason
+	// See ctrl.WaitLabel().
+	WaitLabel string
+	Priority  int
+	Tags      []string
+	// Number of the child's own children.
+	Children int
+}
+
+// Returns a copy of the state of the current child coroutines.
+// Unlike Children(), the result does not refer to the children,
+// so it can be kept and read after they are done and freed
+// for subsequent use. Useful for debug overlays that list
+// the children on every frame. Can be called from any thread.
+func (ctrl *Control) ChildrenSnapshot() []ChildInfo {
+	ctrl.subControlsMu.RLock()
+	defer ctrl.subControlsMu.RUnlock()
+	result := make([]ChildInfo, 0, len(ctrl.subControls))
+	for _, sub := range ctrl.subControls {
+		result = append(result, sub.info())
+	}
+	return result
+}
+
+// Returns a copy of the state of the coroutine, must be called
+// while holding the parent's subControlsMu so the
+// coroutine is not freed meanwhile.
+func (ctrl *Control) info() ChildInfo {
+	coroutine := ctrl.currentCoroutine()
+
+	ctrl.subControlsMu.RLock()
+	children := len(ctrl.subControls)
+	ctrl.subControlsMu.RUnlock()
+
+	state := ChildRunning
+	switch {
+	case ctrl.IsDone():
+		state = ChildDone
+	case ctrl.isRestarting():
+		// a new or restarting coroutine may still have
+		// the cancel state of its previous run
+	case ctrl.isCanceled() || ctrl.isCancelling():
+		state = ChildCancelling
+	case ctrl.IsPaused():
+		state = ChildPaused
+	}
+
+	return ChildInfo{
+		ID:         ctrl.ID,
+		Name:       coroutineName(coroutine),
+		State:      state,
+		WaitReason: ctrl.WaitReason(),
+		WaitLabel:  ctrl.WaitLabel(),
+		Priority:   int(ctrl.priority.Load()),
+		Tags:       ctrl.Tags(),
+		Children:   children,
+	}
+}
@@ -0,0 +1,69 @@
+package carrot
+
+import "fmt"
+
+// A Stall is a coroutine that has ended, but is not done
+// since one of its children hasn't ended after being cancelled,
+// for instance because the child never yields or recovers
+// from the cancellation. See WithStallReport().
+type Stall struct {
+	// ID and name of the coroutine that has ended.
+	ID   int64
+	Name string
+	// Number of frames the coroutine has waited for its children.
+	Frames int
+	// The first child that hasn't ended yet.
+	Child ChildInfo
+}
+
+func (stall Stall) String() string {
+	s := fmt.Sprintf("coroutine %v (%v) waited %v frames for child %v (%v, %v)",
+		stall.ID, stall.Name, stall.Frames, stall.Child.ID, stall.Child.Name, stall.Child.State)
+	if stall.Child.WaitLabel != "" {
+		s += fmt.Sprintf(", waiting for %q", stall.Child.WaitLabel)
+	}
+	return s
+}
+
+// Calls fn once when a coroutine of the script has ended but
+// has waited for the given number of frames for its children
+// to end, which otherwise looks like the coroutine never
+// becoming done. The stall is also logged. fn is called from
+// the coroutine's goroutine during Update(), so it must not
+// update the script. Only has effect when used with Start()
+// or Create().
+//
+//	Example:
+//	carrot.Start(level, carrot.WithStallReport(60, func(stall carrot.Stall) {
+//		log.Println(stall)
+//	}))
+func WithStallReport(frames int, fn func(Stall)) Option {
+	return func(ctrl *Control) {
+		ctrl.stallFrames = frames
+		ctrl.stallFn = fn
+	}
+}
+
+// Called by waitForSubsToEnd() on every frame that it waits,
+// reports the first child that is not done once
+// the frames reach the limit.
+func (ctrl *Control) checkStall(waited int, subs []*Control) {
+	root := ctrl.root()
+	if root.stallFn == nil || waited != root.stallFrames {
+		return
+	}
+	for _, s := range subs {
+		if s.IsDone() {
+			continue
+		}
+		stall := Stall{
+			ID:     ctrl.ID,
+			Name:   coroutineName(ctrl.currentCoroutine()),
+			Frames: waited,
+			Child:  s.info(),
+		}
+		ctrl.Logf("stalled: %v", stall)
+		root.stallFn(stall)
+		return
+	}
+}
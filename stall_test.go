@@ -0,0 +1,65 @@
+package carrot_test
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestWithStallReport(t *testing.T) {
+	var stopped atomic.Bool
+	var stalls []carrot.Stall
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.StartAsync(func(ctrl *carrot.Control) {
+			// ignores the cancellation until stopped
+			for !stopped.Load() {
+				ctrl.YieldNamed("stubborn")
+			}
+		})
+		ctrl.Delay(2)
+	},
+		carrot.WithCancelPolicy(carrot.CancelError),
+		carrot.WithStallReport(3, func(stall carrot.Stall) {
+			stalls = append(stalls, stall)
+		}),
+	)
+
+	for i := 0; i < 10; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if script.IsDone() {
+		t.Fatal("script should be waiting for the child")
+	}
+	if len(stalls) != 1 {
+		t.Fatal("expected one stall, got", len(stalls))
+	}
+	stall := stalls[0]
+	if stall.Frames != 3 {
+		t.Error("wrong number of frames", stall.Frames)
+	}
+	if stall.Child.WaitLabel != "stubborn" {
+		t.Error("wrong wait label", stall.Child.WaitLabel)
+	}
+	if stall.Child.State != carrot.ChildCancelling {
+		t.Error("wrong child state", stall.Child.State)
+	}
+	if !strings.Contains(stall.String(), `waiting for "stubborn"`) {
+		t.Error("wrong string", stall.String())
+	}
+
+	stopped.Store(true)
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !script.IsDone() {
+		t.Error("script should be done")
+	}
+	if len(stalls) != 1 {
+		t.Error("stall should be reported once", len(stalls))
+	}
+}
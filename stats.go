@@ -0,0 +1,64 @@
+package carrot
+
+import "sync/atomic"
+
+// Stats are counters about how a coroutine was run,
+// useful for dashboards and for finding scripts that
+// could do with a lower update rate. See ctrl.Stats().
+type Stats struct {
+	// Number of times the coroutine was resumed.
+	Resumes int64
+
+	// Number of updates where the coroutine was running
+	// but was not resumed, for instance because it was
+	// sleeping, parked, or not its turn with
+	// SetUpdateDivider().
+	FramesWaited int64
+
+	// Number of times the coroutine was restarted, which
+	// includes transitions. The first start is not counted.
+	Restarts int64
+
+	// Number of times the coroutine was cancelled with
+	// Cancel(), or because its parent ended. Cancellations
+	// caused by Restart() or Transition() are not counted.
+	Cancels int64
+}
+
+type controlStats struct {
+	resumes      atomic.Int64
+	framesWaited atomic.Int64
+	starts       atomic.Int64
+	cancels      atomic.Int64
+}
+
+func (stats *controlStats) reset() {
+	stats.resumes.Store(0)
+	stats.framesWaited.Store(0)
+	stats.starts.Store(0)
+	stats.cancels.Store(0)
+}
+
+// Returns the counters of the coroutine. Child coroutines
+// are not included. Can be called from any thread.
+// The counters stay at zero while the instrumentation
+// is disabled, see SetInstrumentation().
+func (ctrl *Control) Stats() Stats {
+	stats := &ctrl.stats
+	restarts := stats.starts.Load() - 1
+	if restarts < 0 {
+		restarts = 0
+	}
+	return Stats{
+		Resumes:      stats.resumes.Load(),
+		FramesWaited: stats.framesWaited.Load(),
+		Restarts:     restarts,
+		Cancels:      stats.cancels.Load(),
+	}
+}
+
+// Returns the counters of the script's main coroutine.
+// See ctrl.Stats().
+func (script *Script) Stats() Stats {
+	return script.baseControl.Stats()
+}
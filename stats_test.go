@@ -0,0 +1,72 @@
+package carrot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestStats(t *testing.T) {
+	clock := &manualClock{}
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Yield()
+		ctrl.Sleep(time.Second)
+		ctrl.Abyss()
+	}, carrot.WithClock(clock))
+	update := func(n int) {
+		for i := 0; i < n; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update(10)
+	stats := script.Stats()
+	if stats.Resumes != 2 || stats.FramesWaited != 8 {
+		t.Error("sleeping coroutine should not be resumed", stats)
+	}
+
+	clock.Add(time.Second)
+	update(3)
+	if stats := script.Stats(); stats.Resumes != 5 || stats.Restarts != 0 {
+		t.Error("coroutine should be resumed after the sleep", stats)
+	}
+
+	script.Restart()
+	update(3)
+	script.Transition(func(ctrl *carrot.Control) { ctrl.Abyss() })
+	update(3)
+	script.Cancel()
+	update(3)
+	stats = script.Stats()
+	if stats.Restarts != 2 || stats.Cancels != 1 {
+		t.Error("expected 2 restarts and 1 cancel", stats)
+	}
+}
+
+func TestSetInstrumentation(t *testing.T) {
+	carrot.SetInstrumentation(false)
+	defer carrot.SetInstrumentation(true)
+
+	var sub carrot.SubControl
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		sub = ctrl.StartAsync(func(ctrl *carrot.Control) {
+			for {
+				ctrl.YieldNamed("spin")
+			}
+		})
+		ctrl.Abyss()
+	})
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if label := sub.WaitLabel(); label != "" {
+		t.Error("wait labels should not be set, got", label)
+	}
+	if stats := sub.Stats(); stats != (carrot.Stats{}) {
+		t.Error("stats should not be counted, got", stats)
+	}
+	script.Close()
+}
@@ -0,0 +1,55 @@
+package carrot
+
+import "golang.org/x/exp/slices"
+
+// A ScriptTemplate is a factory of scripts that run the same
+// coroutine with the same options. Useful for creating
+// many identical scripts, for instance one for each
+// entity in a game.
+//
+//	Note: The options are applied again on every Spawn(),
+//	so options that hold state, such as WithIDSource()
+//	or WithClock(), are shared between the spawned scripts.
+type ScriptTemplate struct {
+	coroutine Coroutine
+	options   []Option
+}
+
+// Creates a template of the coroutine with the given options.
+func Template(coroutine Coroutine, options ...Option) *ScriptTemplate {
+	return &ScriptTemplate{
+		coroutine: coroutine,
+		options:   slices.Clone(options),
+	}
+}
+
+// Returns a new template with the options added
+// after the template's options.
+func (template *ScriptTemplate) With(options ...Option) *ScriptTemplate {
+	return &ScriptTemplate{
+		coroutine: template.coroutine,
+		options:   append(slices.Clone(template.options), options...),
+	}
+}
+
+// Creates a new script from the template. The extra options
+// are applied after the template's options.
+// Like Start(), the coroutine only starts on the first Update().
+func (template *ScriptTemplate) Spawn(extra ...Option) *Script {
+	return Start(template.coroutine, template.withExtra(extra)...)
+}
+
+// Creates a new script from the template and adds it to the manager.
+// See manager.Start().
+func (manager *Manager) Spawn(template *ScriptTemplate, extra ...Option) *Script {
+	return manager.Start(template.coroutine, template.withExtra(extra)...)
+}
+
+func (template *ScriptTemplate) withExtra(extra []Option) []Option {
+	if len(extra) == 0 {
+		return template.options
+	}
+	options := make([]Option, 0, len(template.options)+len(extra))
+	options = append(options, template.options...)
+	return append(options, extra...)
+}
@@ -0,0 +1,207 @@
+package carrot
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// A Timeline is a sequence of simple steps that can be
+// loaded from data, so that designers can author
+// sequences such as cutscenes without writing code.
+// Programmers can keep using coroutines for complex logic,
+// and bind the names used in the timeline to Go functions
+// with a TimelineEnv.
+//
+//	{"steps": [
+//		{"call": "openDoor"},
+//		{"parallel": [
+//			{"tween": "cameraZoom", "from": 1, "to": 2, "duration": 0.5, "ease": "inout"},
+//			{"steps": [{"wait": 0.2}, {"call": "playCreak"}]}
+//		]},
+//		{"wait": 1}
+//	]}
+//
+// Timelines are loaded from JSON with LoadTimeline(), or
+// from YAML with LoadTimelineWith() and a YAML decoder.
+type Timeline struct {
+	Steps []TimelineStep `json:"steps" yaml:"steps"`
+}
+
+// A TimelineStep is one step of a Timeline. The kind of
+// the step is given by which field is set:
+//
+//	call:     calls the function bound to the name
+//	tween:    changes the value bound to the name from
+//	          From to To over Duration seconds
+//	parallel: runs the steps at the same time, and waits
+//	          for all of them to end
+//	steps:    runs the steps one after another
+//	wait:     sleeps for Wait seconds, which is the
+//	          kind used when no other field is set
+type TimelineStep struct {
+	Wait     float64        `json:"wait,omitempty" yaml:"wait,omitempty"`
+	Call     string         `json:"call,omitempty" yaml:"call,omitempty"`
+	Tween    string         `json:"tween,omitempty" yaml:"tween,omitempty"`
+	From     float64        `json:"from,omitempty" yaml:"from,omitempty"`
+	To       float64        `json:"to,omitempty" yaml:"to,omitempty"`
+	Duration float64        `json:"duration,omitempty" yaml:"duration,omitempty"`
+	Ease     string         `json:"ease,omitempty" yaml:"ease,omitempty"`
+	Parallel []TimelineStep `json:"parallel,omitempty" yaml:"parallel,omitempty"`
+	Steps    []TimelineStep `json:"steps,omitempty" yaml:"steps,omitempty"`
+}
+
+// A TimelineEnv binds the names used in a
+// Timeline to functions.
+type TimelineEnv struct {
+	// Functions for the call steps. The function runs
+	// inside the coroutine, so it may yield.
+	Calls map[string]func(ctrl *Control)
+
+	// Setters for the tween steps, called once
+	// per frame with the current value.
+	Values map[string]func(value float64)
+}
+
+// Supported values of TimelineStep.Ease. An empty
+// string is the same as "linear".
+var timelineEases = map[string]func(t float64) float64{
+	"":       func(t float64) float64 { return t },
+	"linear": func(t float64) float64 { return t },
+	"in":     func(t float64) float64 { return t * t },
+	"out":    func(t float64) float64 { return t * (2 - t) },
+	"inout": func(t float64) float64 {
+		if t < 0.5 {
+			return 2 * t * t
+		}
+		return -1 + (4-2*t)*t
+	},
+}
+
+// Parses a timeline from JSON, and checks that the steps
+// are well-formed. The names are checked later by Run().
+func LoadTimeline(data []byte) (*Timeline, error) {
+	return LoadTimelineWith(data, json.Unmarshal)
+}
+
+// Same as LoadTimeline(), but parses the data with the given
+// decoder, for instance yaml.Unmarshal from gopkg.in/yaml.v3
+// to load timelines from YAML. The package doesn't depend
+// on a YAML decoder itself.
+//
+//	timeline, err := carrot.LoadTimelineWith(data, yaml.Unmarshal)
+func LoadTimelineWith(data []byte, unmarshal func(data []byte, v any) error) (*Timeline, error) {
+	var timeline Timeline
+	if err := unmarshal(data, &timeline); err != nil {
+		return nil, err
+	}
+	if err := timeline.Validate(nil); err != nil {
+		return nil, err
+	}
+	return &timeline, nil
+}
+
+// Returns an error if a step sets more than one kind, has
+// a negative duration or an unknown ease, or, if env
+// isn't nil, uses a name that isn't bound in env.
+func (timeline *Timeline) Validate(env *TimelineEnv) error {
+	return validateSteps(timeline.Steps, env, "steps")
+}
+
+// Runs the steps of the timeline in order, and returns when
+// all of them have ended. Returns an error without running
+// anything if the timeline isn't valid for env.
+// Panics when cancelled.
+func (timeline *Timeline) Run(ctrl *Control, env TimelineEnv) error {
+	if err := timeline.Validate(&env); err != nil {
+		return err
+	}
+	runSteps(ctrl, timeline.Steps, &env)
+	return nil
+}
+
+func validateSteps(steps []TimelineStep, env *TimelineEnv, path string) error {
+	for i, step := range steps {
+		stepPath := fmt.Sprintf("%v[%v]", path, i)
+		kinds := 0
+		for _, set := range []bool{step.Call != "", step.Tween != "", step.Parallel != nil, step.Steps != nil} {
+			if set {
+				kinds++
+			}
+		}
+		if kinds > 1 {
+			return fmt.Errorf("timeline: %v has more than one kind", stepPath)
+		}
+		if step.Wait < 0 || step.Duration < 0 {
+			return fmt.Errorf("timeline: %v has a negative duration", stepPath)
+		}
+		if _, ok := timelineEases[step.Ease]; !ok {
+			return fmt.Errorf("timeline: %v has unknown ease %q", stepPath, step.Ease)
+		}
+		if env != nil && step.Call != "" && env.Calls[step.Call] == nil {
+			return fmt.Errorf("timeline: %v calls unbound function %q", stepPath, step.Call)
+		}
+		if env != nil && step.Tween != "" && env.Values[step.Tween] == nil {
+			return fmt.Errorf("timeline: %v tweens unbound value %q", stepPath, step.Tween)
+		}
+		if err := validateSteps(step.Parallel, env, stepPath+".parallel"); err != nil {
+			return err
+		}
+		if err := validateSteps(step.Steps, env, stepPath+".steps"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runSteps(ctrl *Control, steps []TimelineStep, env *TimelineEnv) {
+	for i := range steps {
+		runStep(ctrl, &steps[i], env)
+	}
+}
+
+func runStep(ctrl *Control, step *TimelineStep, env *TimelineEnv) {
+	switch {
+	case step.Call != "":
+		env.Calls[step.Call](ctrl)
+	case step.Tween != "":
+		runTween(ctrl, step, env.Values[step.Tween])
+	case step.Parallel != nil:
+		subs := make([]SubControl, len(step.Parallel))
+		for i := range step.Parallel {
+			child := &step.Parallel[i]
+			subs[i] = ctrl.StartAsync(func(ctrl *Control) {
+				runStep(ctrl, child, env)
+			})
+		}
+		for _, sub := range subs {
+			sub.Join(ctrl)
+		}
+	case step.Steps != nil:
+		runSteps(ctrl, step.Steps, env)
+	default:
+		ctrl.Sleep(seconds(step.Wait))
+	}
+}
+
+func runTween(ctrl *Control, step *TimelineStep, set func(float64)) {
+	ease := timelineEases[step.Ease]
+	duration := seconds(step.Duration)
+	start := ctrl.Now()
+	for {
+		t := 1.0
+		if duration > 0 {
+			t = float64(ctrl.Now().Sub(start)) / float64(duration)
+		}
+		if t >= 1 {
+			set(step.To)
+			return
+		}
+		set(step.From + (step.To-step.From)*ease(t))
+		ctrl.Yield()
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,120 @@
+package carrot_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestTimeline(t *testing.T) {
+	timeline, err := carrot.LoadTimeline([]byte(`{"steps": [
+		{"call": "open"},
+		{"parallel": [
+			{"tween": "zoom", "from": 1, "to": 2, "duration": 0.5},
+			{"steps": [{"wait": 0.2}, {"call": "creak"}]}
+		]},
+		{"wait": 1},
+		{"call": "close"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock := &manualClock{}
+	var mu sync.Mutex
+	var calls []string
+	var zooms []float64
+	call := func(name string) func(*carrot.Control) {
+		return func(ctrl *carrot.Control) {
+			mu.Lock()
+			calls = append(calls, name)
+			mu.Unlock()
+		}
+	}
+	env := carrot.TimelineEnv{
+		Calls: map[string]func(*carrot.Control){
+			"open":  call("open"),
+			"creak": call("creak"),
+			"close": call("close"),
+		},
+		Values: map[string]func(float64){
+			"zoom": func(value float64) {
+				mu.Lock()
+				zooms = append(zooms, value)
+				mu.Unlock()
+			},
+		},
+	}
+
+	var runErr error
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		runErr = timeline.Run(ctrl, env)
+	}, carrot.WithClock(clock))
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+		clock.Add(100 * time.Millisecond)
+	}
+	if !script.IsDone() || runErr != nil {
+		t.Fatal("timeline should end", runErr)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if expected := []string{"open", "creak", "close"}; !reflect.DeepEqual(calls, expected) {
+		t.Errorf("expected calls %v, got %v", expected, calls)
+	}
+	if len(zooms) < 3 || zooms[0] != 1 || zooms[len(zooms)-1] != 2 {
+		t.Error("tween should go from 1 to 2", zooms)
+	}
+	for i := 1; i < len(zooms); i++ {
+		if zooms[i] < zooms[i-1] {
+			t.Error("tween values should increase", zooms)
+		}
+	}
+}
+
+func TestTimelineValidate(t *testing.T) {
+	for _, data := range []string{
+		`{"steps": [{"call": "a", "tween": "b"}]}`,
+		`{"steps": [{"wait": -1}]}`,
+		`{"steps": [{"parallel": [{"tween": "a", "ease": "bounce"}]}]}`,
+		`{"steps": [`,
+	} {
+		if _, err := carrot.LoadTimeline([]byte(data)); err == nil {
+			t.Error("expected error for", data)
+		}
+	}
+
+	timeline, err := carrot.LoadTimeline([]byte(`{"steps": [{"steps": [{"call": "missing"}]}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := timeline.Validate(&carrot.TimelineEnv{}); err == nil {
+		t.Error("unbound names should be an error")
+	}
+}
+
+func TestLoadTimelineWith(t *testing.T) {
+	// stands in for a YAML decoder
+	decode := func(steps ...carrot.TimelineStep) func([]byte, any) error {
+		return func(data []byte, v any) error {
+			v.(*carrot.Timeline).Steps = steps
+			return nil
+		}
+	}
+
+	timeline, err := carrot.LoadTimelineWith(nil, decode(carrot.TimelineStep{Call: "open"}, carrot.TimelineStep{Wait: 1}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(timeline.Steps) != 2 || timeline.Steps[0].Call != "open" {
+		t.Error("wrong steps", timeline.Steps)
+	}
+	if _, err := carrot.LoadTimelineWith(nil, decode(carrot.TimelineStep{Wait: -1})); err == nil {
+		t.Error("decoded timelines should be validated")
+	}
+}
@@ -0,0 +1,52 @@
+package carrot
+
+import "time"
+
+// Cancels the coroutine if it hasn't finished within the
+// duration after it started, with the cause CauseTimeout.
+// The duration is measured with the clock of the script, and
+// starts over when the coroutine is restarted. See also
+// StartAsyncTimeout() and TimedOut().
+func WithTimeout(duration time.Duration) Option {
+	return func(ctrl *Control) {
+		ctrl.timeout = duration
+	}
+}
+
+// Same as StartAsync(), but the child is cancelled if it
+// hasn't finished within the duration, see WithTimeout().
+//
+//	sub := ctrl.StartAsyncTimeout(lookForPlayer, 10*time.Second)
+//	ctrl.YieldUntil(sub.IsDone)
+//	if sub.TimedOut() { ... }
+func (ctrl *Control) StartAsyncTimeout(coroutine Coroutine, duration time.Duration, options ...Option) SubControl {
+	return ctrl.StartAsync(coroutine, append(options, WithTimeout(duration))...)
+}
+
+// Returns true if the coroutine was cancelled
+// since it ran past its timeout, see WithTimeout().
+func (ctrl *Control) TimedOut() bool {
+	return ctrl.timedOut.Load()
+}
+
+// Called on every update, cancels the coroutine
+// once it's past its deadline.
+func (ctrl *Control) checkTimeout() {
+	at := ctrl.timeoutAt.Load()
+	if at == 0 || ctrl.IsDone() || ctrl.Now().UnixNano() < at {
+		return
+	}
+	ctrl.timeoutAt.Store(0)
+	ctrl.timedOut.Store(true)
+	ctrl.Logf("timed out after %v", ctrl.timeout)
+	ctrl.cancelWith(CauseTimeout)
+}
+
+// Called when the coroutine is started or restarted.
+func (ctrl *Control) startTimeout() {
+	if ctrl.timeout <= 0 {
+		return
+	}
+	ctrl.timedOut.Store(false)
+	ctrl.timeoutAt.Store(ctrl.Now().Add(ctrl.timeout).UnixNano())
+}
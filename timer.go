@@ -0,0 +1,81 @@
+package carrot
+
+import (
+	"sync"
+	"time"
+)
+
+var timerPool = sync.Pool{}
+
+type afterTimer struct {
+	timer    *time.Timer
+	deadline time.Time
+}
+
+// Stops the timer, and returns it to the pool if
+// it has not fired yet, so that a reused timer
+// never has a stale value in its channel.
+func freeTimer(t *time.Timer) {
+	if t.Stop() {
+		timerPool.Put(t)
+	}
+}
+
+// Returns a channel that receives the current time after
+// the duration, like time.After(). Unlike time.After(), the
+// timer is stopped and reused once the coroutine ends
+// or is cancelled, so it does not leak when the coroutine is
+// cancelled while waiting. Useful for timeouts when
+// polling channels without blocking:
+//
+//	timeout := ctrl.AfterChan(time.Second)
+//	ctrl.YieldUntil(func() bool {
+//		select {
+//		case msg = <-messages:
+//			return true
+//		case <-timeout:
+//			return true
+//		default:
+//			return false
+//		}
+//	})
+//
+//	Note: The timer uses the system time, not the
+//	clock of the script, so it is not affected
+//	by FastForward() or Advance(). The channel must
+//	not be used after the coroutine ends.
+func (ctrl *Control) AfterChan(d time.Duration) <-chan time.Time {
+	ctrl.timersMu.Lock()
+	defer ctrl.timersMu.Unlock()
+
+	// forget the timers that already fired
+	now := time.Now()
+	timers := ctrl.timers[:0]
+	for _, t := range ctrl.timers {
+		if now.Before(t.deadline) {
+			timers = append(timers, t)
+		}
+	}
+	for i := len(timers); i < len(ctrl.timers); i++ {
+		ctrl.timers[i] = afterTimer{}
+	}
+
+	t, ok := timerPool.Get().(*time.Timer)
+	if ok {
+		t.Reset(d)
+	} else {
+		t = time.NewTimer(d)
+	}
+	ctrl.timers = append(timers, afterTimer{t, now.Add(d)})
+	return t.C
+}
+
+func (ctrl *Control) stopTimers() {
+	ctrl.timersMu.Lock()
+	defer ctrl.timersMu.Unlock()
+	for i, t := range ctrl.timers {
+		freeTimer(t.timer)
+		ctrl.timers[i] = afterTimer{}
+	}
+	ctrl.timers = ctrl.timers[:0]
+}
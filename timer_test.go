@@ -0,0 +1,45 @@
+package carrot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestAfterChan(t *testing.T) {
+	messages := make(chan string)
+	var timedOut bool
+	var stale <-chan time.Time
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		stale = ctrl.AfterChan(10 * time.Millisecond)
+		timeout := ctrl.AfterChan(time.Millisecond)
+		ctrl.YieldUntil(func() bool {
+			select {
+			case <-messages:
+				return true
+			case <-timeout:
+				timedOut = true
+				return true
+			default:
+				return false
+			}
+		})
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !timedOut {
+		t.Error("should time out")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case <-stale:
+		t.Error("timer should be stopped when the coroutine ends")
+	default:
+	}
+}
@@ -0,0 +1,112 @@
+package carrot
+
+import "sync"
+
+// A Token cancels every coroutine it was given to with
+// WithToken(), across scripts and regardless of their
+// parent/child structure, for instance to abort a cutscene
+// that spans the scripts of several entities.
+//
+//	Note: Methods are all concurrent-safe.
+type Token struct {
+	mu        sync.Mutex
+	cancelled bool
+	done      chan struct{}
+	ctrls     []tokenEntry
+}
+
+type tokenEntry struct {
+	ctrl *Control
+	// generation of the control when it was attached, so that
+	// a control freed and reused for another coroutine
+	// is not cancelled
+	generation uint64
+}
+
+// Creates a new token that is not cancelled.
+func NewToken() *Token {
+	return &Token{done: make(chan struct{})}
+}
+
+// Attaches the token to a script or a child coroutine. When the
+// token is cancelled, the coroutine is cancelled as with Cancel(),
+// with the cause CauseToken. Attaching a token that is
+// already cancelled cancels the coroutine right away.
+func WithToken(token *Token) Option {
+	return func(ctrl *Control) {
+		token.attach(ctrl)
+	}
+}
+
+// Cancels all the coroutines the token is attached to
+// that are still running, and the ones that it will
+// be attached to. Does nothing if already cancelled.
+//
+//	Note: As with Cancel(), the coroutines are only
+//	cancelled on the next Update() of their scripts.
+func (token *Token) Cancel() {
+	token.mu.Lock()
+	defer token.mu.Unlock()
+	if token.cancelled {
+		return
+	}
+	token.cancelled = true
+	close(token.done)
+	for _, entry := range token.ctrls {
+		if entry.ctrl.generation.Load() == entry.generation {
+			entry.ctrl.cancelWith(CauseToken)
+		}
+	}
+	token.ctrls = nil
+}
+
+// Returns true if the token has been cancelled.
+func (token *Token) IsCancelled() bool {
+	token.mu.Lock()
+	defer token.mu.Unlock()
+	return token.cancelled
+}
+
+// Returns a channel that is closed when the
+// token is cancelled.
+func (token *Token) Done() <-chan struct{} {
+	return token.done
+}
+
+// Returns the number of coroutines the token is
+// attached to that have not ended yet.
+func (token *Token) Len() int {
+	token.mu.Lock()
+	defer token.mu.Unlock()
+	token.pruneLocked()
+	return len(token.ctrls)
+}
+
+func (token *Token) attach(ctrl *Control) {
+	token.mu.Lock()
+	defer token.mu.Unlock()
+	if token.cancelled {
+		ctrl.cancelWith(CauseToken)
+		return
+	}
+	token.pruneLocked()
+	token.ctrls = append(token.ctrls, tokenEntry{
+		ctrl:       ctrl,
+		generation: ctrl.generation.Load(),
+	})
+}
+
+// removes the controls that were freed
+// or have ended, keeping the order
+func (token *Token) pruneLocked() {
+	kept := token.ctrls[:0]
+	for _, entry := range token.ctrls {
+		if entry.ctrl.generation.Load() == entry.generation && !entry.ctrl.IsDone() {
+			kept = append(kept, entry)
+		}
+	}
+	for i := len(kept); i < len(token.ctrls); i++ {
+		token.ctrls[i] = tokenEntry{}
+	}
+	token.ctrls = kept
+}
@@ -0,0 +1,62 @@
+package carrot_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestToken(t *testing.T) {
+	token := carrot.NewToken()
+	var child carrot.SubControl
+	first := carrot.Start(func(ctrl *carrot.Control) {
+		child = ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		}, carrot.WithToken(token))
+		ctrl.Abyss()
+	})
+	second := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Abyss()
+	}, carrot.WithToken(token))
+	update := func() {
+		for i := 0; i < 3; i++ {
+			first.Update()
+			second.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update()
+	if n := token.Len(); n != 2 {
+		t.Error("expected the token to be attached to 2 coroutines, got", n)
+	}
+	token.Cancel()
+	update()
+	if !child.IsDone() || !child.WasCancelled() {
+		t.Error("the child should be cancelled by the token")
+	}
+	if first.IsDone() {
+		t.Error("the parent of the child should keep running")
+	}
+	if !second.IsDone() {
+		t.Error("the second script should be cancelled by the token")
+	}
+	select {
+	case <-token.Done():
+	default:
+		t.Error("done should be closed")
+	}
+
+	late := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.Abyss()
+	}, carrot.WithToken(token))
+	for i := 0; i < 2; i++ {
+		late.Update()
+		time.Sleep(updateDelay)
+	}
+	if !late.IsDone() {
+		t.Error("attaching a cancelled token should cancel the coroutine")
+	}
+	first.Close()
+}
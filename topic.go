@@ -0,0 +1,195 @@
+package carrot
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// A Topic delivers each published value to every
+// subscribed coroutine, for one-to-many events such as
+// "level started" or "player died". Each subscription
+// buffers up to a fixed number of values, so a coroutine
+// that is busy or sleeping still observes the values
+// published in the meantime when it's next resumed.
+//
+//	var playerDied = carrot.NewTopic[DeathEvent](8)
+//
+//	sub := playerDied.Subscribe(ctrl)
+//	defer sub.Unsubscribe()
+//	for {
+//		event, ok := sub.Next(ctrl)
+//		if !ok { return }
+//		...
+//	}
+//
+//	// anywhere, from any thread
+//	playerDied.Publish(DeathEvent{...})
+type Topic[T any] struct {
+	mu       sync.Mutex
+	subs     []*Subscription[T]
+	capacity int
+}
+
+// A Subscription receives the values published
+// on a Topic after Subscribe() was called.
+type Subscription[T any] struct {
+	topic *Topic[T]
+	// closed when the subscribed coroutine ends or is cancelled
+	done <-chan struct{}
+
+	mu      sync.Mutex
+	buf     []T
+	head    int
+	size    int
+	dropped int
+
+	closed atomic.Bool
+}
+
+// Creates a topic where each subscription buffers up to
+// capacity values. When the buffer is full, the oldest
+// value is dropped. A capacity of less than one is
+// treated as one.
+func NewTopic[T any](capacity int) *Topic[T] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Topic[T]{capacity: capacity}
+}
+
+// Sends the value to all current subscriptions.
+// Does not yield. Can be called from any thread.
+func (topic *Topic[T]) Publish(value T) {
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+	subs := topic.subs[:0]
+	for _, sub := range topic.subs {
+		if sub.isStale() {
+			sub.closed.Store(true)
+			continue
+		}
+		sub.push(value)
+		subs = append(subs, sub)
+	}
+	for i := len(subs); i < len(topic.subs); i++ {
+		topic.subs[i] = nil
+	}
+	topic.subs = subs
+}
+
+// Returns the number of subscriptions.
+func (topic *Topic[T]) Subscribers() int {
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+	return len(topic.subs)
+}
+
+// Subscribes the coroutine to the topic. Only values
+// published after this call are received.
+// The subscription is removed when the coroutine ends or
+// is cancelled, or when Unsubscribe() is called.
+func (topic *Topic[T]) Subscribe(ctrl *Control) *Subscription[T] {
+	sub := &Subscription[T]{
+		topic: topic,
+		done:  ctrl.Context().Done(),
+		buf:   make([]T, topic.capacity),
+	}
+	topic.mu.Lock()
+	topic.subs = append(topic.subs, sub)
+	topic.mu.Unlock()
+	return sub
+}
+
+// Takes the oldest buffered value without yielding.
+// Returns false if there is none.
+func (sub *Subscription[T]) Poll() (T, bool) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	var value T
+	if sub.size == 0 {
+		return value, false
+	}
+	value = sub.buf[sub.head]
+	var zero T
+	sub.buf[sub.head] = zero
+	sub.head = (sub.head + 1) % len(sub.buf)
+	sub.size--
+	return value, true
+}
+
+// Yields until a value is available, then takes it.
+// Returns false if the subscription is closed, or
+// if the coroutine was cancelled.
+// Panics when cancelled, unless the cancel policy
+// is set to something other than CancelPanic.
+func (sub *Subscription[T]) Next(ctrl *Control) (T, bool) {
+	for {
+		if value, ok := sub.Poll(); ok {
+			return value, true
+		}
+		if sub.closed.Load() || ctrl.cancelReturns() {
+			var zero T
+			return zero, false
+		}
+		ctrl.yield(WaitCondition)
+	}
+}
+
+// Returns the number of buffered values.
+func (sub *Subscription[T]) Len() int {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.size
+}
+
+// Returns the number of values that were dropped
+// because the buffer was full.
+func (sub *Subscription[T]) Dropped() int {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	return sub.dropped
+}
+
+// Removes the subscription from the topic. Values that
+// are already buffered can still be taken with Poll().
+func (sub *Subscription[T]) Unsubscribe() {
+	if sub.closed.Swap(true) {
+		return
+	}
+	topic := sub.topic
+	topic.mu.Lock()
+	defer topic.mu.Unlock()
+	for i, s := range topic.subs {
+		if s == sub {
+			last := len(topic.subs) - 1
+			copy(topic.subs[i:], topic.subs[i+1:])
+			topic.subs[last] = nil
+			topic.subs = topic.subs[:last]
+			break
+		}
+	}
+}
+
+func (sub *Subscription[T]) isStale() bool {
+	if sub.closed.Load() {
+		return true
+	}
+	select {
+	case <-sub.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (sub *Subscription[T]) push(value T) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	if sub.size == len(sub.buf) {
+		sub.head = (sub.head + 1) % len(sub.buf)
+		sub.size--
+		sub.dropped++
+	}
+	sub.buf[(sub.head+sub.size)%len(sub.buf)] = value
+	sub.size++
+}
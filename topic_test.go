@@ -0,0 +1,80 @@
+package carrot_test
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestTopic(t *testing.T) {
+	topic := carrot.NewTopic[int](2)
+	var mu sync.Mutex
+	received := map[string][]int{}
+	listen := func(name string) carrot.Coroutine {
+		return func(ctrl *carrot.Control) {
+			sub := topic.Subscribe(ctrl)
+			defer sub.Unsubscribe()
+			for {
+				value, ok := sub.Next(ctrl)
+				if !ok || value < 0 {
+					return
+				}
+				mu.Lock()
+				received[name] = append(received[name], value)
+				mu.Unlock()
+			}
+		}
+	}
+
+	var lagging *carrot.Subscription[int]
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.StartAsync(listen("a"))
+		ctrl.StartAsync(listen("b"))
+		lagging = topic.Subscribe(ctrl)
+		ctrl.Abyss()
+	})
+	update := func() {
+		for i := 0; i < 3; i++ {
+			script.Update()
+			time.Sleep(updateDelay)
+		}
+	}
+
+	update()
+	if n := topic.Subscribers(); n != 3 {
+		t.Fatal("expected 3 subscribers, got", n)
+	}
+	for i := 1; i <= 3; i++ {
+		topic.Publish(i)
+		update()
+	}
+	topic.Publish(-1)
+	update()
+
+	mu.Lock()
+	expected := []int{1, 2, 3}
+	if !reflect.DeepEqual(received["a"], expected) || !reflect.DeepEqual(received["b"], expected) {
+		t.Error("every subscriber should receive every value", received)
+	}
+	mu.Unlock()
+
+	if lagging.Len() != 2 || lagging.Dropped() != 2 {
+		t.Error("buffer should keep the newest values", lagging.Len(), lagging.Dropped())
+	}
+	if value, _ := lagging.Poll(); value != 3 {
+		t.Error("expected oldest buffered value to be 3, got", value)
+	}
+	if n := topic.Subscribers(); n != 1 {
+		t.Error("ended subscribers should be removed, got", n)
+	}
+
+	script.Cancel()
+	update()
+	topic.Publish(0)
+	if n := topic.Subscribers(); n != 0 {
+		t.Error("cancelled subscribers should be removed, got", n)
+	}
+}
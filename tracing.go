@@ -0,0 +1,103 @@
+package carrot
+
+import "context"
+
+// A Tracer opens a span for each run of a coroutine,
+// see WithTracer().
+//
+// The method set is kept small so that carrot does not
+// depend on a tracing library. The otelcarrot package adapts
+// an OpenTelemetry TracerProvider:
+//
+//	script := carrot.Start(fn, otelcarrot.WithTracerProvider(ctx, provider))
+type Tracer interface {
+	// Starts a span as a child of the span in ctx, if any.
+	// The returned context is used as the parent of the spans
+	// of child coroutines, and as the parent of ctrl.Context().
+	StartSpan(ctx context.Context, name string, id int64) (context.Context, Span)
+}
+
+// A Span is the lifetime of a coroutine run, opened by a Tracer.
+// Methods may be called from different goroutines,
+// but never at the same time.
+type Span interface {
+	// Called when the coroutine transitions or is cancelled.
+	AddEvent(name string, attrs map[string]string)
+	// Called when the coroutine ends. The err is nil if the
+	// coroutine returned normally, ErrCancelled if it was
+	// cancelled, or a *PanicError.
+	End(err error)
+}
+
+// Opens a span with the tracer for each run of the script's
+// coroutines, with events for transitions and cancellations.
+// A restart ends the span and opens a new one. Spans of child
+// coroutines are children of the span of their parent. Only
+// has effect when used with Start() or Create().
+//
+// The ctx is the parent of the script's span, for instance
+// the context of a request that started the script. A nil
+// ctx is the same as context.Background().
+func WithTracer(ctx context.Context, tracer Tracer) Option {
+	return func(ctrl *Control) {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		ctrl.tracer = tracer
+		ctrl.traceCtx = ctx
+	}
+}
+
+// opens the span of the coroutine, called
+// before the coroutine function runs
+func (ctrl *Control) startSpan() {
+	tracer := ctrl.root().tracer
+	if tracer == nil {
+		return
+	}
+	parent := ctrl.root().traceCtx
+	if ctrl.parent != nil {
+		parent = ctrl.parent.spanContext()
+	}
+	ctx, span := tracer.StartSpan(parent, coroutineName(ctrl.coroutine), ctrl.ID)
+	ctrl.ctxMu.Lock()
+	ctrl.spanCtx, ctrl.span = ctx, span
+	ctrl.ctxMu.Unlock()
+}
+
+func (ctrl *Control) endSpan(err error) {
+	ctrl.ctxMu.Lock()
+	span := ctrl.span
+	ctrl.spanCtx, ctrl.span = nil, nil
+	ctrl.ctxMu.Unlock()
+	if span != nil {
+		span.End(err)
+	}
+}
+
+func (ctrl *Control) spanEvent(name string, attrs map[string]string) {
+	ctrl.ctxMu.Lock()
+	span := ctrl.span
+	ctrl.ctxMu.Unlock()
+	if span != nil {
+		span.AddEvent(name, attrs)
+	}
+}
+
+// Returns the context of the coroutine's span, or the
+// parent context of the script if there is none.
+func (ctrl *Control) spanContext() context.Context {
+	ctrl.ctxMu.Lock()
+	ctx := ctrl.spanCtx
+	ctrl.ctxMu.Unlock()
+	if ctx != nil {
+		return ctx
+	}
+	if ctrl.parent != nil {
+		return ctrl.parent.spanContext()
+	}
+	if ctx := ctrl.traceCtx; ctx != nil {
+		return ctx
+	}
+	return context.Background()
+}
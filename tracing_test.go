@@ -0,0 +1,86 @@
+package carrot_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+type spanKey struct{}
+
+type fakeTracer struct {
+	mu  sync.Mutex
+	log []string
+}
+
+func (tracer *fakeTracer) add(entry string) {
+	tracer.mu.Lock()
+	tracer.log = append(tracer.log, entry)
+	tracer.mu.Unlock()
+}
+
+func (tracer *fakeTracer) StartSpan(ctx context.Context, name string, id int64) (context.Context, carrot.Span) {
+	parent, _ := ctx.Value(spanKey{}).(int)
+	span := &fakeSpan{tracer: tracer, depth: parent + 1}
+	tracer.add(fmt.Sprint("start ", span.depth))
+	return context.WithValue(ctx, spanKey{}, span.depth), span
+}
+
+type fakeSpan struct {
+	tracer *fakeTracer
+	depth  int
+}
+
+func (span *fakeSpan) AddEvent(name string, attrs map[string]string) {
+	span.tracer.add(fmt.Sprint("event ", span.depth, " ", name))
+}
+
+func (span *fakeSpan) End(err error) {
+	span.tracer.add(fmt.Sprint("end ", span.depth, " ", err))
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &fakeTracer{}
+	var depth int
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			depth, _ = ctrl.Context().Value(spanKey{}).(int)
+		})
+		ctrl.YieldUntil(sub.IsDone)
+		ctrl.Transition(func(ctrl *carrot.Control) {
+			ctrl.Abyss()
+		})
+	}, carrot.WithTracer(context.Background(), tracer))
+	for i := 0; i < 6; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Cancel()
+	script.Update()
+	time.Sleep(updateDelay)
+
+	expected := []string{
+		"start 1",
+		"start 2",
+		"end 2 <nil>",
+		"event 1 transition",
+		"end 1 <nil>",
+		"start 1",
+		"event 1 cancel",
+		"end 1 " + carrot.ErrCancelled.Error(),
+	}
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	if !reflect.DeepEqual(tracer.log, expected) {
+		t.Errorf("expected %q, got %q", expected, tracer.log)
+	}
+	if depth != 2 {
+		t.Error("ctrl.Context() should carry the span of the coroutine, got depth", depth)
+	}
+	script.Close()
+}
@@ -0,0 +1,120 @@
+// Package tui drives carrot scripts from the event loop of
+// a terminal UI, such as tcell or Bubble Tea, for scripted
+// interactive CLIs and roguelikes.
+//
+// The package does not depend on any terminal library.
+// Events of any type are published to a carrot.Topic,
+// which coroutines subscribe to for input.
+//
+//	Example with tcell:
+//	loop := tui.New[tcell.Event](carrot.NewManager(), 16)
+//	loop.Manager.Start(func(ctrl *carrot.Control) {
+//		keys := loop.Events.Subscribe(ctrl)
+//		for {
+//			event, _ := keys.Next(ctrl)
+//			...
+//		}
+//	})
+//	events := make(chan tcell.Event)
+//	go screen.ChannelEvents(events, quit)
+//	loop.Run(ctx, events, screen.Show)
+//
+//	Example with Bubble Tea, in the model:
+//	func (m model) Init() tea.Cmd { return tick() }
+//	func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+//		if _, ok := msg.(tickMsg); ok {
+//			m.loop.Tick()
+//			return m, tick()
+//		}
+//		m.loop.Send(msg)
+//		return m, nil
+//	}
+package tui
+
+import (
+	"context"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+// The default interval between the updates
+// when no events are received.
+const DefaultTickRate = time.Second / 30
+
+// A Loop updates the scripts of a manager on every terminal
+// event, and periodically in between so that timers
+// such as ctrl.Sleep() keep running.
+type Loop[E any] struct {
+	// The scripts that are updated.
+	Manager *carrot.Manager
+
+	// Every event received by the loop is published here
+	// before the scripts are updated, so that coroutines
+	// waiting on a subscription see it on the same update.
+	Events *carrot.Topic[E]
+
+	// Interval between the updates when no events
+	// are received. Defaults to DefaultTickRate.
+	TickRate time.Duration
+}
+
+// Creates a loop for the manager, where each subscription
+// to the loop's events buffers up to capacity events.
+// A nil manager creates a new one.
+func New[E any](manager *carrot.Manager, capacity int) *Loop[E] {
+	if manager == nil {
+		manager = carrot.NewManager()
+	}
+	return &Loop[E]{
+		Manager:  manager,
+		Events:   carrot.NewTopic[E](capacity),
+		TickRate: DefaultTickRate,
+	}
+}
+
+// Publishes the event and updates the scripts. For event
+// loops that are not driven by Run(), such as Bubble Tea's
+// Update(). Must not be called concurrently with
+// Tick() or Run().
+func (loop *Loop[E]) Send(event E) {
+	loop.Events.Publish(event)
+	loop.Manager.Update()
+}
+
+// Updates the scripts without an event.
+// Must not be called concurrently with
+// Send() or Run().
+func (loop *Loop[E]) Tick() {
+	loop.Manager.Update()
+}
+
+// Receives events and updates the scripts until ctx is done
+// or the events channel is closed. The draw function, if not
+// nil, is called after every update, for instance to show
+// the screen. Returns ctx.Err() if ctx is done, or nil
+// if the channel was closed.
+func (loop *Loop[E]) Run(ctx context.Context, events <-chan E, draw func()) error {
+	rate := loop.TickRate
+	if rate <= 0 {
+		rate = DefaultTickRate
+	}
+	ticker := time.NewTicker(rate)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			loop.Send(event)
+		case <-ticker.C:
+			loop.Tick()
+		}
+		if draw != nil {
+			draw()
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package tui_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+	"github.com/nvlled/carrot/tui"
+)
+
+func TestLoop(t *testing.T) {
+	loop := tui.New[rune](nil, 8)
+	var typed atomic.Value
+	typed.Store("")
+	loop.Manager.Start(func(ctrl *carrot.Control) {
+		keys := loop.Events.Subscribe(ctrl)
+		text := ""
+		for {
+			key, ok := keys.Next(ctrl)
+			if !ok {
+				return
+			}
+			text += string(key)
+			typed.Store(text)
+		}
+	})
+
+	loop.Tick()
+	time.Sleep(10 * time.Millisecond)
+	for _, key := range "hi" {
+		loop.Send(key)
+		time.Sleep(10 * time.Millisecond)
+	}
+	loop.Tick()
+	if text := typed.Load(); text != "hi" {
+		t.Error("expected typed text hi, got", text)
+	}
+}
+
+func TestLoopRun(t *testing.T) {
+	loop := tui.New[rune](nil, 8)
+	events := make(chan rune, 2)
+	events <- 'a'
+	events <- 'b'
+	close(events)
+	draws := 0
+	if err := loop.Run(context.Background(), events, func() { draws++ }); err != nil {
+		t.Error("expected nil when the events are closed, got", err)
+	}
+	if draws != 2 {
+		t.Error("expected a draw after each event, got", draws)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := loop.Run(ctx, make(chan rune), nil); err != context.DeadlineExceeded {
+		t.Error("expected the context error, got", err)
+	}
+}
@@ -0,0 +1,136 @@
+package carrot
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/exp/slices"
+)
+
+// Returned by the external calls of a coroutine that runs
+// in Validate(), such as AwaitIO(), Fetch() and LoadFile(),
+// instead of running them.
+var ErrStubbed = errors.New("external call stubbed during validation")
+
+// A ValidationResult is the outcome of Validate().
+type ValidationResult struct {
+	// Number of updates that were run.
+	Frames int
+
+	// True if the script finished within the frames.
+	Done bool
+
+	// Error of the base coroutine, see ctrl.Err().
+	Err error
+
+	// Checkpoints reached by the coroutines of the
+	// script, see script.Checkpoints().
+	Checkpoints []string
+
+	// Number of waits that were forced to end,
+	// and of external calls that were stubbed.
+	ForcedWaits  int
+	StubbedCalls int
+}
+
+// Returns the checkpoints that were not reached,
+// in the given order.
+func (result ValidationResult) Missing(checkpoints ...string) []string {
+	var missing []string
+	for _, name := range checkpoints {
+		if !slices.Contains(result.Checkpoints, name) {
+			missing = append(missing, name)
+		}
+	}
+	return missing
+}
+
+// Runs the coroutine in a dry run, to quickly check that it
+// terminates and reaches the expected checkpoints, without
+// waiting for the game or the network. Runs at most maxFrames
+// updates without waiting in between, where:
+//   - Sleep() and Delay() return on the next update,
+//     as with FastForward(), and parked coroutines are woken.
+//   - A coroutine that stays in any other wait for forceAfter
+//     frames in a row, such as YieldUntil(), stops waiting
+//     as if it was cancelled with a policy other than
+//     CancelPanic, see WithCancelPolicy().
+//   - External calls return ErrStubbed without running.
+//
+// The script is cancelled if it's not done by then.
+//
+//	Example:
+//	result := carrot.Validate(cutscene, 1000, 10)
+//	if !result.Done || len(result.Missing("intro", "outro")) > 0 { ... }
+func Validate(coroutine Coroutine, maxFrames, forceAfter int, options ...Option) ValidationResult {
+	script := Start(coroutine, options...)
+	ctrl := script.baseControl
+	v := &validation{forceAfter: forceAfter, waits: map[*Control]int{}}
+	ctrl.validation = v
+	script.Use(v.middleware)
+
+	var result ValidationResult
+	for result.Frames < maxFrames && !script.IsDone() {
+		script.FastForward()
+		script.Update()
+		ctrl.settle()
+		result.Frames++
+	}
+	result.Done = script.IsDone()
+	result.Err = ctrl.Err()
+	result.Checkpoints = script.Checkpoints()
+	result.ForcedWaits = int(v.forced.Load())
+	result.StubbedCalls = int(v.stubbed.Load())
+	if !result.Done {
+		script.Cancel()
+		script.Update()
+	}
+	return result
+}
+
+type validation struct {
+	forceAfter int
+	forced     atomic.Int64
+	stubbed    atomic.Int64
+
+	// number of frames each coroutine
+	// has waited in a row
+	mu    sync.Mutex
+	waits map[*Control]int
+}
+
+func (v *validation) middleware(next YieldFunc) YieldFunc {
+	return func(ctrl *Control, reason WaitReason) {
+		if reason == WaitWake {
+			ctrl.Wake()
+		}
+		next(ctrl, reason)
+
+		v.mu.Lock()
+		defer v.mu.Unlock()
+		if reason == WaitFrame {
+			delete(v.waits, ctrl)
+			return
+		}
+		v.waits[ctrl]++
+		if v.waits[ctrl] >= v.forceAfter {
+			delete(v.waits, ctrl)
+			v.forced.Add(1)
+			ctrl.forceWait.Store(true)
+		}
+	}
+}
+
+// Returns true if the external calls of the coroutine
+// are stubbed, since it runs in Validate().
+func (ctrl *Control) isStubbed() bool {
+	return ctrl.root().validation != nil
+}
+
+// Counts an external call that was stubbed, for
+// ValidationResult.StubbedCalls. Must only be called
+// when isStubbed() is true.
+func (ctrl *Control) countStubbed() {
+	ctrl.root().validation.stubbed.Add(1)
+}
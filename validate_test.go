@@ -0,0 +1,87 @@
+package carrot_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestValidate(t *testing.T) {
+	var fetchErr error
+	result := carrot.Validate(func(ctrl *carrot.Control) {
+		ctrl.Checkpoint("start")
+		ctrl.Sleep(time.Hour)
+		ctrl.Checkpoint("slept")
+		ctrl.YieldUntil(func() bool { return false })
+		ctrl.Checkpoint("forced")
+
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.AbyssUntilWoken()
+			_, fetchErr = carrot.AwaitIO(ctrl, func() (int, error) {
+				t.Error("external call should be stubbed")
+				return 0, nil
+			})
+		})
+		ctrl.YieldUntil(sub.IsDone)
+		ctrl.Checkpoint("end")
+	}, 100, 5)
+
+	if !result.Done {
+		t.Error("script should be done", result.Frames)
+	}
+	if result.Err != nil {
+		t.Error("unexpected error", result.Err)
+	}
+	if missing := result.Missing("start", "slept", "forced", "end", "credits"); len(missing) != 1 || missing[0] != "credits" {
+		t.Error("wrong missing checkpoints", missing)
+	}
+	if result.ForcedWaits != 1 {
+		t.Error("wrong number of forced waits", result.ForcedWaits)
+	}
+	if result.StubbedCalls != 1 || !errors.Is(fetchErr, carrot.ErrStubbed) {
+		t.Error("external call should be stubbed", result.StubbedCalls, fetchErr)
+	}
+	if result.Frames > 15 {
+		t.Error("too many frames", result.Frames)
+	}
+}
+
+func TestValidateNotDone(t *testing.T) {
+	result := carrot.Validate(func(ctrl *carrot.Control) {
+		for {
+			ctrl.Yield()
+		}
+	}, 20, 5)
+
+	if result.Done {
+		t.Error("script should not be done")
+	}
+	if result.Frames != 20 {
+		t.Error("wrong number of frames", result.Frames)
+	}
+}
+
+func TestValidateForcedOnce(t *testing.T) {
+	var endedEarly bool
+	result := carrot.Validate(func(ctrl *carrot.Control) {
+		// each sleep is ended by Validate(), but they add up
+		// to a forced wait that only the last sleep sees
+		for i := 0; i < 5; i++ {
+			ctrl.Sleep(time.Hour)
+		}
+		sub := ctrl.StartAsync(func(ctrl *carrot.Control) {
+			ctrl.Delay(2)
+		})
+		ctrl.YieldUntil(sub.IsDone)
+		endedEarly = !sub.IsDone()
+	}, 100, 5)
+
+	if !result.Done {
+		t.Error("script should be done", result.Frames)
+	}
+	if endedEarly {
+		t.Error("a forced wait should not end the next wait")
+	}
+}
@@ -0,0 +1,56 @@
+package carrot
+
+// A WaitReason describes what a coroutine is
+// currently waiting on. See ctrl.WaitReason().
+type WaitReason uint32
+
+const (
+	// The coroutine is not waiting, it's either
+	// running or done.
+	WaitNone WaitReason = iota
+
+	// Waiting for the next frame, for instance
+	// with Yield() or Delay().
+	WaitFrame
+
+	// Waiting for a duration to pass with Sleep().
+	WaitSleep
+
+	// Waiting for a condition to be true, for
+	// instance with YieldUntil(), YieldOn() or handle.Await().
+	WaitCondition
+
+	// The coroutine has returned, and is waiting
+	// for its child coroutines to end.
+	WaitChildren
+
+	// Waiting indefinitely with Abyss().
+	WaitForever
+
+	// Parked with AbyssUntilWoken(), waiting for Wake().
+	WaitWake
+)
+
+// A YieldFunc suspends the coroutine until it's resumed
+// on the next Update(). See script.Use().
+type YieldFunc func(ctrl *Control, reason WaitReason)
+
+func (reason WaitReason) String() string {
+	switch reason {
+	case WaitNone:
+		return "none"
+	case WaitFrame:
+		return "frame"
+	case WaitSleep:
+		return "sleep"
+	case WaitCondition:
+		return "condition"
+	case WaitChildren:
+		return "children"
+	case WaitForever:
+		return "forever"
+	case WaitWake:
+		return "wake"
+	}
+	return "unknown"
+}
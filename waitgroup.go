@@ -0,0 +1,54 @@
+package carrot
+
+import "sync/atomic"
+
+// A WaitGroup waits for a collection of tasks to finish,
+// like sync.WaitGroup, except that waiting is done by
+// yielding frames instead of blocking the goroutine.
+// Add() and Done() can be called from any goroutine,
+// so coroutines can wait on plain goroutine workers.
+//
+//	var wg carrot.WaitGroup
+//	for _, chunk := range chunks {
+//		wg.Add(1)
+//		go func() { defer wg.Done(); load(chunk) }()
+//	}
+//	if !wg.Wait(ctrl) { return }
+//
+// The zero value is ready to use. A WaitGroup must not be
+// copied after first use.
+type WaitGroup struct {
+	count atomic.Int64
+}
+
+// Adds delta, which may be negative, to the counter.
+// Panics if the counter becomes negative.
+func (wg *WaitGroup) Add(delta int) {
+	if wg.count.Add(int64(delta)) < 0 {
+		panic("carrot: negative WaitGroup counter")
+	}
+}
+
+// Decrements the counter by one.
+func (wg *WaitGroup) Done() {
+	wg.Add(-1)
+}
+
+// Returns the current value of the counter.
+func (wg *WaitGroup) Count() int {
+	return int(wg.count.Load())
+}
+
+// Yields until the counter is zero. Returns false if the
+// coroutine was cancelled before that, true otherwise.
+// Panics when cancelled, unless the cancel policy
+// is set to something other than CancelPanic.
+func (wg *WaitGroup) Wait(ctrl *Control) bool {
+	for wg.count.Load() > 0 {
+		if ctrl.cancelReturns() {
+			return false
+		}
+		ctrl.yield(WaitCondition)
+	}
+	return true
+}
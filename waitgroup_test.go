@@ -0,0 +1,76 @@
+package carrot_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestWaitGroup(t *testing.T) {
+	var wg carrot.WaitGroup
+	var finished atomic.Int32
+	var waited atomic.Bool
+	release := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-release
+			finished.Add(1)
+		}()
+	}
+
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		waited.Store(wg.Wait(ctrl))
+	})
+	for i := 0; i < 5; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if script.IsDone() {
+		t.Fatal("script should wait for the workers")
+	}
+
+	close(release)
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !waited.Load() || finished.Load() != 4 {
+		t.Error("Wait should return true after all workers are done", finished.Load())
+	}
+	if wg.Count() != 0 {
+		t.Error("counter should be zero", wg.Count())
+	}
+}
+
+func TestWaitGroupCancel(t *testing.T) {
+	var wg carrot.WaitGroup
+	wg.Add(1)
+	var waited, returned atomic.Bool
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		waited.Store(wg.Wait(ctrl))
+		returned.Store(true)
+	}, carrot.WithCancelPolicy(carrot.CancelError))
+	for i := 0; i < 3; i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	script.Cancel()
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	if !returned.Load() || waited.Load() {
+		t.Error("Wait should return false when cancelled")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("negative counter should panic")
+		}
+	}()
+	wg.Add(-2)
+}
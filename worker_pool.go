@@ -0,0 +1,215 @@
+package carrot
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+)
+
+// A WorkerPool runs blocking tasks, such as file or network IO,
+// on a fixed number of goroutines, so that coroutines can
+// wait for them without blocking the Update().
+// See Await() and AwaitIO().
+//
+//	Note: Methods are all concurrent-safe.
+type WorkerPool struct {
+	size  int
+	tasks chan func()
+	start sync.Once
+
+	running   atomic.Int64
+	completed atomic.Int64
+}
+
+// Statistics of a WorkerPool. See pool.Stats().
+type WorkerPoolStats struct {
+	// Number of worker goroutines.
+	Workers int
+
+	// Number of tasks waiting to be run.
+	Queued int
+
+	// Number of tasks being run.
+	Running int
+
+	// Number of tasks that are done.
+	Completed int64
+}
+
+var defaultWorkerPool atomic.Pointer[WorkerPool]
+
+func init() {
+	defaultWorkerPool.Store(NewWorkerPool(runtime.NumCPU(), 256))
+}
+
+// Creates a new worker pool with the given number of workers,
+// and at most queueLen tasks waiting to be run.
+// Workers are only started once the first task is submitted.
+func NewWorkerPool(size, queueLen int) *WorkerPool {
+	if size < 1 {
+		size = 1
+	}
+	if queueLen < 0 {
+		queueLen = 0
+	}
+	return &WorkerPool{
+		size:  size,
+		tasks: make(chan func(), queueLen),
+	}
+}
+
+// Returns the worker pool used by Await() and AwaitIO().
+func DefaultWorkerPool() *WorkerPool {
+	return defaultWorkerPool.Load()
+}
+
+// Changes the worker pool used by Await() and AwaitIO().
+// The previous pool is not closed.
+func SetDefaultWorkerPool(pool *WorkerPool) {
+	defaultWorkerPool.Store(pool)
+}
+
+// Adds a task to the pool. Yields while the queue is full.
+// Panics when cancelled.
+func (pool *WorkerPool) Submit(ctrl *Control, task func()) {
+	pool.start.Do(pool.startWorkers)
+	ctrl.YieldUntil(func() bool {
+		return pool.TrySubmit(task)
+	})
+}
+
+// Adds a task to the pool without waiting. Returns
+// false if the queue is full.
+func (pool *WorkerPool) TrySubmit(task func()) bool {
+	pool.start.Do(pool.startWorkers)
+	select {
+	case pool.tasks <- task:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the current statistics of the pool.
+func (pool *WorkerPool) Stats() WorkerPoolStats {
+	return WorkerPoolStats{
+		Workers:   pool.size,
+		Queued:    len(pool.tasks),
+		Running:   int(pool.running.Load()),
+		Completed: pool.completed.Load(),
+	}
+}
+
+// Stops the workers once the queued tasks are done.
+// No more tasks should be submitted after Close().
+func (pool *WorkerPool) Close() {
+	pool.start.Do(func() {})
+	close(pool.tasks)
+}
+
+func (pool *WorkerPool) startWorkers() {
+	for i := 0; i < pool.size; i++ {
+		go pool.work()
+	}
+}
+
+func (pool *WorkerPool) work() {
+	for task := range pool.tasks {
+		pool.running.Add(1)
+		task()
+		pool.running.Add(-1)
+		pool.completed.Add(1)
+	}
+}
+
+// Runs fn on the default worker pool, and yields until
+// it returns. If fn panics, the panic is re-raised
+// in the coroutine.
+// Panics when cancelled, fn is still run to completion
+// but the result is discarded. Returns the zero value
+// if the cancel policy is not CancelPanic.
+func Await[T any](ctrl *Control, fn func() T) T {
+	result, err := AwaitIO(ctrl, func() (T, error) {
+		return fn(), nil
+	})
+	if err, ok := err.(*PanicError); ok {
+		panic(err.Value)
+	}
+	return result
+}
+
+// Same as Await(), but for functions that can fail,
+// such as reading a file. If fn panics, a *PanicError
+// is returned. Returns ErrCancelled if the coroutine is
+// cancelled and the cancel policy is not CancelPanic.
+// See also ctrl.AwaitContext() for functions that can
+// be cancelled with a context.
+func AwaitIO[T any](ctrl *Control, fn func() (T, error)) (T, error) {
+	return awaitTask(ctrl, fn, nil)
+}
+
+// Runs fn on the default worker pool, and yields until
+// it returns. The context passed to fn is derived from
+// ctrl.Context(), so it is cancelled as soon as the coroutine
+// is cancelled or ends, and fn can stop early instead of
+// running past the end of the script.
+// If fn panics, a *PanicError is returned.
+// Panics when cancelled, or returns ErrCancelled if the
+// cancel policy is not CancelPanic.
+func (ctrl *Control) AwaitContext(fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithCancel(ctrl.Context())
+	defer cancel()
+	_, err := awaitTask(ctrl, func() (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	}, nil)
+	return err
+}
+
+// states of a task run with awaitTask()
+const (
+	taskPending int32 = iota
+	taskDone
+	taskAbandoned
+)
+
+// Runs task on the default worker pool, and yields until it
+// returns. If the coroutine is cancelled first, the task is
+// abandoned and its result is never read by the coroutine,
+// but passed to discard if not nil once the task returns, so
+// that resources such as response bodies are not leaked.
+func awaitTask[T any](ctrl *Control, task func() (T, error), discard func(T)) (T, error) {
+	var zero T
+	if ctrl.isStubbed() {
+		ctrl.countStubbed()
+		return zero, ErrStubbed
+	}
+	// only read by the coroutine once the state is taskDone
+	var result T
+	var err error
+	var state atomic.Int32
+
+	DefaultWorkerPool().Submit(ctrl, func() {
+		value, taskErr := runTask(task)
+		result, err = value, taskErr
+		if !state.CompareAndSwap(taskPending, taskDone) && discard != nil {
+			discard(value)
+		}
+	})
+	// also abandons the task when the wait panics
+	defer state.CompareAndSwap(taskPending, taskAbandoned)
+	YieldUntilEqual(ctrl, state.Load, taskDone)
+	if state.CompareAndSwap(taskPending, taskAbandoned) {
+		return zero, ErrCancelled
+	}
+	return result, err
+}
+
+func runTask[T any](task func() (T, error)) (result T, err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Value: value}
+		}
+	}()
+	return task()
+}
@@ -0,0 +1,128 @@
+package carrot_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nvlled/carrot"
+)
+
+func TestAwait(t *testing.T) {
+	pool := carrot.NewWorkerPool(2, 1)
+	defer pool.Close()
+	prevPool := carrot.DefaultWorkerPool()
+	carrot.SetDefaultWorkerPool(pool)
+	defer carrot.SetDefaultWorkerPool(prevPool)
+
+	var running, maxRunning, sum atomic.Int32
+	var ioErr error
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		carrot.ForEach(ctrl, []int32{1, 2, 3, 4, 5}, 0, func(ctrl *carrot.Control, n int32) {
+			result := carrot.Await(ctrl, func() int32 {
+				count := running.Add(1)
+				defer running.Add(-1)
+				for {
+					max := maxRunning.Load()
+					if count <= max || maxRunning.CompareAndSwap(max, count) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				return n * 10
+			})
+			sum.Add(result)
+		})
+
+		_, ioErr = carrot.AwaitIO(ctrl, func() (string, error) {
+			return "", errors.New("not found")
+		})
+	})
+
+	for !script.IsDone() {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+
+	if sum.Load() != 150 {
+		t.Error("wrong sum", sum.Load())
+	}
+	if maxRunning.Load() > 2 {
+		t.Error("too many tasks running at the same time", maxRunning.Load())
+	}
+	if ioErr == nil || ioErr.Error() != "not found" {
+		t.Error("expected error", ioErr)
+	}
+	// the last task is counted after its result is delivered
+	for i := 0; i < 100 && pool.Stats().Completed < 6; i++ {
+		time.Sleep(updateDelay)
+	}
+	if stats := pool.Stats(); stats.Completed != 6 || stats.Workers != 2 {
+		t.Error("wrong stats", stats)
+	}
+}
+
+func TestAwaitContextCancel(t *testing.T) {
+	var ctxErr atomic.Pointer[error]
+	started := make(chan struct{})
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		ctrl.AwaitContext(func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			err := ctx.Err()
+			ctxErr.Store(&err)
+			return err
+		})
+		t.Error("AwaitContext should not return when cancelled")
+	})
+
+	script.Update()
+	<-started
+	script.Cancel()
+	script.Update()
+
+	for i := 0; i < 100 && ctxErr.Load() == nil; i++ {
+		time.Sleep(updateDelay)
+	}
+	if err := ctxErr.Load(); err == nil || *err != context.Canceled {
+		t.Error("context should be cancelled")
+	}
+}
+
+func TestAwaitIOCancelError(t *testing.T) {
+	release := make(chan struct{})
+	var result atomic.Pointer[string]
+	var awaitErr, ctxErr atomic.Value
+	script := carrot.Start(func(ctrl *carrot.Control) {
+		value, err := carrot.AwaitIO(ctrl, func() (string, error) {
+			<-release
+			return "late", nil
+		})
+		result.Store(&value)
+		awaitErr.Store(err)
+		ctxErr.Store(ctrl.AwaitContext(func(ctx context.Context) error {
+			return ctx.Err()
+		}))
+	}, carrot.WithCancelPolicy(carrot.CancelError))
+
+	script.Update()
+	script.Cancel()
+	for i := 0; i < 100 && !script.IsDone(); i++ {
+		script.Update()
+		time.Sleep(updateDelay)
+	}
+	// the abandoned task still finishes on the worker
+	close(release)
+
+	if err, _ := awaitErr.Load().(error); err != carrot.ErrCancelled {
+		t.Error("AwaitIO should return ErrCancelled", err)
+	}
+	if value := result.Load(); value == nil || *value != "" {
+		t.Error("AwaitIO should return the zero value when cancelled", value)
+	}
+	if err, _ := ctxErr.Load().(error); err != carrot.ErrCancelled {
+		t.Error("AwaitContext should return ErrCancelled", err)
+	}
+}